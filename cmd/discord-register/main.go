@@ -0,0 +1,38 @@
+// Command discord-register registers the /makan application command with
+// Discord. Run it once per application, and again whenever the command
+// definition changes.
+//
+//	go run ./cmd/discord-register -app-id 123 -guild 456
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/discord"
+)
+
+func main() {
+	appID := flag.String("app-id", os.Getenv("DISCORD_APPLICATION_ID"), "Discord application ID")
+	botToken := flag.String("token", os.Getenv("DISCORD_BOT_TOKEN"), "Discord bot token")
+	guildID := flag.String("guild", "", "register for this server only instead of globally")
+	flag.Parse()
+
+	if *appID == "" || *botToken == "" {
+		log.Fatal("the application ID and bot token are required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := discord.RegisterCommands(ctx, http.DefaultClient, *appID, *guildID, *botToken)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("registered /" + discord.MakanCommand.Name)
+}
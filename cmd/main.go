@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -9,9 +10,20 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/auth"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/category"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/config"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/database"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/draw"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/http/router"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/location"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/place"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/preference"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/schedule"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/trash"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/spf13/viper"
@@ -38,16 +50,59 @@ func main() {
 	flag.StringVar(&cfg.Database.DSN, "dsn", viper.GetString("DB_CONNECTIONSTRING"), "mySQL connection string")
 	flag.StringVar(&cfg.SecretCode, "secretCode", viper.GetString("SECRET_CODE"), "registration secret code")
 	flag.StringVar(&cfg.Env, "env", "development", "Application environment (development|production)")
-	flag.StringVar(&cfg.JWT.Secret, "jwt-secret", "2dce505d96a53c5768052ee90f3df2055657518dad489160df9913f66042e160", "secret")
+	flag.StringVar(&cfg.Schedule.HolidayCalendarPath, "holiday-calendar", viper.GetString("HOLIDAY_CALENDAR_PATH"), "public holiday calendar file for scheduled picks")
+	flag.StringVar(&cfg.Slack.SigningSecret, "slack-signing-secret", viper.GetString("SLACK_SIGNING_SECRET"), "Slack app signing secret")
+	flag.StringVar(&cfg.Discord.PublicKey, "discord-public-key", viper.GetString("DISCORD_PUBLIC_KEY"), "Discord application public key, hex encoded")
+	flag.IntVar(&cfg.Trash.RetentionDays, "trash-retention-days", viper.GetInt("TRASH_RETENTION_DAYS"), "days deleted places, categories and locations stay in the trash before they are purged (0 keeps them)")
+	flag.StringVar(&cfg.JWT.Secret, "jwt-secret", viper.GetString("JWT_ACCESS_SECRET"), "secret that signs access tokens")
+	flag.StringVar(&cfg.JWT.RefreshSecret, "jwt-refresh-secret", viper.GetString("JWT_REFRESH_SECRET"), "secret that signs refresh tokens")
 	flag.Parse()
 
+	signer, err := auth.NewSigner(cfg.JWT)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
 	db, err := database.OpenDB(cfg.Database)
 	if err != nil {
 		logger.Fatal(err)
 	}
 	defer db.Close()
 
-	r := router.NewRouter(cfg, db)
+	holidays, err := schedule.LoadHolidayCalendar(cfg.Schedule.HolidayCalendarPath)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	hooks := webhook.NewDispatcher(webhook.NewSQLWebhookRepository(db), logger)
+	go hooks.Run(context.Background())
+
+	scheduler := schedule.NewScheduler(
+		schedule.NewSQLScheduleRepository(db),
+		workspace.NewSQLWorkspaceRepository(db),
+		place.NewSQLPlaceRepository(db),
+		area.NewSQLAreaRepository(db),
+		preference.NewSQLPreferenceRepository(db),
+		draw.NewSQLDrawRepository(db),
+		holidays,
+		hooks,
+		logger,
+	)
+	go scheduler.Run(context.Background())
+
+	if cfg.Trash.RetentionDays > 0 {
+		purger := trash.NewPurger(
+			workspace.NewSQLWorkspaceRepository(db),
+			place.NewSQLPlaceRepository(db),
+			category.NewSQLCategoryRepostory(db),
+			location.NewSQLLocationRepository(db),
+			time.Duration(cfg.Trash.RetentionDays)*24*time.Hour,
+			logger,
+		)
+		go purger.Run(context.Background())
+	}
+
+	r := router.NewRouter(cfg, db, signer, holidays, hooks)
 
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
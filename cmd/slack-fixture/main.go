@@ -0,0 +1,112 @@
+// Command slack-fixture signs a Slack request fixture and sends it to a
+// locally running server, so the Slack endpoints can be exercised without a
+// Slack workspace. Fixtures live in pkg/slack/testdata: .txt files are slash
+// command bodies and .json files are interaction payloads.
+//
+//	go run ./cmd/slack-fixture -secret $SLACK_SIGNING_SECRET pkg/slack/testdata/command.txt
+//	go run ./cmd/slack-fixture -secret $SLACK_SIGNING_SECRET -draw 1a2b3c4d5e6f7a8b pkg/slack/testdata/interaction-vote.json
+//
+// Interaction fixtures point response_url at -listen, where the updated
+// message that the server posts back is printed.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/slack"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:4000", "base URL of the running API")
+	secret := flag.String("secret", os.Getenv("SLACK_SIGNING_SECRET"), "Slack signing secret the server is configured with")
+	drawID := flag.String("draw", "", "draw ID to substitute for DRAW_ID in the fixture")
+	listen := flag.String("listen", "localhost:9999", "address to receive response_url posts on")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		log.Fatal("usage: slack-fixture [flags] fixture")
+	}
+	path := flag.Arg(0)
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fixture := strings.ReplaceAll(string(raw), "DRAW_ID", *drawID)
+
+	var body []byte
+	endpoint := *server + "/v1/integrations/slack/command"
+	if filepath.Ext(path) == ".json" {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(fixture)); err != nil {
+			log.Fatal(err)
+		}
+		body = []byte("payload=" + url.QueryEscape(compact.String()))
+		endpoint = *server + "/v1/integrations/slack/interactions"
+	} else {
+		body = []byte(strings.TrimSpace(fixture))
+	}
+
+	responses := make(chan []byte, 1)
+	if *listen != "" {
+		ln, err := net.Listen("tcp", *listen)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			responses <- b
+		}))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(slack.HeaderTimestamp, fmt.Sprint(now.Unix()))
+	req.Header.Set(slack.HeaderSignature, slack.Sign(*secret, now, body))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	fmt.Println(resp.Status)
+	printJSON(respBody)
+
+	if *listen == "" || filepath.Ext(path) != ".json" {
+		return
+	}
+	select {
+	case b := <-responses:
+		fmt.Println("response_url:")
+		printJSON(b)
+	case <-time.After(5 * time.Second):
+		fmt.Println("no response_url post received")
+	}
+}
+
+func printJSON(b []byte) {
+	var out bytes.Buffer
+	if json.Indent(&out, b, "", "  ") != nil {
+		fmt.Println(string(b))
+		return
+	}
+	fmt.Println(out.String())
+}
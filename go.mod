@@ -6,7 +6,6 @@ require (
 	github.com/go-sql-driver/mysql v1.7.0
 	github.com/golang-jwt/jwt/v4 v4.5.0
 	github.com/gorilla/mux v1.8.1
-	github.com/julienschmidt/httprouter v1.3.0
 	github.com/pascaldekloe/jwt v1.12.0
 	github.com/spf13/viper v1.15.0
 	golang.org/x/crypto v0.1.0
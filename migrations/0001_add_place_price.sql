@@ -0,0 +1,7 @@
+-- Price information for places. price_level is 1 (cheapest) to 4, with 0
+-- meaning the level has not been captured yet. Spend columns are typical
+-- per-person amounts in SGD and are optional.
+ALTER TABLE place
+    ADD COLUMN price_level TINYINT NOT NULL DEFAULT 0,
+    ADD COLUMN min_spend DECIMAL(8, 2) NULL,
+    ADD COLUMN max_spend DECIMAL(8, 2) NULL;
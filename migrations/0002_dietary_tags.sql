@@ -0,0 +1,39 @@
+-- Admin-managed dietary tag taxonomy replacing place.is_halal and
+-- place.is_vegetarian.
+CREATE TABLE dietary_tag (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    slug VARCHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE place_dietary_tag (
+    place_id INT NOT NULL,
+    dietary_tag_id INT NOT NULL,
+    PRIMARY KEY (place_id, dietary_tag_id),
+    FOREIGN KEY (place_id) REFERENCES place (id) ON DELETE CASCADE,
+    FOREIGN KEY (dietary_tag_id) REFERENCES dietary_tag (id) ON DELETE CASCADE
+);
+
+INSERT INTO dietary_tag (slug, name) VALUES
+    ('halal-certified', 'Halal certified'),
+    ('muslim-owned', 'Muslim-owned'),
+    ('vegetarian', 'Vegetarian'),
+    ('vegan', 'Vegan'),
+    ('no-beef', 'No beef'),
+    ('gluten-free', 'Gluten-free'),
+    ('nut-free', 'Nut-free'),
+    ('pescatarian', 'Pescatarian');
+
+-- The old flag did not say whether a place is certified, so existing halal
+-- places are tagged as certified and should be reviewed by an admin.
+INSERT INTO place_dietary_tag (place_id, dietary_tag_id)
+SELECT p.id, t.id FROM place p JOIN dietary_tag t ON t.slug = 'halal-certified' WHERE p.is_halal = 1;
+
+INSERT INTO place_dietary_tag (place_id, dietary_tag_id)
+SELECT p.id, t.id FROM place p JOIN dietary_tag t ON t.slug = 'vegetarian' WHERE p.is_vegetarian = 1;
+
+ALTER TABLE place
+    DROP COLUMN is_halal,
+    DROP COLUMN is_vegetarian;
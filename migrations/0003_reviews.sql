@@ -0,0 +1,21 @@
+-- Place reviews and their per-place aggregate. place_rating is maintained by
+-- the review repository in the same transaction as each review change.
+CREATE TABLE review (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    place_id INT NOT NULL,
+    user_id INT NOT NULL,
+    rating TINYINT NOT NULL,
+    comment TEXT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_review_place_user (place_id, user_id),
+    FOREIGN KEY (place_id) REFERENCES place (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES user (id) ON DELETE CASCADE
+);
+
+CREATE TABLE place_rating (
+    place_id INT PRIMARY KEY,
+    rating_count INT NOT NULL DEFAULT 0,
+    rating_sum INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (place_id) REFERENCES place (id) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+-- Per-user favourite and blocked places. A place is either a favourite or
+-- blocked for a user, never both.
+CREATE TABLE user_place_preference (
+    user_id INT NOT NULL,
+    place_id INT NOT NULL,
+    kind ENUM('favourite', 'blocked') NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, place_id),
+    FOREIGN KEY (user_id) REFERENCES user (id) ON DELETE CASCADE,
+    FOREIGN KEY (place_id) REFERENCES place (id) ON DELETE CASCADE
+);
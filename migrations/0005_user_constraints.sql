@@ -0,0 +1,17 @@
+-- Standing dietary requirements and budget caps per user, merged when a
+-- group generates a place together.
+CREATE TABLE user_constraint (
+    user_id INT PRIMARY KEY,
+    max_price TINYINT NOT NULL DEFAULT 0,
+    budget DECIMAL(8, 2) NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES user (id) ON DELETE CASCADE
+);
+
+CREATE TABLE user_dietary_tag (
+    user_id INT NOT NULL,
+    dietary_tag_id INT NOT NULL,
+    PRIMARY KEY (user_id, dietary_tag_id),
+    FOREIGN KEY (user_id) REFERENCES user (id) ON DELETE CASCADE,
+    FOREIGN KEY (dietary_tag_id) REFERENCES dietary_tag (id) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+-- Every generated pick is recorded so that it can be shared and reproduced.
+-- candidates is a snapshot of the pool the pick was drawn from.
+CREATE TABLE draw (
+    id CHAR(16) PRIMARY KEY,
+    seed BIGINT NOT NULL,
+    filters JSON NOT NULL,
+    candidates JSON NOT NULL,
+    picked_place_ids JSON NOT NULL,
+    user_id INT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_draw_created_at (created_at),
+    FOREIGN KEY (user_id) REFERENCES user (id) ON DELETE SET NULL
+);
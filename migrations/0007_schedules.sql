@@ -0,0 +1,25 @@
+-- Scheduled picks. filters holds a generatePlace query string such as
+-- "is_halal=true&max_price=2". Each schedule gets at most one pick per day.
+CREATE TABLE schedule (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    cron_expr VARCHAR(255) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Singapore',
+    filters VARCHAR(1024) NOT NULL DEFAULT '',
+    skip_holidays TINYINT(1) NOT NULL DEFAULT 1,
+    enabled TINYINT(1) NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE schedule_pick (
+    schedule_id INT NOT NULL,
+    pick_date DATE NOT NULL,
+    place_id INT NOT NULL,
+    draw_id CHAR(16) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (schedule_id, pick_date),
+    FOREIGN KEY (schedule_id) REFERENCES schedule (id) ON DELETE CASCADE,
+    FOREIGN KEY (place_id) REFERENCES place (id) ON DELETE CASCADE,
+    FOREIGN KEY (draw_id) REFERENCES draw (id)
+);
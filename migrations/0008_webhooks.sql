@@ -0,0 +1,31 @@
+-- Outgoing webhooks. events is a comma-separated list of event names such as
+-- "place.created,pick.generated". Every delivery attempt is logged in
+-- webhook_delivery; a replay is a new delivery that points at the original.
+CREATE TABLE webhook_subscription (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    url VARCHAR(1024) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    events VARCHAR(512) NOT NULL,
+    enabled TINYINT(1) NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE webhook_delivery (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    subscription_id INT NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    payload JSON NOT NULL,
+    status ENUM('pending', 'succeeded', 'failed') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NULL,
+    last_status_code INT NULL,
+    last_error VARCHAR(1024) NOT NULL DEFAULT '',
+    replay_of INT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX (status, next_attempt_at),
+    INDEX (subscription_id, created_at),
+    FOREIGN KEY (subscription_id) REFERENCES webhook_subscription (id) ON DELETE CASCADE,
+    FOREIGN KEY (replay_of) REFERENCES webhook_delivery (id) ON DELETE SET NULL
+);
@@ -0,0 +1,9 @@
+-- Votes cast with the "vote" button on a Slack pick message. Slack users are
+-- not app users, so votes are keyed by Slack user ID.
+CREATE TABLE slack_vote (
+    draw_id CHAR(16) NOT NULL,
+    slack_user_id VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (draw_id, slack_user_id),
+    FOREIGN KEY (draw_id) REFERENCES draw (id) ON DELETE CASCADE
+);
@@ -0,0 +1,30 @@
+-- Service areas. boundary is a GeoJSON MultiPolygon; area filters test place
+-- coordinates against it, and area_id on places and locations records the
+-- area they were entered for.
+CREATE TABLE area (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'Asia/Singapore',
+    boundary JSON NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE place
+    ADD COLUMN area_id INT NULL,
+    ADD FOREIGN KEY (area_id) REFERENCES area (id) ON DELETE SET NULL;
+
+ALTER TABLE location
+    ADD COLUMN area_id INT NULL,
+    ADD FOREIGN KEY (area_id) REFERENCES area (id) ON DELETE SET NULL;
+
+-- Everything so far is in Novena. The boundary is a rough box around it and
+-- should be replaced by uploading the planning area outline.
+INSERT INTO area (name, timezone, boundary) VALUES (
+    'Novena',
+    'Asia/Singapore',
+    '{"type": "MultiPolygon", "coordinates": [[[[103.834, 1.313], [103.856, 1.313], [103.856, 1.330], [103.834, 1.330], [103.834, 1.313]]]]}'
+);
+
+UPDATE place SET area_id = (SELECT id FROM area WHERE name = 'Novena');
+UPDATE location SET area_id = (SELECT id FROM area WHERE name = 'Novena');
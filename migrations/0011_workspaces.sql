@@ -0,0 +1,83 @@
+-- Workspaces give each team its own places, categories, locations, schedules,
+-- draws and webhooks. Members hold a role per workspace; user.workspace_id is the
+-- workspace a user last switched to and is used at login.
+CREATE TABLE workspace (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(64) NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE workspace_member (
+    workspace_id INT NOT NULL,
+    user_id INT NOT NULL,
+    role TINYINT NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, user_id),
+    FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES user (id) ON DELETE CASCADE
+);
+
+-- Invites are single use. code is the secret the invitee redeems.
+CREATE TABLE workspace_invite (
+    code CHAR(32) PRIMARY KEY,
+    workspace_id INT NOT NULL,
+    role TINYINT NOT NULL DEFAULT 0,
+    created_by INT NULL,
+    expires_at DATETIME NOT NULL,
+    accepted_by INT NULL,
+    accepted_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES user (id) ON DELETE SET NULL,
+    FOREIGN KEY (accepted_by) REFERENCES user (id) ON DELETE SET NULL
+);
+
+-- Everything that exists today belongs to the default workspace, which
+-- anonymous callers also see. Every user joins it with their current role.
+INSERT INTO workspace (id, name, slug) VALUES (1, 'Default', 'default');
+
+INSERT INTO workspace_member (workspace_id, user_id, role)
+SELECT 1, id, role FROM user;
+
+ALTER TABLE user
+    ADD COLUMN workspace_id INT NULL,
+    ADD FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE SET NULL;
+
+ALTER TABLE place
+    ADD COLUMN workspace_id INT NOT NULL DEFAULT 1,
+    ADD INDEX idx_place_workspace (workspace_id),
+    ADD FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE;
+
+ALTER TABLE category
+    ADD COLUMN workspace_id INT NOT NULL DEFAULT 1,
+    ADD INDEX idx_category_workspace (workspace_id),
+    ADD FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE;
+
+ALTER TABLE location
+    ADD COLUMN workspace_id INT NOT NULL DEFAULT 1,
+    ADD INDEX idx_location_workspace (workspace_id),
+    ADD FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE;
+
+ALTER TABLE schedule
+    ADD COLUMN workspace_id INT NOT NULL DEFAULT 1,
+    ADD INDEX idx_schedule_workspace (workspace_id),
+    ADD FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE;
+
+ALTER TABLE webhook_subscription
+    ADD COLUMN workspace_id INT NOT NULL DEFAULT 1,
+    ADD INDEX idx_webhook_subscription_workspace (workspace_id),
+    ADD FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE;
+
+-- Deliveries copy their subscription's workspace so the background sender
+-- knows which workspace each one belongs to.
+ALTER TABLE webhook_delivery
+    ADD COLUMN workspace_id INT NOT NULL DEFAULT 1,
+    ADD INDEX idx_webhook_delivery_workspace (workspace_id);
+
+-- Draws are the group sessions behind every pick, vote and reroll.
+ALTER TABLE draw
+    ADD COLUMN workspace_id INT NOT NULL DEFAULT 1,
+    ADD INDEX idx_draw_workspace (workspace_id),
+    ADD FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE;
@@ -0,0 +1,24 @@
+-- Suggestions are new places or corrections sent in by users and held for a
+-- moderator. proposed is the suggested place as JSON. An approved suggestion
+-- records the place it created or changed in place_id.
+CREATE TABLE place_suggestion (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    workspace_id INT NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    place_id INT NULL,
+    user_id INT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    proposed JSON NOT NULL,
+    comment TEXT NULL,
+    moderator_id INT NULL,
+    moderator_note TEXT NULL,
+    reviewed_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_place_suggestion_queue (workspace_id, status, created_at),
+    INDEX idx_place_suggestion_user (user_id, created_at),
+    FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE,
+    FOREIGN KEY (place_id) REFERENCES place (id) ON DELETE SET NULL,
+    FOREIGN KEY (user_id) REFERENCES user (id) ON DELETE SET NULL,
+    FOREIGN KEY (moderator_id) REFERENCES user (id) ON DELETE SET NULL
+);
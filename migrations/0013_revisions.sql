@@ -0,0 +1,17 @@
+-- Every insert, update and delete of a place, category or location appends a
+-- revision in the same transaction. Rows are never updated or deleted, so
+-- there are no foreign keys that could null them out; entity_id and user_id
+-- may refer to rows that no longer exist.
+CREATE TABLE revision (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    workspace_id INT NOT NULL,
+    entity_type VARCHAR(16) NOT NULL,
+    entity_id INT NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    user_id INT NULL,
+    before_snapshot JSON NULL,
+    after_snapshot JSON NULL,
+    revert_of INT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_revision_entity (workspace_id, entity_type, entity_id, id)
+);
@@ -0,0 +1,14 @@
+-- Deleting a place, category or location moves it to the trash by setting
+-- deleted_at. Every read skips trashed rows; the purge job removes them for
+-- good once they have been in the trash for the configured number of days.
+ALTER TABLE place
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD INDEX idx_place_deleted (workspace_id, deleted_at);
+
+ALTER TABLE category
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD INDEX idx_category_deleted (workspace_id, deleted_at);
+
+ALTER TABLE location
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD INDEX idx_location_deleted (workspace_id, deleted_at);
@@ -0,0 +1,27 @@
+-- The audit log records who did what through the admin and auth endpoints.
+-- It is append-only: the triggers below reject every update and delete, and
+-- there are no foreign keys, so entries outlive the users, workspaces and
+-- rows they mention.
+CREATE TABLE audit_log (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    workspace_id INT NOT NULL,
+    actor_id INT NULL,
+    actor_name VARCHAR(255) NOT NULL DEFAULT '',
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32) NOT NULL DEFAULT '',
+    target_id VARCHAR(255) NOT NULL DEFAULT '',
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    status INT NOT NULL,
+    summary TEXT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_audit_workspace (workspace_id, id),
+    INDEX idx_audit_actor (workspace_id, actor_id, id),
+    INDEX idx_audit_target (workspace_id, target_type, target_id, id)
+);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
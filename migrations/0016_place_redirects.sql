@@ -0,0 +1,16 @@
+-- Merging a duplicate place into another moves its reviews, preferences and
+-- pick history to the surviving place and trashes the duplicate. The old ID
+-- keeps resolving to the survivor through place_redirect; redirects into a
+-- place that is itself merged later are repointed, so there is never more
+-- than one hop.
+CREATE TABLE place_redirect (
+    from_place_id INT PRIMARY KEY,
+    to_place_id INT NOT NULL,
+    workspace_id INT NOT NULL,
+    merged_by INT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_place_redirect_to (to_place_id),
+    FOREIGN KEY (to_place_id) REFERENCES place (id) ON DELETE CASCADE,
+    FOREIGN KEY (workspace_id) REFERENCES workspace (id) ON DELETE CASCADE,
+    FOREIGN KEY (merged_by) REFERENCES user (id) ON DELETE SET NULL
+);
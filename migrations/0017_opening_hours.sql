@@ -0,0 +1,5 @@
+-- Weekly opening hours, as a JSON list of {"day", "opens", "closes"} periods
+-- in the local time of the place's area. NULL means the hours have not been
+-- captured, and such places are never filtered out as closed.
+ALTER TABLE place
+    ADD COLUMN opening_hours JSON NULL;
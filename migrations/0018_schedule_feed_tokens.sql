@@ -0,0 +1,10 @@
+-- Calendar apps cannot send a login token, so each schedule's iCalendar feed
+-- is served under an unguessable token instead of its sequential ID.
+ALTER TABLE schedule
+    ADD COLUMN feed_token CHAR(32) NULL;
+
+UPDATE schedule SET feed_token = LOWER(HEX(RANDOM_BYTES(16)));
+
+ALTER TABLE schedule
+    MODIFY feed_token CHAR(32) NOT NULL,
+    ADD UNIQUE INDEX idx_schedule_feed_token (feed_token);
@@ -0,0 +1,9 @@
+-- Closed votes on Slack pick messages. A draw's vote is open until someone
+-- presses "Close vote"; after that its message shows the final count and
+-- further votes are refused.
+CREATE TABLE slack_vote_session (
+    draw_id CHAR(16) NOT NULL PRIMARY KEY,
+    closed_by VARCHAR(32) NOT NULL,
+    closed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (draw_id) REFERENCES draw (id) ON DELETE CASCADE
+);
@@ -0,0 +1,247 @@
+package area
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+const (
+	// maxBoundarySize bounds uploaded GeoJSON. Planning area outlines are
+	// well under this.
+	maxBoundarySize = 5 << 20
+)
+
+type AreaDto struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Timezone string `json:"timezone"`
+	// Boundary is GeoJSON as accepted by geo.ParseBoundary. It is required
+	// for new areas and kept when an update leaves it out.
+	Boundary json.RawMessage `json:"boundary"`
+}
+
+func (dto *AreaDto) validate() error {
+	dto.Name = strings.TrimSpace(dto.Name)
+	if dto.Name == "" {
+		return errors.New("name is required")
+	}
+	if _, err := strconv.Atoi(dto.Name); err == nil {
+		return errors.New("name must not be a number")
+	}
+
+	if dto.Timezone == "" {
+		dto.Timezone = DefaultTimezone
+	}
+	if _, err := time.LoadLocation(dto.Timezone); err != nil {
+		return errors.New("unknown timezone")
+	}
+
+	if dto.ID == 0 && len(dto.Boundary) == 0 {
+		return errors.New("boundary is required")
+	}
+
+	return nil
+}
+
+func GetAllAreas(repo AreaRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		areas, err := repo.GetAllAreas(ctx)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, areas, "areas")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func GetAreaByID(repo AreaRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		area, err := repo.GetAreaByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.ErrorJSON(w, errors.New("area does not exist"), http.StatusNotFound)
+				return
+			}
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, area, "area")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// EditArea creates or updates an area. The boundary can be drawn on a map
+// and sent inline, or uploaded separately with UploadAreaBoundary.
+func EditArea(repo AreaRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload AreaDto
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBoundarySize)).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = payload.validate()
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+
+		var boundary geo.MultiPolygon
+		if len(payload.Boundary) > 0 {
+			boundary, err = geo.ParseBoundary(payload.Boundary)
+			if err != nil {
+				utils.ErrorJSON(w, err, http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		var area models.Area
+		if payload.ID != 0 {
+			m, err := repo.GetAreaByID(ctx, payload.ID)
+			if err != nil {
+				utils.ErrorJSON(w, err)
+				return
+			}
+			area = *m
+		} else {
+			area.CreatedAt = time.Now()
+		}
+
+		area.Name = payload.Name
+		area.Timezone = payload.Timezone
+		if boundary != nil {
+			area.Boundary = boundary
+		}
+		area.UpdatedAt = time.Now()
+
+		if area.ID == 0 {
+			area.ID, err = repo.InsertArea(ctx, area)
+		} else {
+			err = repo.UpdateArea(ctx, area)
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, area, "area")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// UploadAreaBoundary replaces an area's boundary with the GeoJSON file in
+// the request body, such as a planning area outline exported from a GIS
+// tool.
+func UploadAreaBoundary(repo AreaRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBoundarySize))
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+
+		boundary, err := geo.ParseBoundary(data)
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		area, err := repo.GetAreaByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.ErrorJSON(w, errors.New("area does not exist"), http.StatusNotFound)
+				return
+			}
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		area.Boundary = boundary
+		area.UpdatedAt = time.Now()
+		err = repo.UpdateArea(ctx, *area)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, area, "area")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func DeleteArea(repo AreaRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		err = repo.DeleteArea(ctx, id)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, "Deleted Successfully", "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
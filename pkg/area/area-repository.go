@@ -0,0 +1,191 @@
+package area
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+// ErrAreaNotFound is returned by Lookup when no area has the given ID or
+// name.
+var ErrAreaNotFound = errors.New("area not found")
+
+// DefaultTimezone is the timezone of areas created without one, and of
+// places outside any area.
+const DefaultTimezone = "Asia/Singapore"
+
+var _ AreaRepository = &SQLAreaRepository{}
+
+type AreaRepository interface {
+	GetAreaByID(ctx context.Context, id int) (*models.Area, error)
+	GetAreaByName(ctx context.Context, name string) (*models.Area, error)
+	GetAllAreas(ctx context.Context) ([]*models.Area, error)
+	InsertArea(ctx context.Context, area models.Area) (int, error)
+	UpdateArea(ctx context.Context, area models.Area) error
+	DeleteArea(ctx context.Context, id int) error
+}
+
+type SQLAreaRepository struct {
+	db *sql.DB
+}
+
+func NewSQLAreaRepository(db *sql.DB) *SQLAreaRepository {
+	return &SQLAreaRepository{db: db}
+}
+
+const areaColumns = `id, name, timezone, boundary, created_at, updated_at`
+
+func scanArea(row interface{ Scan(...interface{}) error }) (*models.Area, error) {
+	var a models.Area
+	var boundary []byte
+	err := row.Scan(
+		&a.ID,
+		&a.Name,
+		&a.Timezone,
+		&boundary,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Boundary, err = geo.ParseBoundary(boundary)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+func (repo *SQLAreaRepository) GetAreaByID(ctx context.Context, id int) (*models.Area, error) {
+	row := repo.db.QueryRowContext(ctx, `select `+areaColumns+` from area where id = ?`, id)
+	return scanArea(row)
+}
+
+func (repo *SQLAreaRepository) GetAreaByName(ctx context.Context, name string) (*models.Area, error) {
+	row := repo.db.QueryRowContext(ctx, `select `+areaColumns+` from area where lower(name) = lower(?)`, name)
+	return scanArea(row)
+}
+
+func (repo *SQLAreaRepository) GetAllAreas(ctx context.Context) ([]*models.Area, error) {
+	rows, err := repo.db.QueryContext(ctx, `select `+areaColumns+` from area order by name`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var areas []*models.Area
+	for rows.Next() {
+		a, err := scanArea(rows)
+		if err != nil {
+			return nil, err
+		}
+		areas = append(areas, a)
+	}
+	return areas, rows.Err()
+}
+
+func (repo *SQLAreaRepository) InsertArea(ctx context.Context, a models.Area) (int, error) {
+	boundary, err := a.Boundary.MarshalJSON()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `
+		insert into area (name, timezone, boundary, created_at, updated_at)
+		values (?, ?, ?, ?, ?)
+	`
+	result, err := repo.db.ExecContext(ctx, stmt,
+		a.Name,
+		a.Timezone,
+		boundary,
+		a.CreatedAt,
+		a.UpdatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+func (repo *SQLAreaRepository) UpdateArea(ctx context.Context, a models.Area) error {
+	boundary, err := a.Boundary.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	stmt := `update area set name = ?, timezone = ?, boundary = ?, updated_at = ? where id = ?`
+	_, err = repo.db.ExecContext(ctx, stmt,
+		a.Name,
+		a.Timezone,
+		boundary,
+		a.UpdatedAt,
+		a.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *SQLAreaRepository) DeleteArea(ctx context.Context, id int) error {
+	_, err := repo.db.ExecContext(ctx, `delete from area where id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Lookup finds an area by ID or, failing that, by name, ignoring case. It is
+// how area= query parameters are resolved.
+func Lookup(ctx context.Context, repo AreaRepository, key string) (*models.Area, error) {
+	key = strings.TrimSpace(key)
+
+	var a *models.Area
+	var err error
+	if id, convErr := strconv.Atoi(key); convErr == nil {
+		a, err = repo.GetAreaByID(ctx, id)
+	} else {
+		a, err = repo.GetAreaByName(ctx, key)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAreaNotFound
+	}
+
+	return a, err
+}
+
+// Timezones loads the timezone of every area, keyed by area ID.
+func Timezones(ctx context.Context, repo AreaRepository) (map[int]*time.Location, error) {
+	areas, err := repo.GetAllAreas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := make(map[int]*time.Location, len(areas))
+	for _, a := range areas {
+		loc, err := time.LoadLocation(a.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("area %d: %w", a.ID, err)
+		}
+		zones[a.ID] = loc
+	}
+	return zones, nil
+}
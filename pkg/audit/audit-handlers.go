@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+const (
+	defaultEntryLimit = 50
+	maxEntryLimit     = 500
+)
+
+// AuditPage is one page of entries. NextBefore, when set, is the before
+// parameter that fetches the next, older page.
+type AuditPage struct {
+	Entries    []*models.AuditEntry `json:"entries"`
+	NextBefore *int64               `json:"next_before"`
+}
+
+// GetAuditLog lists the workspace's audit log, newest first. It is filtered
+// by the actor_id, action, target_type, target_id and request_id query
+// parameters and by since and until, given as RFC 3339 times or dates, and
+// paged with limit and before.
+func GetAuditLog(repo AuditRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := Filter{
+			Action:     query.Get("action"),
+			TargetType: query.Get("target_type"),
+			TargetID:   query.Get("target_id"),
+			RequestID:  query.Get("request_id"),
+			Limit:      defaultEntryLimit,
+		}
+
+		var err error
+		if v := query.Get("actor_id"); v != "" {
+			actorID, err := strconv.Atoi(v)
+			if err != nil {
+				utils.ErrorJSON(w, fmt.Errorf("actor_id must be a user ID"), http.StatusBadRequest)
+				return
+			}
+			filter.ActorID = &actorID
+		}
+		if v := query.Get("since"); v != "" {
+			filter.Since, err = parseTime(v)
+			if err != nil {
+				utils.ErrorJSON(w, fmt.Errorf("since: %w", err), http.StatusBadRequest)
+				return
+			}
+		}
+		if v := query.Get("until"); v != "" {
+			filter.Until, err = parseTime(v)
+			if err != nil {
+				utils.ErrorJSON(w, fmt.Errorf("until: %w", err), http.StatusBadRequest)
+				return
+			}
+		}
+		if v := query.Get("before"); v != "" {
+			filter.Before, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || filter.Before < 1 {
+				utils.ErrorJSON(w, fmt.Errorf("before must be an entry ID"), http.StatusBadRequest)
+				return
+			}
+		}
+		if v := query.Get("limit"); v != "" {
+			filter.Limit, err = strconv.Atoi(v)
+			if err != nil || filter.Limit < 1 || filter.Limit > maxEntryLimit {
+				utils.ErrorJSON(w, fmt.Errorf("limit must be between 1 and %d", maxEntryLimit), http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		entries, err := repo.GetEntries(ctx, filter)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		page := AuditPage{Entries: entries}
+		if len(entries) == filter.Limit {
+			page.NextBefore = &entries[len(entries)-1].ID
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, page, "audit")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// parseTime accepts an RFC 3339 time or a date, which means its midnight in
+// UTC.
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 time or a date", v)
+	}
+	return t, nil
+}
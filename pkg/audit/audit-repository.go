@@ -0,0 +1,146 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+// Filter narrows GetEntries. Zero fields match everything. Entries come
+// newest first; Before, when set, starts the page below that entry ID.
+type Filter struct {
+	ActorID    *int
+	Action     string
+	TargetType string
+	TargetID   string
+	RequestID  string
+	Since      time.Time
+	Until      time.Time
+	Before     int64
+	Limit      int
+}
+
+var _ AuditRepository = &SQLAuditRepository{}
+
+// AuditRepository can only add and read entries; the table's triggers
+// reject updates and deletes from anywhere else too.
+type AuditRepository interface {
+	InsertEntry(ctx context.Context, entry models.AuditEntry) error
+	GetEntries(ctx context.Context, filter Filter) ([]*models.AuditEntry, error)
+}
+
+type SQLAuditRepository struct {
+	db *sql.DB
+}
+
+func NewSQLAuditRepository(db *sql.DB) *SQLAuditRepository {
+	return &SQLAuditRepository{db: db}
+}
+
+func (repo *SQLAuditRepository) InsertEntry(ctx context.Context, entry models.AuditEntry) error {
+	stmt := `
+		insert into audit_log (workspace_id, actor_id, actor_name, action, target_type, target_id, request_id, ip, status, summary, created_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := repo.db.ExecContext(ctx, stmt,
+		workspace.FromContext(ctx),
+		entry.ActorID,
+		entry.ActorName,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.RequestID,
+		entry.IP,
+		entry.Status,
+		entry.Summary,
+		entry.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetEntries returns the workspace's entries that match the filter, newest
+// first.
+func (repo *SQLAuditRepository) GetEntries(ctx context.Context, filter Filter) ([]*models.AuditEntry, error) {
+	where := []string{"workspace_id = ?"}
+	args := []interface{}{workspace.FromContext(ctx)}
+
+	if filter.ActorID != nil {
+		where = append(where, "actor_id = ?")
+		args = append(args, *filter.ActorID)
+	}
+	if filter.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.TargetType != "" {
+		where = append(where, "target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		where = append(where, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if filter.RequestID != "" {
+		where = append(where, "request_id = ?")
+		args = append(args, filter.RequestID)
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, filter.Until)
+	}
+	if filter.Before != 0 {
+		where = append(where, "id < ?")
+		args = append(args, filter.Before)
+	}
+
+	query := `
+		select id, actor_id, actor_name, action, target_type, target_id, request_id, ip, status, coalesce(summary, ''), created_at
+		from audit_log
+		where ` + strings.Join(where, " and ") + `
+		order by id desc
+		limit ?
+	`
+	args = append(args, filter.Limit)
+
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.ActorName,
+			&entry.Action,
+			&entry.TargetType,
+			&entry.TargetID,
+			&entry.RequestID,
+			&entry.IP,
+			&entry.Status,
+			&entry.Summary,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,257 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/middleware"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+const (
+	// maxCapture is how much of a request body is kept for its summary.
+	maxCapture = 16 << 10
+	// maxSummary is the longest summary stored; longer ones are cut short.
+	maxSummary = 1024
+	// maxTargetID is the longest target ID stored, as for long bulk deletes.
+	maxTargetID = 255
+)
+
+// Recorder appends entries to the audit log.
+type Recorder struct {
+	repo AuditRepository
+}
+
+func NewRecorder(repo AuditRepository) *Recorder {
+	return &Recorder{repo: repo}
+}
+
+// Record appends the entry with the request's ID, client IP and, unless the
+// entry names another, workspace. The actor is the authenticated caller
+// unless the entry already names one, as for a login. A failure to write is
+// logged rather than returned because the request has usually been answered
+// by then.
+func (rec *Recorder) Record(r *http.Request, entry models.AuditEntry) {
+	if entry.ActorID == nil {
+		if td, ok := middleware.UserFromContext(r.Context()); ok {
+			actorID := td.ID
+			entry.ActorID = &actorID
+			entry.ActorName = td.Username
+		}
+	}
+	entry.RequestID = middleware.RequestIDFromContext(r.Context())
+	entry.IP = clientIP(r)
+	entry.CreatedAt = time.Now()
+
+	workspaceID := entry.WorkspaceID
+	if workspaceID == 0 {
+		workspaceID = workspace.FromContext(r.Context())
+	}
+
+	// The request's context may be cancelled once its handler returns, so
+	// the entry is written with a fresh one.
+	ctx, cancel := context.WithTimeout(workspace.NewContext(context.Background(), workspaceID), 3*time.Second)
+	defer cancel()
+
+	err := rec.repo.InsertEntry(ctx, entry)
+	if err != nil {
+		log.Printf("audit: recording %s for request %s: %v", entry.Action, entry.RequestID, err)
+	}
+}
+
+// Log records every call of next as action on targetType, along with the
+// response status, so wrap it around RequireRole to record refused attempts
+// too. The target is the id route variable, else the id of a JSON object
+// body, else the IDs in a JSON array body as sent to the bulk deletes. An
+// empty targetType is taken from the type route variable.
+func (rec *Recorder) Log(action, targetType string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entityType := targetType
+		if entityType == "" {
+			entityType = mux.Vars(r)["type"]
+		}
+
+		body := &capture{ReadCloser: r.Body}
+		r.Body = body
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next(sw, r)
+
+		rec.Record(r, models.AuditEntry{
+			Action:     action,
+			TargetType: entityType,
+			TargetID:   targetID(r, body),
+			Status:     sw.status,
+			Summary:    summarize(r, body),
+		})
+	}
+}
+
+// capture keeps the first maxCapture bytes the handler reads from a request
+// body and counts the rest.
+type capture struct {
+	io.ReadCloser
+	buf bytes.Buffer
+	n   int
+}
+
+func (c *capture) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if room := maxCapture - c.buf.Len(); room > 0 {
+		if room > n {
+			room = n
+		}
+		c.buf.Write(p[:room])
+	}
+	c.n += n
+	return n, err
+}
+
+// complete reports whether the whole body was captured.
+func (c *capture) complete() bool {
+	return c.n <= maxCapture
+}
+
+// statusWriter remembers the status a handler responded with.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func targetID(r *http.Request, body *capture) string {
+	if id := mux.Vars(r)["id"]; id != "" {
+		return id
+	}
+	if !body.complete() {
+		return ""
+	}
+
+	var object struct {
+		ID json.Number `json:"id"`
+	}
+	if json.Unmarshal(body.buf.Bytes(), &object) == nil && object.ID != "" && object.ID != "0" {
+		return object.ID.String()
+	}
+
+	var ids []json.Number
+	if json.Unmarshal(body.buf.Bytes(), &ids) == nil && len(ids) > 0 {
+		list := make([]string, len(ids))
+		for i, id := range ids {
+			list[i] = id.String()
+		}
+		return truncate(strings.Join(list, ","), maxTargetID)
+	}
+
+	return ""
+}
+
+// summarize returns a JSON body with its secrets redacted, or the size and
+// type of any other body, such as an uploaded file.
+func summarize(r *http.Request, body *capture) string {
+	if body.n == 0 {
+		return ""
+	}
+
+	if body.complete() {
+		var v interface{}
+		if json.Unmarshal(body.buf.Bytes(), &v) == nil {
+			b, err := json.Marshal(redact(v))
+			if err == nil {
+				return truncate(string(b), maxSummary)
+			}
+		}
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		contentType = "unknown type"
+	}
+	return fmt.Sprintf("%d bytes of %s", body.n, contentType)
+}
+
+// redact replaces the values of passwords, secrets, tokens and invite codes
+// anywhere in a decoded JSON value.
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSecret(key) {
+				v[key] = "[redacted]"
+			} else {
+				v[key] = redact(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redact(v[i])
+		}
+	}
+	return v
+}
+
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	return key == "code" ||
+		strings.Contains(key, "password") ||
+		strings.Contains(key, "secret") ||
+		strings.Contains(key, "token")
+}
+
+// truncate cuts s to at most max bytes without splitting a character, marking
+// the cut with an ellipsis.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+
+	const ellipsis = "…"
+	n := max - len(ellipsis)
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + ellipsis
+}
+
+// clientIP returns the address the request came from. Behind a proxy that is
+// the last address in X-Forwarded-For, the one the proxy itself added;
+// earlier ones are whatever the client claimed.
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(forwarded[len(forwarded)-1], ",")
+		if ip := strings.TrimSpace(hops[len(hops)-1]); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -2,18 +2,27 @@ package auth
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/ngfenglong/food-randomizer-BE/pkg/config"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
 )
 
-func Login(repo AuthRepository) http.HandlerFunc {
+// AuditRecorder records auth events in the audit log. It is satisfied by
+// *audit.Recorder.
+type AuditRecorder interface {
+	Record(r *http.Request, entry models.AuditEntry)
+}
+
+func Login(repo AuthRepository, signer *Signer, recorder AuditRecorder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var loginCredential LoginDto
 		err := json.NewDecoder(r.Body).Decode(&loginCredential)
@@ -25,46 +34,64 @@ func Login(repo AuthRepository) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 		defer cancel()
 
+		failed := models.AuditEntry{
+			Action:     models.AuditLoginFailed,
+			TargetType: "user",
+			TargetID:   loginCredential.Email,
+			Status:     http.StatusUnauthorized,
+		}
+
 		user, err := repo.GetUserByEmail(ctx, loginCredential.Email)
 		if err != nil {
+			failed.Summary = "unknown email"
+			recorder.Record(r, failed)
 			utils.ErrorJSON(w, errors.New("invalid login crendential"), http.StatusUnauthorized)
 			return
 		}
 
 		validPassword, err := utils.PasswordMatches(user.Password, loginCredential.Password)
 		if !validPassword || err != nil {
+			failed.ActorID = &user.ID
+			failed.ActorName = user.UserName
+			failed.Summary = "wrong password"
+			recorder.Record(r, failed)
 			utils.ErrorJSON(w, errors.New("invalid login crendential"), http.StatusUnauthorized)
 			return
 		}
 
 		tokenDetail := &TokenDetail{
-			ID:       user.ID,
-			Email:    user.Email,
-			Username: user.UserName,
-		}
-		accessToken, accessExpiry, err := GenerateAccessToken(tokenDetail)
-		if err != nil {
-			utils.ErrorJSON(w, err, http.StatusInternalServerError)
-			return
+			ID:          user.ID,
+			Email:       user.Email,
+			Username:    user.UserName,
+			Role:        models.RoleUser,
+			WorkspaceID: models.DefaultWorkspaceID,
+			SystemAdmin: user.Role == models.RoleAdmin,
 		}
 
-		refreshToken, refreshExpiry, err := GenerateRefreshToken(tokenDetail)
-		if err != nil {
+		member, err := repo.GetActiveMembership(ctx, user.ID)
+		if err == nil {
+			tokenDetail.Role = member.Role
+			tokenDetail.WorkspaceID = member.WorkspaceID
+		} else if !errors.Is(err, sql.ErrNoRows) {
 			utils.ErrorJSON(w, err, http.StatusInternalServerError)
 			return
 		}
 
-		err = repo.InsertToken(ctx, user.ID, refreshToken, refreshExpiry)
+		payload, err := IssueTokens(ctx, repo, signer, tokenDetail)
 		if err != nil {
 			utils.ErrorJSON(w, err, http.StatusInternalServerError)
 			return
 		}
 
-		var payload LoginResponseDto
-		payload.AccessToken = accessToken
-		payload.RefreshToken = refreshToken
-		payload.Expiry = accessExpiry
-		payload.UserName = user.UserName
+		recorder.Record(r, models.AuditEntry{
+			WorkspaceID: tokenDetail.WorkspaceID,
+			ActorID:     &user.ID,
+			ActorName:   user.UserName,
+			Action:      models.AuditLogin,
+			TargetType:  "user",
+			TargetID:    strconv.Itoa(user.ID),
+			Status:      http.StatusOK,
+		})
 
 		err = utils.WriteJSON(w, http.StatusOK, payload, "data")
 		if err != nil {
@@ -74,7 +101,34 @@ func Login(repo AuthRepository) http.HandlerFunc {
 	}
 }
 
-func Logout(repo AuthRepository) http.HandlerFunc {
+// IssueTokens signs a new access and refresh token pair for the details and
+// stores the refresh token.
+func IssueTokens(ctx context.Context, repo AuthRepository, signer *Signer, td *TokenDetail) (*LoginResponseDto, error) {
+	accessToken, accessExpiry, err := signer.GenerateAccessToken(td)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshExpiry, err := signer.GenerateRefreshToken(td)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.InsertToken(ctx, td.ID, refreshToken, refreshExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponseDto{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Expiry:       accessExpiry,
+		UserName:     td.Username,
+		WorkspaceID:  td.WorkspaceID,
+	}, nil
+}
+
+func Logout(repo AuthRepository, recorder AuditRecorder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var logoutRequestDto struct {
 			RefreshToken string `json:"refresh_token"`
@@ -96,6 +150,12 @@ func Logout(repo AuthRepository) http.HandlerFunc {
 			return
 		}
 
+		recorder.Record(r, models.AuditEntry{
+			Action:     models.AuditLogout,
+			TargetType: "user",
+			Status:     http.StatusOK,
+		})
+
 		var payload struct {
 			Error   bool   `json:"error"`
 			Message string `json:"message"`
@@ -115,7 +175,7 @@ func ForgetPassword(repo AuthRepository) http.HandlerFunc {
 	}
 }
 
-func Register(repo AuthRepository, cfg *config.Config) http.HandlerFunc {
+func Register(repo AuthRepository, cfg *config.Config, recorder AuditRecorder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var registerInput RegisterUserDto
 		err := json.NewDecoder(r.Body).Decode(&registerInput)
@@ -124,13 +184,23 @@ func Register(repo AuthRepository, cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
+		entry := models.AuditEntry{
+			Action:     models.AuditRegister,
+			TargetType: "user",
+			TargetID:   registerInput.Email,
+			Summary:    registerInput.Username,
+		}
+
 		if registerInput.SecretCode != cfg.SecretCode {
+			entry.Status = http.StatusBadRequest
+			entry.Summary = "invalid secret code"
+			recorder.Record(r, entry)
 			utils.ErrorJSON(w, errors.New("insert a valid secret code"))
 			return
 		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		cancel()
+		defer cancel()
 
 		usernameExists, emailExists, err := repo.CheckIfUserExists(ctx, registerInput)
 		if err != nil {
@@ -162,6 +232,9 @@ func Register(repo AuthRepository, cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
+		entry.Status = http.StatusOK
+		recorder.Record(r, entry)
+
 		var payload struct {
 			Error   bool   `json:"error"`
 			Message string `json:"message"`
@@ -16,6 +16,7 @@ type AuthRepository interface {
 	DeleteToken(ctx context.Context, refreshToken string) error
 	IsAdminRequestPending(ctx context.Context, ar AdminRequestDto) (bool, error)
 	RegisterRequest(ctx context.Context, teleId, teleUsername string) error
+	GetActiveMembership(ctx context.Context, userID int) (*models.WorkspaceMember, error)
 }
 
 type SQLAuthRepository struct {
@@ -43,6 +44,7 @@ type LoginResponseDto struct {
 	RefreshToken string    `json:"refresh_token"`
 	Expiry       time.Time `json:"expiry"`
 	UserName     string    `json:"username"`
+	WorkspaceID  int       `json:"workspace_id"`
 }
 
 type AdminRequestDto struct {
@@ -72,16 +74,60 @@ func (repo *SQLAuthRepository) GetUserByEmail(ctx context.Context, email string)
 	return &u, nil
 }
 
+// RegisterUser creates the user as a member of the default workspace.
 func (repo *SQLAuthRepository) RegisterUser(ctx context.Context, r RegisterUserDto) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	stmt := `
 		Insert into user (username, email, password, role) value (?, ?, ?, ?)
 	`
-	_, err := repo.db.ExecContext(ctx, stmt, r.Username, r.Email, r.Password, 0)
+	result, err := tx.ExecContext(ctx, stmt, r.Username, r.Email, r.Password, 0)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `insert into workspace_member (workspace_id, user_id, role) values (?, ?, ?)`, models.DefaultWorkspaceID, id, models.RoleUser)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetActiveMembership returns the membership a user works in after logging
+// in: the workspace they last switched to if they still belong to it,
+// otherwise their oldest workspace. It returns sql.ErrNoRows for users
+// without any workspace.
+func (repo *SQLAuthRepository) GetActiveMembership(ctx context.Context, userID int) (*models.WorkspaceMember, error) {
+	query := `
+		select m.workspace_id, m.user_id, m.role, m.created_at
+		from workspace_member m
+		join user u on u.id = m.user_id
+		where m.user_id = ?
+		order by m.workspace_id = coalesce(u.workspace_id, 0) desc, m.created_at, m.workspace_id
+		limit 1
+	`
+	var m models.WorkspaceMember
+	err := repo.db.QueryRowContext(ctx, query, userID).Scan(
+		&m.WorkspaceID,
+		&m.UserID,
+		&m.Role,
+		&m.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
 }
 
 func (repo *SQLAuthRepository) InsertToken(ctx context.Context, userId int, refreshToken string, expiresAt time.Time) error {
@@ -1,31 +1,57 @@
 package auth
 
 import (
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
-	"github.com/spf13/viper"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/config"
 )
 
-var accessTokenSecret = viper.GetString("JTW_ACCESS_SECRET")
-var refreshTokenSecret = viper.GetString("JTW_REFRESH_SECRET")
+// Signer signs and verifies tokens with the secrets from the configuration.
+type Signer struct {
+	accessSecret  []byte
+	refreshSecret []byte
+}
+
+// NewSigner returns a Signer for the configured secrets. It fails when either
+// is missing, since anyone could then sign their own tokens.
+func NewSigner(cfg config.JWTConfig) (*Signer, error) {
+	if cfg.Secret == "" {
+		return nil, errors.New("JWT_ACCESS_SECRET must be set")
+	}
+	if cfg.RefreshSecret == "" {
+		return nil, errors.New("JWT_REFRESH_SECRET must be set")
+	}
+	return &Signer{accessSecret: []byte(cfg.Secret), refreshSecret: []byte(cfg.RefreshSecret)}, nil
+}
 
+// TokenDetail is what an access token says about its holder. Role is the
+// holder's role in WorkspaceID, the workspace they are working in.
+// SystemAdmin is set for admins of the whole instance, who manage the data
+// shared by every workspace.
 type TokenDetail struct {
-	ID       int
-	Email    string
-	Username string
+	ID          int
+	Email       string
+	Username    string
+	Role        int
+	WorkspaceID int
+	SystemAdmin bool
 }
 
-func GenerateAccessToken(td *TokenDetail) (string, time.Time, error) {
+func (s *Signer) GenerateAccessToken(td *TokenDetail) (string, time.Time, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 	expiry := time.Now().Add(time.Hour * 24 * 3)
 	claims := token.Claims.(jwt.MapClaims)
 	claims["ID"] = td.ID
 	claims["Email"] = td.Email
 	claims["Username"] = td.Username
-	claims["exp"] = expiry
+	claims["Role"] = td.Role
+	claims["WorkspaceID"] = td.WorkspaceID
+	claims["SystemAdmin"] = td.SystemAdmin
+	claims["exp"] = expiry.Unix()
 
-	signedToken, err := token.SignedString([]byte(accessTokenSecret))
+	signedToken, err := token.SignedString(s.accessSecret)
 	if err != nil {
 		return "", expiry, err
 	}
@@ -33,18 +59,55 @@ func GenerateAccessToken(td *TokenDetail) (string, time.Time, error) {
 	return signedToken, expiry, nil
 }
 
-func GenerateRefreshToken(td *TokenDetail) (string, time.Time, error) {
+func (s *Signer) GenerateRefreshToken(td *TokenDetail) (string, time.Time, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 	expiry := time.Now().Add(time.Hour * 24 * 7)
 
 	claims := token.Claims.(jwt.MapClaims)
 	claims["ID"] = td.ID
-	claims["exp"] = expiry
+	claims["exp"] = expiry.Unix()
 
-	signedToken, err := token.SignedString([]byte(refreshTokenSecret))
+	signedToken, err := token.SignedString(s.refreshSecret)
 	if err != nil {
 		return "", expiry, err
 	}
 
 	return signedToken, expiry, nil
 }
+
+// ParseAccessToken verifies a token issued by GenerateAccessToken and returns
+// the details it carries.
+func (s *Signer) ParseAccessToken(signedToken string) (*TokenDetail, error) {
+	token, err := jwt.Parse(signedToken, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return s.accessSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	id, ok := claims["ID"].(float64)
+	if !ok {
+		return nil, errors.New("invalid token subject")
+	}
+
+	td := &TokenDetail{ID: int(id)}
+	td.Email, _ = claims["Email"].(string)
+	td.Username, _ = claims["Username"].(string)
+	if role, ok := claims["Role"].(float64); ok {
+		td.Role = int(role)
+	}
+	if workspaceID, ok := claims["WorkspaceID"].(float64); ok {
+		td.WorkspaceID = int(workspaceID)
+	}
+	td.SystemAdmin, _ = claims["SystemAdmin"].(bool)
+
+	return td, nil
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/config"
+)
+
+func TestNewSignerRequiresSecrets(t *testing.T) {
+	for _, cfg := range []config.JWTConfig{
+		{},
+		{Secret: "access"},
+		{RefreshSecret: "refresh"},
+	} {
+		if _, err := NewSigner(cfg); err == nil {
+			t.Errorf("NewSigner(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
+
+func TestParseAccessToken(t *testing.T) {
+	signer, err := NewSigner(config.JWTConfig{Secret: "access secret", RefreshSecret: "refresh secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	td := &TokenDetail{ID: 7, Email: "a@example.com", Username: "alice", Role: 2, WorkspaceID: 3, SystemAdmin: true}
+	token, _, err := signer.GenerateAccessToken(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := signer.ParseAccessToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *td {
+		t.Errorf("ParseAccessToken = %+v, want %+v", *got, *td)
+	}
+
+	forge := func(key string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"ID": 1, "Role": 2, "WorkspaceID": 3})
+		signed, err := token.SignedString([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	refresh, _, err := signer.GenerateRefreshToken(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{
+		"empty key":     forge(""),
+		"other key":     forge("guess"),
+		"refresh token": refresh,
+		"garbage":       "not a token",
+	} {
+		if _, err := signer.ParseAccessToken(token); err == nil {
+			t.Errorf("%s: ParseAccessToken succeeded, want an error", name)
+		}
+	}
+}
@@ -8,8 +8,9 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/julienschmidt/httprouter"
+	"github.com/gorilla/mux"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/revision"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
 )
 
@@ -20,13 +21,13 @@ type CategoryDto struct {
 
 func GetCategoryByID(repo CategoryRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params := httprouter.ParamsFromContext(r.Context())
-		id, err := strconv.Atoi(params.ByName("id"))
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
 		if err != nil {
 			utils.ErrorJSON(w, err)
+			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 
 		category, err := repo.GetCategoryByID(ctx, id)
@@ -44,7 +45,7 @@ func GetCategoryByID(repo CategoryRepository) http.HandlerFunc {
 
 func GetAllCategories(repo CategoryRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 
 		categories, err := repo.GetAllCategories(ctx)
@@ -71,7 +72,7 @@ func EditCategory(repo CategoryRepository) http.HandlerFunc {
 			utils.ErrorJSON(w, err)
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 
 		var category models.Category
@@ -113,13 +114,12 @@ func EditCategory(repo CategoryRepository) http.HandlerFunc {
 
 func DeleteCategory(repo CategoryRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params := httprouter.ParamsFromContext(r.Context())
-		id, err := strconv.Atoi(params.ByName("id"))
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
 		if err != nil {
 			utils.ErrorJSON(w, err)
 			return
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 
 		err = repo.DeleteCategory(ctx, id)
@@ -150,7 +150,7 @@ func DeleteCategories(repo CategoryRepository) http.HandlerFunc {
 			utils.ErrorJSON(w, errors.New("the ID list is empty"))
 			return
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 
 		err = repo.DeleteCategories(ctx, idList)
@@ -166,3 +166,32 @@ func DeleteCategories(repo CategoryRepository) http.HandlerFunc {
 		}
 	}
 }
+
+// RevertCategory restores the category to how it was after the revision in
+// the URL, recording the restore as a new revision.
+func RevertCategory(repo CategoryRepository, revisionRepo revision.RevisionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		var category models.Category
+		id, revisionID, ok := revision.ReadRevert(ctx, w, r, revisionRepo, models.EntityCategory, &category)
+		if !ok {
+			return
+		}
+
+		category.ID = id
+		category.UpdatedAt = time.Now()
+		err := repo.RestoreCategory(ctx, category, revisionID)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, nil, "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
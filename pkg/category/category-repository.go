@@ -5,10 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"strconv"
-	"strings"
+	"time"
 
 	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/revision"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
 )
 
 var _ CategoryRepository = &SQLCategoryRepository{}
@@ -20,6 +21,10 @@ type CategoryRepository interface {
 	UpdateCategory(ctx context.Context, category models.Category) error
 	DeleteCategory(ctx context.Context, id int) error
 	DeleteCategories(ctx context.Context, idList []int) error
+	RestoreCategory(ctx context.Context, category models.Category, revisionID int) error
+	GetDeletedCategories(ctx context.Context) ([]*models.TrashItem, error)
+	UndeleteCategory(ctx context.Context, id int) error
+	PurgeCategories(ctx context.Context, before time.Time) (int, error)
 }
 
 type SQLCategoryRepository struct {
@@ -31,9 +36,20 @@ func NewSQLCategoryRepostory(db *sql.DB) *SQLCategoryRepository {
 }
 
 func (repo *SQLCategoryRepository) GetCategoryByID(ctx context.Context, id int) (*models.Category, error) {
-	query := `select id, category_name, created_at, updated_at from category where id = ?`
+	return loadCategory(ctx, repo.db, id, false)
+}
+
+// loadCategory reads a category that is not in the trash, locking it until
+// the end of q's transaction when lock is set.
+func loadCategory(ctx context.Context, q interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, id int, lock bool) (*models.Category, error) {
+	query := `select id, category_name, created_at, updated_at from category where id = ? and workspace_id = ? and deleted_at is null`
+	if lock {
+		query += ` for update`
+	}
 
-	row := repo.db.QueryRowContext(ctx, query, id)
+	row := q.QueryRowContext(ctx, query, id, workspace.FromContext(ctx))
 	var category models.Category
 	err := row.Scan(
 		&category.ID,
@@ -49,8 +65,8 @@ func (repo *SQLCategoryRepository) GetCategoryByID(ctx context.Context, id int)
 }
 
 func (repo *SQLCategoryRepository) GetAllCategories(ctx context.Context) ([]*models.Category, error) {
-	query := fmt.Sprintf(`select id, category_name, created_at, updated_at from category`)
-	rows, err := repo.db.QueryContext(ctx, query)
+	query := fmt.Sprintf(`select id, category_name, created_at, updated_at from category where workspace_id = ? and deleted_at is null`)
+	rows, err := repo.db.QueryContext(ctx, query, workspace.FromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -77,59 +93,186 @@ func (repo *SQLCategoryRepository) GetAllCategories(ctx context.Context) ([]*mod
 
 func (repo *SQLCategoryRepository) InsertCategory(ctx context.Context, category models.Category) error {
 	stmt := `
-		insert into category (category_name) value (?)
+		insert into category (category_name, workspace_id) value (?, ?)
 	`
 
-	_, err := repo.db.ExecContext(ctx, stmt, category.CategoryName)
-	if err != nil {
-		return err
-	}
+	return repo.write(ctx, func(tx *sql.Tx) (revision.Change, error) {
+		result, err := tx.ExecContext(ctx, stmt, category.CategoryName, workspace.FromContext(ctx))
+		if err != nil {
+			return revision.Change{}, err
+		}
 
-	return nil
+		id, err := result.LastInsertId()
+		return revision.Change{EntityID: int(id), Action: models.RevisionCreate}, err
+	})
 }
 
 func (repo *SQLCategoryRepository) UpdateCategory(ctx context.Context, category models.Category) error {
 	stmt := `
-		Update category set category_name = ? where id = ?
+		Update category set category_name = ? where id = ? and workspace_id = ?
 	`
 
-	_, err := repo.db.ExecContext(ctx, stmt, category.CategoryName, category.ID)
+	return repo.write(ctx, func(tx *sql.Tx) (revision.Change, error) {
+		before, err := loadCategory(ctx, tx, category.ID, true)
+		if err != nil {
+			return revision.Change{}, err
+		}
+
+		_, err = tx.ExecContext(ctx, stmt, category.CategoryName, category.ID, workspace.FromContext(ctx))
+		return revision.Change{EntityID: category.ID, Action: models.RevisionUpdate, Before: before}, err
+	})
+}
+
+// RestoreCategory writes the category back as it was in a snapshot taken
+// from revisionID, taking it out of the trash or re-creating it under the
+// same ID if it has been deleted.
+func (repo *SQLCategoryRepository) RestoreCategory(ctx context.Context, category models.Category, revisionID int) error {
+	return repo.write(ctx, func(tx *sql.Tx) (revision.Change, error) {
+		change := revision.Change{EntityID: category.ID, Action: models.RevisionRevert, RevertOf: &revisionID}
+
+		before, err := loadCategory(ctx, tx, category.ID, true)
+		if errors.Is(err, sql.ErrNoRows) {
+			stmt := `
+				insert into category (id, category_name, workspace_id) value (?, ?, ?)
+				on duplicate key update category_name = values(category_name), deleted_at = null
+			`
+			_, err = tx.ExecContext(ctx, stmt, category.ID, category.CategoryName, workspace.FromContext(ctx))
+			return change, err
+		}
+		if err != nil {
+			return change, err
+		}
+
+		change.Before = before
+		_, err = tx.ExecContext(ctx, `update category set category_name = ? where id = ? and workspace_id = ?`, category.CategoryName, category.ID, workspace.FromContext(ctx))
+		return change, err
+	})
+}
+
+func (repo *SQLCategoryRepository) DeleteCategory(ctx context.Context, id int) error {
+	return repo.DeleteCategories(ctx, []int{id})
+}
+
+// DeleteCategories moves the categories to the trash, recording each one's
+// last state. IDs that are not categories in the workspace, or are already
+// in the trash, are skipped.
+func (repo *SQLCategoryRepository) DeleteCategories(ctx context.Context, idList []int) error {
+	if len(idList) == 0 {
+		return errors.New("the list is empty")
+	}
+
+	tx, err := repo.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	for _, id := range idList {
+		before, err := loadCategory(ctx, tx, id, true)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "Update category set deleted_at = ? where id = ? and workspace_id = ?", time.Now(), id, workspace.FromContext(ctx))
+		if err != nil {
+			return err
+		}
+
+		err = revision.Record(ctx, tx, revision.Change{
+			EntityType: models.EntityCategory,
+			EntityID:   id,
+			Action:     models.RevisionDelete,
+			Before:     before,
+		})
+		if err != nil {
+			return err
+		}
+	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (repo *SQLCategoryRepository) DeleteCategory(ctx context.Context, id int) error {
-	stmt := "Delete from category where id = ?"
+// GetDeletedCategories lists the categories in the trash, most recently
+// deleted first.
+func (repo *SQLCategoryRepository) GetDeletedCategories(ctx context.Context) ([]*models.TrashItem, error) {
+	query := `select id, category_name, deleted_at from category where workspace_id = ? and deleted_at is not null order by deleted_at desc, id`
+	rows, err := repo.db.QueryContext(ctx, query, workspace.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var items []*models.TrashItem
+	for rows.Next() {
+		item := models.TrashItem{EntityType: models.EntityCategory}
+		err := rows.Scan(&item.ID, &item.Name, &item.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+// UndeleteCategory takes the category out of the trash. It returns
+// sql.ErrNoRows if the category is not in the trash.
+func (repo *SQLCategoryRepository) UndeleteCategory(ctx context.Context, id int) error {
+	return repo.write(ctx, func(tx *sql.Tx) (revision.Change, error) {
+		change := revision.Change{EntityID: id, Action: models.RevisionRestore}
 
-	_, err := repo.db.ExecContext(ctx, stmt, id)
+		result, err := tx.ExecContext(ctx, `update category set deleted_at = null where id = ? and workspace_id = ? and deleted_at is not null`, id, workspace.FromContext(ctx))
+		if err != nil {
+			return change, err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return change, err
+		} else if n == 0 {
+			return change, sql.ErrNoRows
+		}
+		return change, nil
+	})
+}
+
+// PurgeCategories permanently removes the categories that were moved to the
+// trash before the given time and returns how many there were.
+func (repo *SQLCategoryRepository) PurgeCategories(ctx context.Context, before time.Time) (int, error) {
+	result, err := repo.db.ExecContext(ctx, `delete from category where workspace_id = ? and deleted_at < ?`, workspace.FromContext(ctx), before)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	n, err := result.RowsAffected()
+	return int(n), err
 }
 
-func (repo *SQLCategoryRepository) DeleteCategories(ctx context.Context, idList []int) error {
-	if len(idList) == 0 {
-		return errors.New("the list is empty")
+// write runs fn in a transaction and records the change it reports, with the
+// category as stored afterwards.
+func (repo *SQLCategoryRepository) write(ctx context.Context, fn func(tx *sql.Tx) (revision.Change, error)) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	change, err := fn(tx)
+	if err != nil {
+		return err
 	}
 
-	var sb strings.Builder
-	sb.WriteString("Delete from category where id in (")
-	sb.WriteString(strconv.Itoa(idList[0]))
-	for i := 1; i < len(idList); i++ {
-		sb.WriteString(",")
-		sb.WriteString(strconv.Itoa(idList[i]))
+	change.EntityType = models.EntityCategory
+	change.After, err = loadCategory(ctx, tx, change.EntityID, false)
+	if err != nil {
+		return err
 	}
-	sb.WriteString(")")
 
-	_, err := repo.db.ExecContext(ctx, sb.String())
+	err = revision.Record(ctx, tx, change)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
@@ -8,6 +8,10 @@ type Config struct {
 	Server     ServerConfig
 	Database   DatabaseConfig
 	JWT        JWTConfig
+	Schedule   ScheduleConfig
+	Slack      SlackConfig
+	Discord    DiscordConfig
+	Trash      TrashConfig
 	SecretCode string
 	Env        string
 }
@@ -19,7 +23,20 @@ type DatabaseConfig struct {
 	DSN string
 }
 type JWTConfig struct {
-	Secret string
+	Secret        string
+	RefreshSecret string
+}
+type ScheduleConfig struct {
+	HolidayCalendarPath string
+}
+type SlackConfig struct {
+	SigningSecret string
+}
+type DiscordConfig struct {
+	PublicKey string
+}
+type TrashConfig struct {
+	RetentionDays int
 }
 
 func LoadConfig() (*Config, error) {
@@ -31,6 +48,9 @@ func LoadConfig() (*Config, error) {
 
 	viper.AutomaticEnv()
 
+	viper.SetDefault("HOLIDAY_CALENDAR_PATH", "holidays/sg.txt")
+	viper.SetDefault("TRASH_RETENTION_DAYS", 30)
+
 	var cfg Config
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
@@ -39,6 +59,11 @@ func LoadConfig() (*Config, error) {
 	cfg.Server.Port = viper.GetInt("PORT")
 	cfg.Database.DSN = viper.GetString("DB_CONNECTIONSTRING")
 	cfg.JWT.Secret = viper.GetString("JWT_ACCESS_SECRET")
+	cfg.JWT.RefreshSecret = viper.GetString("JWT_REFRESH_SECRET")
+	cfg.Schedule.HolidayCalendarPath = viper.GetString("HOLIDAY_CALENDAR_PATH")
+	cfg.Slack.SigningSecret = viper.GetString("SLACK_SIGNING_SECRET")
+	cfg.Discord.PublicKey = viper.GetString("DISCORD_PUBLIC_KEY")
+	cfg.Trash.RetentionDays = viper.GetInt("TRASH_RETENTION_DAYS")
 	cfg.SecretCode = viper.GetString("SECRET_CODE")
 	cfg.Env = viper.GetString("ENV")
 
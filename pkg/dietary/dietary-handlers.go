@@ -0,0 +1,147 @@
+package dietary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type DietaryTagDto struct {
+	ID   int    `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+func GetAllDietaryTags(repo DietaryTagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		tags, err := repo.GetAllDietaryTags(ctx)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, tags, "dietary_tags")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func GetDietaryTagByID(repo DietaryTagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		tag, err := repo.GetDietaryTagByID(ctx, id)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, tag, "dietary_tag")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func EditDietaryTag(repo DietaryTagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload DietaryTagDto
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		if !slugPattern.MatchString(payload.Slug) {
+			utils.ErrorJSON(w, errors.New("slug must be lowercase words separated by hyphens"), http.StatusBadRequest)
+			return
+		}
+
+		if payload.Name == "" {
+			utils.ErrorJSON(w, errors.New("name is required"), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		var tag models.DietaryTag
+		if payload.ID != 0 {
+			m, err := repo.GetDietaryTagByID(ctx, payload.ID)
+			if err != nil {
+				utils.ErrorJSON(w, err)
+				return
+			}
+			tag = *m
+		}
+
+		tag.ID = payload.ID
+		tag.Slug = payload.Slug
+		tag.Name = payload.Name
+		tag.UpdatedAt = time.Now()
+
+		if tag.ID == 0 {
+			err = repo.InsertDietaryTag(ctx, tag)
+		} else {
+			err = repo.UpdateDietaryTag(ctx, tag)
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, nil, "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func DeleteDietaryTag(repo DietaryTagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		err = repo.DeleteDietaryTag(ctx, id)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, "Deleted Successfully", "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
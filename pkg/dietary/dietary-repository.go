@@ -0,0 +1,155 @@
+package dietary
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+const (
+	TagHalalCertified = "halal-certified"
+	TagMuslimOwned    = "muslim-owned"
+	TagVegetarian     = "vegetarian"
+	TagVegan          = "vegan"
+)
+
+// HalalTags and VegetarianTags back the legacy is_halal and is_vegetarian
+// flags: a place counts as halal or vegetarian if it carries any of them.
+var (
+	HalalTags      = []string{TagHalalCertified, TagMuslimOwned}
+	VegetarianTags = []string{TagVegetarian, TagVegan}
+)
+
+// UniqueSlugs returns slugs without repeats, in their first order, so that
+// the tags found for them can be counted against the slugs asked for.
+func UniqueSlugs(slugs []string) []string {
+	seen := make(map[string]bool, len(slugs))
+	unique := make([]string, 0, len(slugs))
+	for _, slug := range slugs {
+		if !seen[slug] {
+			seen[slug] = true
+			unique = append(unique, slug)
+		}
+	}
+	return unique
+}
+
+var _ DietaryTagRepository = &SQLDietaryTagRepository{}
+
+type DietaryTagRepository interface {
+	GetDietaryTagByID(ctx context.Context, id int) (*models.DietaryTag, error)
+	GetDietaryTagsBySlugs(ctx context.Context, slugs []string) ([]*models.DietaryTag, error)
+	GetAllDietaryTags(ctx context.Context) ([]*models.DietaryTag, error)
+	InsertDietaryTag(ctx context.Context, tag models.DietaryTag) error
+	UpdateDietaryTag(ctx context.Context, tag models.DietaryTag) error
+	DeleteDietaryTag(ctx context.Context, id int) error
+}
+
+type SQLDietaryTagRepository struct {
+	db *sql.DB
+}
+
+func NewSQLDietaryTagRepository(db *sql.DB) *SQLDietaryTagRepository {
+	return &SQLDietaryTagRepository{db: db}
+}
+
+func (repo *SQLDietaryTagRepository) GetDietaryTagByID(ctx context.Context, id int) (*models.DietaryTag, error) {
+	query := `select id, slug, name, created_at, updated_at from dietary_tag where id = ?`
+
+	row := repo.db.QueryRowContext(ctx, query, id)
+	var tag models.DietaryTag
+	err := row.Scan(
+		&tag.ID,
+		&tag.Slug,
+		&tag.Name,
+		&tag.CreatedAt,
+		&tag.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+func (repo *SQLDietaryTagRepository) GetDietaryTagsBySlugs(ctx context.Context, slugs []string) ([]*models.DietaryTag, error) {
+	if len(slugs) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(slugs))
+	for i, slug := range slugs {
+		args[i] = slug
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(slugs)), ",")
+	query := `select id, slug, name, created_at, updated_at from dietary_tag where slug in (` + placeholders + `) order by slug`
+
+	return repo.queryDietaryTags(ctx, query, args...)
+}
+
+func (repo *SQLDietaryTagRepository) GetAllDietaryTags(ctx context.Context) ([]*models.DietaryTag, error) {
+	query := `select id, slug, name, created_at, updated_at from dietary_tag order by slug`
+	return repo.queryDietaryTags(ctx, query)
+}
+
+func (repo *SQLDietaryTagRepository) queryDietaryTags(ctx context.Context, query string, args ...interface{}) ([]*models.DietaryTag, error) {
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var tags []*models.DietaryTag
+	for rows.Next() {
+		var tag models.DietaryTag
+		err := rows.Scan(
+			&tag.ID,
+			&tag.Slug,
+			&tag.Name,
+			&tag.CreatedAt,
+			&tag.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, &tag)
+	}
+	return tags, rows.Err()
+}
+
+func (repo *SQLDietaryTagRepository) InsertDietaryTag(ctx context.Context, tag models.DietaryTag) error {
+	stmt := `insert into dietary_tag (slug, name) values (?, ?)`
+
+	_, err := repo.db.ExecContext(ctx, stmt, tag.Slug, tag.Name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *SQLDietaryTagRepository) UpdateDietaryTag(ctx context.Context, tag models.DietaryTag) error {
+	stmt := `update dietary_tag set slug = ?, name = ?, updated_at = ? where id = ?`
+
+	_, err := repo.db.ExecContext(ctx, stmt, tag.Slug, tag.Name, tag.UpdatedAt, tag.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *SQLDietaryTagRepository) DeleteDietaryTag(ctx context.Context, id int) error {
+	stmt := `delete from dietary_tag where id = ?`
+
+	_, err := repo.db.ExecContext(ctx, stmt, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
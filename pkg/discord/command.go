@@ -0,0 +1,106 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+const apiBase = "https://discord.com/api/v10"
+
+// Application command option types.
+const (
+	optionString  = 3
+	optionInteger = 4
+	optionBoolean = 5
+	optionNumber  = 10
+)
+
+type Command struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Options     []CommandOption `json:"options,omitempty"`
+}
+
+type CommandOption struct {
+	Type        int            `json:"type"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Required    bool           `json:"required,omitempty"`
+	Choices     []OptionChoice `json:"choices,omitempty"`
+	MinValue    *float64       `json:"min_value,omitempty"`
+	MaxValue    *float64       `json:"max_value,omitempty"`
+}
+
+type OptionChoice struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+// MakanCommand is the /makan definition. Option names are the generatePlace
+// query parameters they set, so the answers go through the same filter
+// parsing as the HTTP API.
+var MakanCommand = Command{
+	Name:        "makan",
+	Description: "Pick a place for lunch",
+	Options: []CommandOption{
+		{Type: optionBoolean, Name: "is_halal", Description: "Only halal places"},
+		{Type: optionBoolean, Name: "is_vegetarian", Description: "Only places with vegetarian options"},
+		{Type: optionBoolean, Name: "open_now", Description: "Only places open now"},
+		{Type: optionString, Name: "dietary", Description: "Dietary tags the place must carry, comma separated"},
+		{Type: optionString, Name: "category", Description: "Categories to choose from, comma separated"},
+		{Type: optionInteger, Name: "max_price", Description: "Most expensive price level", Choices: []OptionChoice{
+			{Name: "$", Value: 1},
+			{Name: "$$", Value: 2},
+			{Name: "$$$", Value: 3},
+			{Name: "$$$$", Value: 4},
+		}},
+		{Type: optionString, Name: "area", Description: "Service area, such as Novena"},
+		{Type: optionNumber, Name: "budget", Description: "Budget per person in SGD", MinValue: float(0)},
+		{Type: optionNumber, Name: "min_rating", Description: "Lowest average rating", MinValue: float(models.MinRating), MaxValue: float(models.MaxRating)},
+		{Type: optionInteger, Name: "exclude_recent_days", Description: "Skip places picked in the last N days", MinValue: float(1), MaxValue: float(365)},
+	},
+}
+
+// RegisterCommands overwrites the application's commands with MakanCommand.
+// With a guild ID the command is registered for that server only, which
+// takes effect immediately; global commands can take up to an hour.
+func RegisterCommands(ctx context.Context, client *http.Client, appID, guildID, botToken string) error {
+	endpoint := fmt.Sprintf("%s/applications/%s/commands", apiBase, appID)
+	if guildID != "" {
+		endpoint = fmt.Sprintf("%s/applications/%s/guilds/%s/commands", apiBase, appID, guildID)
+	}
+
+	js, err := json.Marshal([]Command{MakanCommand})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(js))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bot "+botToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("discord responded with %s: %s", resp.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/draw"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/place"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/preference"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
+)
+
+// Interaction and response types.
+const (
+	interactionPing               = 1
+	interactionApplicationCommand = 2
+
+	responsePong           = 1
+	responseChannelMessage = 4
+
+	flagEphemeral = 1 << 6
+	embedColour   = 0xF59E0B
+)
+
+type interaction struct {
+	Type int `json:"type"`
+	Data struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string      `json:"name"`
+			Value interface{} `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+type InteractionResponse struct {
+	Type int           `json:"type"`
+	Data *ResponseData `json:"data,omitempty"`
+}
+
+type ResponseData struct {
+	Content string  `json:"content,omitempty"`
+	Embeds  []Embed `json:"embeds,omitempty"`
+	Flags   int     `json:"flags,omitempty"`
+}
+
+type Embed struct {
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	Color       int          `json:"color"`
+	Fields      []EmbedField `json:"fields,omitempty"`
+	Footer      *EmbedFooter `json:"footer,omitempty"`
+}
+
+type EmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type EmbedFooter struct {
+	Text string `json:"text"`
+}
+
+// Interactions answers Discord's endpoint pings and the /makan command.
+// Problems are reported as ephemeral messages so only the caller sees them.
+func Interactions(repo place.PlaceRepository, areaRepo area.AreaRepository, prefRepo preference.PreferenceRepository, drawRepo draw.DrawRepository, hooks webhook.Publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in interaction
+		err := json.NewDecoder(r.Body).Decode(&in)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case in.Type == interactionPing:
+			writeResponse(w, InteractionResponse{Type: responsePong})
+			return
+		case in.Type != interactionApplicationCommand || in.Data.Name != MakanCommand.Name:
+			writeResponse(w, ephemeral("Sorry, I don't know that command."))
+			return
+		}
+
+		query := url.Values{}
+		for _, option := range in.Data.Options {
+			query.Set(option.Name, optionValue(option.Value))
+		}
+
+		params, err := place.ParseGenerateParams(query)
+		if err != nil {
+			writeResponse(w, ephemeral(err.Error()))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		outcome, err := place.Generate(ctx, repo, areaRepo, prefRepo, drawRepo, params, nil)
+		if errors.Is(err, area.ErrAreaNotFound) {
+			writeResponse(w, ephemeral("There is no area called "+params.Filter.Area+"."))
+			return
+		}
+		if errors.Is(err, place.ErrNoPlaceMatches) {
+			writeResponse(w, ephemeral("No place matches those filters. Try fewer of them."))
+			return
+		}
+		if err != nil {
+			log.Println("discord: generating place:", err)
+			writeResponse(w, ephemeral("Sorry, something went wrong picking a place."))
+			return
+		}
+
+		hooks.Publish(ctx, webhook.EventPickGenerated, webhook.PickEvent{
+			DrawID: outcome.DrawID,
+			Seed:   outcome.Seed,
+			Source: place.PickSourceDiscord,
+			Places: outcome.Picked,
+		})
+
+		writeResponse(w, InteractionResponse{
+			Type: responseChannelMessage,
+			Data: &ResponseData{Embeds: []Embed{placeEmbed(outcome.Picked[0], outcome.DrawID)}},
+		})
+	}
+}
+
+// optionValue formats an option value the way it would appear in a query
+// string. Discord sends numbers as JSON numbers.
+func optionValue(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func placeEmbed(p *models.Place, drawID string) Embed {
+	embed := Embed{
+		Title:       p.Name,
+		Description: p.Description,
+		Color:       embedColour,
+		Footer:      &EmbedFooter{Text: "Draw " + drawID},
+	}
+
+	if p.Location != "" {
+		embed.Fields = append(embed.Fields, EmbedField{Name: "Location", Value: p.Location, Inline: true})
+	}
+	if p.Category != "" {
+		embed.Fields = append(embed.Fields, EmbedField{Name: "Category", Value: p.Category, Inline: true})
+	}
+	if p.PriceLevel > 0 {
+		embed.Fields = append(embed.Fields, EmbedField{Name: "Price", Value: strings.Repeat("$", p.PriceLevel), Inline: true})
+	}
+	if p.RatingCount > 0 {
+		embed.Fields = append(embed.Fields, EmbedField{Name: "Rating", Value: fmt.Sprintf("★ %.1f (%d)", p.AverageRating, p.RatingCount), Inline: true})
+	}
+
+	return embed
+}
+
+func ephemeral(content string) InteractionResponse {
+	return InteractionResponse{
+		Type: responseChannelMessage,
+		Data: &ResponseData{Content: content, Flags: flagEphemeral},
+	}
+}
+
+func writeResponse(w http.ResponseWriter, resp InteractionResponse) {
+	js, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
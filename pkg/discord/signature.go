@@ -0,0 +1,72 @@
+package discord
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+const (
+	HeaderSignature = "X-Signature-Ed25519"
+	HeaderTimestamp = "X-Signature-Timestamp"
+
+	// maxClockSkew rejects replayed requests, as the Slack verifier does.
+	maxClockSkew = 5 * time.Minute
+	maxBodyBytes = 1 << 20
+)
+
+// Verify rejects requests that are not signed with the application's
+// Ed25519 key, as Discord requires of every interactions endpoint, or whose
+// timestamp is more than five minutes off. The body is restored so that next
+// can read it.
+func Verify(publicKey string, next http.HandlerFunc) http.HandlerFunc {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		key = nil
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key == nil {
+			utils.ErrorJSON(w, errors.New("the Discord integration is not configured"), http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			utils.ErrorJSON(w, errors.New("invalid request timestamp"), http.StatusUnauthorized)
+			return
+		}
+		if skew := time.Since(time.Unix(ts, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+			utils.ErrorJSON(w, errors.New("stale request timestamp"), http.StatusUnauthorized)
+			return
+		}
+
+		sig, err := hex.DecodeString(r.Header.Get(HeaderSignature))
+		if err != nil || len(sig) != ed25519.SignatureSize {
+			utils.ErrorJSON(w, errors.New("invalid request signature"), http.StatusUnauthorized)
+			return
+		}
+
+		msg := append([]byte(r.Header.Get(HeaderTimestamp)), body...)
+		if !ed25519.Verify(ed25519.PublicKey(key), msg, sig) {
+			utils.ErrorJSON(w, errors.New("invalid request signature"), http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,154 @@
+package discord
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	at := func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
+	timestamp := at(now)
+	body := []byte(`{"type":1}`)
+	sign := func(key ed25519.PrivateKey, timestamp string, body []byte) string {
+		return hex.EncodeToString(ed25519.Sign(key, append([]byte(timestamp), body...)))
+	}
+
+	tests := []struct {
+		name      string
+		publicKey string
+		timestamp string
+		signature string
+		body      []byte
+		want      int
+	}{
+		{
+			name:      "valid",
+			publicKey: hex.EncodeToString(pub),
+			timestamp: timestamp,
+			signature: sign(priv, timestamp, body),
+			body:      body,
+			want:      http.StatusOK,
+		},
+		{
+			name:      "other key",
+			publicKey: hex.EncodeToString(pub),
+			timestamp: timestamp,
+			signature: sign(otherPriv, timestamp, body),
+			body:      body,
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "tampered body",
+			publicKey: hex.EncodeToString(pub),
+			timestamp: timestamp,
+			signature: sign(priv, timestamp, body),
+			body:      []byte(`{"type":2}`),
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "other timestamp",
+			publicKey: hex.EncodeToString(pub),
+			timestamp: at(now.Add(time.Second)),
+			signature: sign(priv, timestamp, body),
+			body:      body,
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "stale timestamp",
+			publicKey: hex.EncodeToString(pub),
+			timestamp: at(now.Add(-6 * time.Minute)),
+			signature: sign(priv, at(now.Add(-6*time.Minute)), body),
+			body:      body,
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "future timestamp",
+			publicKey: hex.EncodeToString(pub),
+			timestamp: at(now.Add(6 * time.Minute)),
+			signature: sign(priv, at(now.Add(6*time.Minute)), body),
+			body:      body,
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "timestamp not a number",
+			publicKey: hex.EncodeToString(pub),
+			timestamp: "yesterday",
+			signature: sign(priv, "yesterday", body),
+			body:      body,
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "signature not hex",
+			publicKey: hex.EncodeToString(pub),
+			timestamp: timestamp,
+			signature: "not a signature",
+			body:      body,
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "missing signature",
+			publicKey: hex.EncodeToString(pub),
+			timestamp: timestamp,
+			body:      body,
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "not configured",
+			publicKey: "",
+			timestamp: timestamp,
+			signature: sign(priv, timestamp, body),
+			body:      body,
+			want:      http.StatusServiceUnavailable,
+		},
+		{
+			name:      "malformed key",
+			publicKey: "abcd",
+			timestamp: timestamp,
+			signature: sign(priv, timestamp, body),
+			body:      body,
+			want:      http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []byte
+			handler := Verify(tt.publicKey, func(w http.ResponseWriter, r *http.Request) {
+				got, _ = io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			req.Header.Set(HeaderTimestamp, tt.timestamp)
+			if tt.signature != "" {
+				req.Header.Set(HeaderSignature, tt.signature)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && !bytes.Equal(got, tt.body) {
+				t.Errorf("next read body %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package draw
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+// GetDrawByID serves a draw to anyone with its ID, whatever their workspace,
+// so that a link posted in chat shows exactly what was picked.
+func GetDrawByID(repo DrawRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		d, err := repo.GetSharedDraw(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.ErrorJSON(w, errors.New("draw does not exist"), http.StatusNotFound)
+				return
+			}
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, d, "draw")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
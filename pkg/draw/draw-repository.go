@@ -0,0 +1,144 @@
+package draw
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+var _ DrawRepository = &SQLDrawRepository{}
+
+type DrawRepository interface {
+	GetDrawByID(ctx context.Context, id string) (*models.Draw, error)
+	GetSharedDraw(ctx context.Context, id string) (*models.Draw, error)
+	InsertDraw(ctx context.Context, draw models.Draw) error
+	GetPickedPlaceIDsSince(ctx context.Context, since time.Time, userID *int) (map[int]bool, error)
+}
+
+type SQLDrawRepository struct {
+	db *sql.DB
+}
+
+func NewSQLDrawRepository(db *sql.DB) *SQLDrawRepository {
+	return &SQLDrawRepository{db: db}
+}
+
+// NewID returns a random identifier that is short enough to share in chat.
+func NewID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (repo *SQLDrawRepository) GetDrawByID(ctx context.Context, id string) (*models.Draw, error) {
+	query := `select ` + drawColumns + ` from draw where id = ? and workspace_id = ?`
+	return scanDraw(repo.db.QueryRowContext(ctx, query, id, workspace.FromContext(ctx)))
+}
+
+// GetSharedDraw finds a draw in any workspace. Draw IDs are unguessable, so
+// a share link works for whoever it is posted to, signed in or not.
+func (repo *SQLDrawRepository) GetSharedDraw(ctx context.Context, id string) (*models.Draw, error) {
+	query := `select ` + drawColumns + ` from draw where id = ?`
+	return scanDraw(repo.db.QueryRowContext(ctx, query, id))
+}
+
+const drawColumns = `id, seed, filters, candidates, picked_place_ids, user_id, created_at`
+
+func scanDraw(row *sql.Row) (*models.Draw, error) {
+	var d models.Draw
+	var filters, candidates, picked []byte
+	err := row.Scan(
+		&d.ID,
+		&d.Seed,
+		&filters,
+		&candidates,
+		&picked,
+		&d.UserID,
+		&d.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Filters = json.RawMessage(filters)
+	if err := json.Unmarshal(candidates, &d.Candidates); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(picked, &d.PickedPlaceIDs); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func (repo *SQLDrawRepository) InsertDraw(ctx context.Context, d models.Draw) error {
+	candidates, err := json.Marshal(d.Candidates)
+	if err != nil {
+		return err
+	}
+
+	picked, err := json.Marshal(d.PickedPlaceIDs)
+	if err != nil {
+		return err
+	}
+
+	stmt := `insert into draw (id, seed, filters, candidates, picked_place_ids, user_id, created_at, workspace_id) values (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = repo.db.ExecContext(ctx, stmt,
+		d.ID,
+		d.Seed,
+		[]byte(d.Filters),
+		candidates,
+		picked,
+		d.UserID,
+		d.CreatedAt,
+		workspace.FromContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetPickedPlaceIDsSince returns the places picked at or after since by the
+// user's draws, or with a nil userID by any of the workspace's draws.
+func (repo *SQLDrawRepository) GetPickedPlaceIDsSince(ctx context.Context, since time.Time, userID *int) (map[int]bool, error) {
+	query := `select picked_place_ids from draw where created_at >= ? and workspace_id = ?`
+	args := []interface{}{since, workspace.FromContext(ctx)}
+	if userID != nil {
+		query += ` and user_id = ?`
+		args = append(args, *userID)
+	}
+
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+
+		var picked []int
+		if err := json.Unmarshal(raw, &picked); err != nil {
+			return nil, err
+		}
+		for _, id := range picked {
+			ids[id] = true
+		}
+	}
+	return ids, rows.Err()
+}
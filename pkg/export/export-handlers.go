@@ -0,0 +1,267 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/category"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/location"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/place"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+// placeCSVColumns match the columns accepted by the place import, so an
+// exported places.csv can be imported again.
+var placeCSVColumns = []string{"id", "name", "description", "category", "location", "dietary_tags", "price_level", "min_spend", "max_spend", "lat", "lon", "area_id"}
+
+// Export streams the whole dataset. format=json (the default) returns one
+// document with places, categories and locations sections. format=csv and
+// format=geojson return a zip with places in that format alongside
+// categories and locations files.
+//
+// Places are written as they are read, so an error part way through can only
+// be logged; the client sees a truncated download.
+func Export(placeRepo place.PlaceRepository, categoryRepo category.CategoryRepository, locationRepo location.LocationRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+		if format != "json" && format != "csv" && format != "geojson" {
+			utils.ErrorJSON(w, errors.New("format must be csv, json or geojson"), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+		defer cancel()
+
+		categories, err := categoryRepo.GetAllCategories(ctx)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		locations, err := locationRepo.GetAllLocations(ctx)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		// Empty sections are written as [] rather than null.
+		if categories == nil {
+			categories = []*models.Category{}
+		}
+		if locations == nil {
+			locations = []*models.Location{}
+		}
+
+		name := "time-to-makan-" + time.Now().Format("20060102")
+		switch format {
+		case "json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, name))
+			err = writeJSON(ctx, w, placeRepo, categories, locations)
+		default:
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.zip"`, name, format))
+			err = writeZip(ctx, w, format, placeRepo, categories, locations)
+		}
+		if err != nil {
+			log.Println("export:", err)
+		}
+	}
+}
+
+func writeJSON(ctx context.Context, w io.Writer, repo place.PlaceRepository, categories []*models.Category, locations []*models.Location) error {
+	if _, err := io.WriteString(w, `{"places":`); err != nil {
+		return err
+	}
+
+	err := writePlacesArray(ctx, w, repo, func(p *models.Place) interface{} { return p })
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, `,"categories":`); err != nil {
+		return err
+	}
+	if err := writeValue(w, categories); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, `,"locations":`); err != nil {
+		return err
+	}
+	if err := writeValue(w, locations); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "}\n")
+	return err
+}
+
+func writeZip(ctx context.Context, w io.Writer, format string, repo place.PlaceRepository, categories []*models.Category, locations []*models.Location) error {
+	zw := zip.NewWriter(w)
+
+	if format == "csv" {
+		f, err := zw.Create("places.csv")
+		if err != nil {
+			return err
+		}
+		if err := writePlacesCSV(ctx, f, repo); err != nil {
+			return err
+		}
+
+		f, err = zw.Create("categories.csv")
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(f)
+		cw.Write([]string{"id", "category_name"})
+		for _, c := range categories {
+			cw.Write([]string{strconv.Itoa(c.ID), c.CategoryName})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+
+		f, err = zw.Create("locations.csv")
+		if err != nil {
+			return err
+		}
+		cw = csv.NewWriter(f)
+		cw.Write([]string{"id", "location_name"})
+		for _, l := range locations {
+			cw.Write([]string{strconv.Itoa(l.ID), l.LocationName})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+
+		return zw.Close()
+	}
+
+	f, err := zw.Create("places.geojson")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, `{"type":"FeatureCollection","features":`); err != nil {
+		return err
+	}
+	err = writePlacesArray(ctx, f, repo, func(p *models.Place) interface{} { return place.NewFeature(p) })
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, "}\n"); err != nil {
+		return err
+	}
+
+	f, err = zw.Create("categories.json")
+	if err != nil {
+		return err
+	}
+	if err := writeValue(f, categories); err != nil {
+		return err
+	}
+
+	f, err = zw.Create("locations.json")
+	if err != nil {
+		return err
+	}
+	if err := writeValue(f, locations); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// writePlacesArray writes every place as one element of a JSON array, using
+// encode to choose its representation.
+func writePlacesArray(ctx context.Context, w io.Writer, repo place.PlaceRepository, encode func(p *models.Place) interface{}) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	first := true
+	err := repo.EachPlace(ctx, func(p *models.Place) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		return writeValue(w, encode(p))
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+func writePlacesCSV(ctx context.Context, w io.Writer, repo place.PlaceRepository) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(placeCSVColumns); err != nil {
+		return err
+	}
+
+	err := repo.EachPlace(ctx, func(p *models.Place) error {
+		return cw.Write([]string{
+			strconv.Itoa(p.ID),
+			p.Name,
+			p.Description,
+			p.Category,
+			p.Location,
+			strings.Join(place.DietaryTagSlugs(p), ","),
+			strconv.Itoa(p.PriceLevel),
+			formatSpend(p.MinSpend),
+			formatSpend(p.MaxSpend),
+			strings.TrimSpace(p.Lat),
+			strings.TrimSpace(p.Lon),
+			formatAreaID(p.AreaID),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeValue(w io.Writer, v interface{}) error {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(js)
+	return err
+}
+
+func formatSpend(spend *float64) string {
+	if spend == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*spend, 'f', -1, 64)
+}
+
+func formatAreaID(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(*id)
+}
@@ -0,0 +1,133 @@
+package geo
+
+import "math"
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// MaxGeohashPrecision is the longest geohash Encode produces, about 4cm.
+const MaxGeohashPrecision = 12
+
+// EncodeGeohash returns the geohash of the point with the given number of
+// characters.
+func EncodeGeohash(lat, lon float64, precision int) string {
+	if precision < 1 {
+		precision = 1
+	}
+	if precision > MaxGeohashPrecision {
+		precision = MaxGeohashPrecision
+	}
+
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+
+	hash := make([]byte, 0, precision)
+	bit, ch := 0, 0
+	even := true
+	for len(hash) < precision {
+		if even {
+			mid := (minLon + maxLon) / 2
+			if lon >= mid {
+				ch |= 1 << (4 - bit)
+				minLon = mid
+			} else {
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch |= 1 << (4 - bit)
+				minLat = mid
+			} else {
+				maxLat = mid
+			}
+		}
+		even = !even
+
+		if bit < 4 {
+			bit++
+		} else {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return string(hash)
+}
+
+// GeohashCellSize returns the height and width in degrees of a geohash cell
+// with the given number of characters. Longitude takes the extra bit when a
+// geohash has an odd number of bits.
+func GeohashCellSize(precision int) (latDeg, lonDeg float64) {
+	bits := 5 * precision
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / float64(uint64(1)<<uint(latBits)), 360 / float64(uint64(1)<<uint(lonBits))
+}
+
+// GeohashesCovering returns the geohashes of the given length whose cells
+// together cover the circle of radiusKm around the point. The cells are
+// found by sampling the circle's bounding box at cell-size steps, so every
+// cell the box touches is included.
+func GeohashesCovering(lat, lon, radiusKm float64, precision int) []string {
+	dLat := radiusKm / kmPerDegree
+	minLat, maxLat := math.Max(lat-dLat, -90), math.Min(lat+dLat, 90)
+
+	// Near the poles, or for very large circles, every longitude is in range.
+	dLon := 180.0
+	if cos := math.Cos(toRadians(math.Max(math.Abs(minLat), math.Abs(maxLat)))); cos > 0 {
+		dLon = math.Min(dLat/cos, 180)
+	}
+
+	cellLat, cellLon := GeohashCellSize(precision)
+	seen := make(map[string]bool)
+	var hashes []string
+	for y := minLat; ; y += cellLat {
+		if y > maxLat {
+			y = maxLat
+		}
+		for x := lon - dLon; ; x += cellLon {
+			if x > lon+dLon {
+				x = lon + dLon
+			}
+			h := EncodeGeohash(y, wrapLon(x), precision)
+			if !seen[h] {
+				seen[h] = true
+				hashes = append(hashes, h)
+			}
+			if x >= lon+dLon {
+				break
+			}
+		}
+		if y >= maxLat {
+			break
+		}
+	}
+	return hashes
+}
+
+// GeohashPrecisionForRadius picks the longest geohash whose cells near lat
+// are at least radiusKm tall and wide, so a circle of that radius touches at
+// most three cells in each direction.
+func GeohashPrecisionForRadius(lat, radiusKm float64) int {
+	cos := math.Cos(toRadians(lat))
+	for p := MaxGeohashPrecision; p > 1; p-- {
+		cellLat, cellLon := GeohashCellSize(p)
+		if cellLat*kmPerDegree >= radiusKm && cellLon*kmPerDegree*cos >= radiusKm {
+			return p
+		}
+	}
+	return 1
+}
+
+// kmPerDegree is the length of a degree of latitude.
+const kmPerDegree = math.Pi * earthRadiusKm / 180
+
+func wrapLon(lon float64) float64 {
+	for lon < -180 {
+		lon += 360
+	}
+	for lon >= 180 {
+		lon -= 360
+	}
+	return lon
+}
@@ -0,0 +1,165 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Polygon is a GeoJSON polygon: an outer ring followed by any holes. Each
+// ring is a closed list of [lon, lat] positions.
+type Polygon [][][2]float64
+
+// MultiPolygon is the boundary of an area. Single polygons are stored as a
+// MultiPolygon of one.
+type MultiPolygon []Polygon
+
+// geoJSONObject holds the members of the GeoJSON objects ParseBoundary
+// accepts.
+type geoJSONObject struct {
+	Type        string           `json:"type"`
+	Coordinates json.RawMessage  `json:"coordinates"`
+	Geometry    *geoJSONObject   `json:"geometry"`
+	Features    []*geoJSONObject `json:"features"`
+}
+
+// ParseBoundary reads an area boundary from GeoJSON. It accepts a Polygon or
+// MultiPolygon geometry, or a Feature or FeatureCollection of them as saved
+// by map drawing tools; the polygons of every feature are combined.
+func ParseBoundary(data []byte) (MultiPolygon, error) {
+	var obj geoJSONObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("boundary is not valid GeoJSON: %w", err)
+	}
+
+	m, err := obj.polygons()
+	if err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, errors.New("boundary has no polygons")
+	}
+
+	return m, nil
+}
+
+func (obj *geoJSONObject) polygons() (MultiPolygon, error) {
+	switch obj.Type {
+	case "Polygon":
+		var coords [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &coords); err != nil {
+			return nil, errors.New("polygon coordinates must be a list of rings")
+		}
+		p, err := newPolygon(coords)
+		if err != nil {
+			return nil, err
+		}
+		return MultiPolygon{p}, nil
+
+	case "MultiPolygon":
+		var coords [][][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &coords); err != nil {
+			return nil, errors.New("multipolygon coordinates must be a list of polygons")
+		}
+		var m MultiPolygon
+		for _, c := range coords {
+			p, err := newPolygon(c)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, p)
+		}
+		return m, nil
+
+	case "Feature":
+		if obj.Geometry == nil {
+			return nil, errors.New("feature has no geometry")
+		}
+		return obj.Geometry.polygons()
+
+	case "FeatureCollection":
+		var m MultiPolygon
+		for _, f := range obj.Features {
+			if f == nil {
+				continue
+			}
+			polygons, err := f.polygons()
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, polygons...)
+		}
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("boundary must be a Polygon or MultiPolygon, not %q", obj.Type)
+}
+
+func newPolygon(coords [][][]float64) (Polygon, error) {
+	if len(coords) == 0 {
+		return nil, errors.New("polygon has no rings")
+	}
+
+	p := make(Polygon, 0, len(coords))
+	for _, c := range coords {
+		if len(c) < 4 {
+			return nil, errors.New("polygon rings need at least four positions")
+		}
+
+		ring := make([][2]float64, 0, len(c))
+		for _, pos := range c {
+			if len(pos) < 2 {
+				return nil, errors.New("positions must be [lon, lat]")
+			}
+			if pos[0] < -180 || pos[0] > 180 || pos[1] < -90 || pos[1] > 90 {
+				return nil, fmt.Errorf("position [%g, %g] is out of range", pos[0], pos[1])
+			}
+			ring = append(ring, [2]float64{pos[0], pos[1]})
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return nil, errors.New("polygon rings must end where they start")
+		}
+
+		p = append(p, ring)
+	}
+
+	return p, nil
+}
+
+// Contains reports whether the point lies inside one of the polygons and
+// outside its holes. Points exactly on an edge may fall either way.
+func (m MultiPolygon) Contains(lat, lon float64) bool {
+	for _, p := range m {
+		if p.Contains(lat, lon) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains uses the even-odd rule over every ring, which leaves holes out.
+func (p Polygon) Contains(lat, lon float64) bool {
+	inside := false
+	for _, ring := range p {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			xi, yi := ring[i][0], ring[i][1]
+			xj, yj := ring[j][0], ring[j][1]
+			if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// MarshalJSON writes the boundary as a GeoJSON MultiPolygon geometry.
+func (m MultiPolygon) MarshalJSON() ([]byte, error) {
+	coords := []Polygon(m)
+	if coords == nil {
+		coords = []Polygon{}
+	}
+	return json.Marshal(struct {
+		Type        string    `json:"type"`
+		Coordinates []Polygon `json:"coordinates"`
+	}{"MultiPolygon", coords})
+}
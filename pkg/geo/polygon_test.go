@@ -0,0 +1,102 @@
+package geo
+
+import "testing"
+
+func TestPolygonContains(t *testing.T) {
+	// A square around Novena with a square hole in the middle, and an
+	// L-shaped polygon to exercise a concave edge. Positions are [lon, lat].
+	square := Polygon{
+		{{103.80, 1.30}, {103.86, 1.30}, {103.86, 1.34}, {103.80, 1.34}, {103.80, 1.30}},
+	}
+	withHole := Polygon{
+		square[0],
+		{{103.82, 1.31}, {103.84, 1.31}, {103.84, 1.33}, {103.82, 1.33}, {103.82, 1.31}},
+	}
+	lShape := Polygon{
+		{{0, 0}, {2, 0}, {2, 1}, {1, 1}, {1, 2}, {0, 2}, {0, 0}},
+	}
+
+	tests := []struct {
+		name     string
+		polygon  Polygon
+		lat, lon float64
+		want     bool
+	}{
+		{"inside", square, 1.32, 103.83, true},
+		{"near a corner", square, 1.3001, 103.8001, true},
+		{"west", square, 1.32, 103.79, false},
+		{"east", square, 1.32, 103.87, false},
+		{"north", square, 1.35, 103.83, false},
+		{"south", square, 1.29, 103.83, false},
+		{"level with a vertex, outside", square, 1.34, 103.90, false},
+		{"in the hole", withHole, 1.32, 103.83, false},
+		{"around the hole", withHole, 1.305, 103.83, true},
+		{"between hole and edge", withHole, 1.32, 103.81, true},
+		{"in the L's foot", lShape, 0.5, 1.5, true},
+		{"in the L's stem", lShape, 1.5, 0.5, true},
+		{"in the L's notch", lShape, 1.5, 1.5, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.polygon.Contains(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("%s: Contains(%g, %g) = %v, want %v", tt.name, tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
+
+func TestMultiPolygonContains(t *testing.T) {
+	m := MultiPolygon{
+		{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}},
+		{{{5, 5}, {6, 5}, {6, 6}, {5, 6}, {5, 5}}},
+	}
+
+	tests := []struct {
+		lat, lon float64
+		want     bool
+	}{
+		{0.5, 0.5, true},
+		{5.5, 5.5, true},
+		{3, 3, false},
+	}
+
+	for _, tt := range tests {
+		if got := m.Contains(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("Contains(%g, %g) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+		}
+	}
+	if (MultiPolygon{}).Contains(0.5, 0.5) {
+		t.Error("an empty boundary contains a point")
+	}
+}
+
+func TestParseBoundary(t *testing.T) {
+	valid := []string{
+		`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}`,
+		`{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,1],[0,0]]]]}`,
+		`{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}}`,
+		`{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}}]}`,
+	}
+	for _, data := range valid {
+		m, err := ParseBoundary([]byte(data))
+		if err != nil {
+			t.Errorf("ParseBoundary(%s): %v", data, err)
+			continue
+		}
+		if !m.Contains(0.5, 0.5) {
+			t.Errorf("ParseBoundary(%s) does not contain the centre", data)
+		}
+	}
+
+	invalid := []string{
+		`{"type":"Point","coordinates":[0,0]}`,
+		`{"type":"Polygon","coordinates":[]}`,
+		`{"type":"Polygon","coordinates":[[[0,0],[1,0],[0,0]]]}`,
+		`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1]]]}`,
+		`{"type":"Polygon","coordinates":[[[0,0],[200,0],[1,1],[0,0]]]}`,
+	}
+	for _, data := range invalid {
+		if _, err := ParseBoundary([]byte(data)); err == nil {
+			t.Errorf("ParseBoundary(%s) succeeded, want an error", data)
+		}
+	}
+}
@@ -5,54 +5,190 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/audit"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/auth"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/category"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/config"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/dietary"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/discord"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/draw"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/export"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/location"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/middleware"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/place"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/preference"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/review"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/revision"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/schedule"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/slack"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/suggestion"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/trash"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
 )
 
-func NewRouter(cfg *config.Config, db *sql.DB) *mux.Router {
+func NewRouter(cfg *config.Config, db *sql.DB, signer *auth.Signer, holidays *schedule.HolidayCalendar, hooks *webhook.Dispatcher) *mux.Router {
 	r := mux.NewRouter()
 
+	r.Use(middleware.RequestID)
 	r.Use(middleware.EnableCORS)
+	r.Use(middleware.Authenticate(signer))
+	r.Use(workspace.Scope)
 
 	// Create Repo
 	authRepo := auth.NewSQLAuthRepository(db)
 	categoryRepo := category.NewSQLCategoryRepostory(db)
 	locationRepo := location.NewSQLLocationRepository(db)
-	placeRepo := place.NewSQLPlaceRepository(db)
+	areaRepo := area.NewSQLAreaRepository(db)
+	// Writes through placeRepo keep placeIndex in step for the map and
+	// proximity endpoints.
+	placeIndex := place.NewPlaceIndex(place.NewSQLPlaceRepository(db))
+	placeRepo := place.NewIndexedPlaceRepository(place.NewSQLPlaceRepository(db), placeIndex)
+	dietaryTagRepo := dietary.NewSQLDietaryTagRepository(db)
+	reviewRepo := review.NewSQLReviewRepository(db)
+	preferenceRepo := preference.NewSQLPreferenceRepository(db)
+	drawRepo := draw.NewSQLDrawRepository(db)
+	scheduleRepo := schedule.NewSQLScheduleRepository(db)
+	webhookRepo := webhook.NewSQLWebhookRepository(db)
+	slackRepo := slack.NewSQLSlackRepository(db)
+	workspaceRepo := workspace.NewSQLWorkspaceRepository(db)
+	suggestionRepo := suggestion.NewSQLSuggestionRepository(db)
+	revisionRepo := revision.NewSQLRevisionRepository(db)
+	auditRepo := audit.NewSQLAuditRepository(db)
+	// recorder.Log wraps every admin write endpoint so that each call,
+	// allowed or not, is in the audit log.
+	recorder := audit.NewRecorder(auditRepo)
 
 	// Handle  API
 	api := r.PathPrefix("/v1").Subrouter()
 	// api.HandleFunc("/places", auth.PlaceHandler(db)).Methods("GET")
 
 	// Places
-	api.HandleFunc("/places", place.GetAllPlaces(placeRepo)).Methods("GET")
-	api.HandleFunc("/places/:id", place.GetPlaceByID(placeRepo)).Methods("GET")
-	api.HandleFunc("/admin/updatePlace", place.EditPlace(placeRepo)).Methods("PUT")
-	api.HandleFunc("/admin/deletePlace/:id", place.DeletePlace(placeRepo)).Methods("DELETE")
-	api.HandleFunc("/admin/deletePlaces", place.DeletePlaces(placeRepo)).Methods("POST")
-	api.HandleFunc("/generatePlace", place.GeneratePlace(placeRepo)).Methods("GET")
+	// Regular users propose edits through suggestions; moderators and admins
+	// edit places, categories and locations directly.
+	api.HandleFunc("/places", place.GetAllPlaces(placeRepo, areaRepo)).Methods("GET")
+	api.HandleFunc("/places.geojson", place.GetPlacesGeoJSON(placeRepo, areaRepo)).Methods("GET")
+	api.HandleFunc("/places/clusters", place.GetPlaceClusters(placeIndex, areaRepo)).Methods("GET")
+	api.HandleFunc("/places/nearby", place.GetNearbyPlaces(placeIndex, areaRepo)).Methods("GET")
+	api.HandleFunc("/places/{id:[0-9]+}", place.GetPlaceByID(placeRepo)).Methods("GET")
+	api.HandleFunc("/admin/updatePlace", recorder.Log("place.save", models.EntityPlace, middleware.RequireRole(models.RoleModerator, place.EditPlace(placeRepo, dietaryTagRepo, hooks)))).Methods("PUT")
+	api.HandleFunc("/admin/deletePlace/{id:[0-9]+}", recorder.Log("place.delete", models.EntityPlace, middleware.RequireRole(models.RoleModerator, place.DeletePlace(placeRepo, hooks)))).Methods("DELETE")
+	api.HandleFunc("/admin/deletePlaces", recorder.Log("place.delete", models.EntityPlace, middleware.RequireRole(models.RoleModerator, place.DeletePlaces(placeRepo, hooks)))).Methods("POST")
+	api.HandleFunc("/admin/places/{id}/history", middleware.RequireRole(models.RoleModerator, revision.GetHistory(revisionRepo, models.EntityPlace))).Methods("GET")
+	api.HandleFunc("/admin/places/{id}/history/{revisionId}/revert", recorder.Log("place.revert", models.EntityPlace, middleware.RequireRole(models.RoleModerator, place.RevertPlace(placeRepo, revisionRepo, hooks)))).Methods("POST")
+	api.HandleFunc("/admin/places/duplicates", middleware.RequireRole(models.RoleModerator, place.GetDuplicatePlaces(placeRepo))).Methods("GET")
+	api.HandleFunc("/admin/places/{id}/merge", recorder.Log("place.merge", models.EntityPlace, middleware.RequireRole(models.RoleModerator, place.MergePlace(placeRepo, hooks)))).Methods("POST")
+	api.HandleFunc("/admin/places/import", recorder.Log("place.import", models.EntityPlace, middleware.RequireRole(models.RoleAdmin, place.ImportPlaces(placeRepo, dietaryTagRepo, hooks)))).Methods("POST")
+	api.HandleFunc("/generatePlace", place.GeneratePlace(placeRepo, areaRepo, preferenceRepo, drawRepo, hooks)).Methods("GET")
+	api.HandleFunc("/generatePlace/group", middleware.RequireUser(place.GenerateGroupPlace(placeRepo, areaRepo, preferenceRepo, workspaceRepo, drawRepo, hooks))).Methods("POST")
+
+	// Suggestions
+	api.HandleFunc("/suggestions", middleware.RequireUser(suggestion.SubmitSuggestion(suggestionRepo, placeRepo, dietaryTagRepo))).Methods("POST")
+	api.HandleFunc("/me/suggestions", middleware.RequireUser(suggestion.GetMySuggestions(suggestionRepo))).Methods("GET")
+	api.HandleFunc("/admin/suggestions", middleware.RequireRole(models.RoleModerator, suggestion.GetSuggestionQueue(suggestionRepo))).Methods("GET")
+	api.HandleFunc("/admin/suggestions/{id}", middleware.RequireRole(models.RoleModerator, suggestion.GetSuggestion(suggestionRepo, placeRepo))).Methods("GET")
+	api.HandleFunc("/admin/suggestions/{id}/approve", recorder.Log("suggestion.approve", "suggestion", middleware.RequireRole(models.RoleModerator, suggestion.ApproveSuggestion(suggestionRepo, placeRepo, dietaryTagRepo, hooks)))).Methods("POST")
+	api.HandleFunc("/admin/suggestions/{id}/reject", recorder.Log("suggestion.reject", "suggestion", middleware.RequireRole(models.RoleModerator, suggestion.RejectSuggestion(suggestionRepo)))).Methods("POST")
+
+	// Trash
+	api.HandleFunc("/admin/trash", middleware.RequireRole(models.RoleAdmin, trash.GetTrash(placeRepo, categoryRepo, locationRepo))).Methods("GET")
+	api.HandleFunc("/admin/trash/{type}/{id}/restore", recorder.Log("trash.restore", "", middleware.RequireRole(models.RoleAdmin, trash.RestoreItem(placeRepo, categoryRepo, locationRepo, hooks)))).Methods("POST")
+
+	// Export
+	api.HandleFunc("/admin/export", middleware.RequireRole(models.RoleAdmin, export.Export(placeRepo, categoryRepo, locationRepo))).Methods("GET")
 
 	// Categories
 	api.HandleFunc("/admin/categories", category.GetAllCategories(categoryRepo)).Methods("GET")
-	api.HandleFunc("/admin/categories/:id", category.GetCategoryByID(categoryRepo)).Methods("GET")
-	api.HandleFunc("/admin/updateCategory", category.EditCategory(categoryRepo)).Methods("PUT")
-	api.HandleFunc("/admin/deleteCategory/:id", category.DeleteCategory(categoryRepo)).Methods("DELETE")
-	api.HandleFunc("/admin/deleteCategories", category.DeleteCategories(categoryRepo)).Methods("POST")
+	api.HandleFunc("/admin/categories/{id:[0-9]+}", category.GetCategoryByID(categoryRepo)).Methods("GET")
+	api.HandleFunc("/admin/updateCategory", recorder.Log("category.save", models.EntityCategory, middleware.RequireRole(models.RoleModerator, category.EditCategory(categoryRepo)))).Methods("PUT")
+	api.HandleFunc("/admin/deleteCategory/{id:[0-9]+}", recorder.Log("category.delete", models.EntityCategory, middleware.RequireRole(models.RoleModerator, category.DeleteCategory(categoryRepo)))).Methods("DELETE")
+	api.HandleFunc("/admin/deleteCategories", recorder.Log("category.delete", models.EntityCategory, middleware.RequireRole(models.RoleModerator, category.DeleteCategories(categoryRepo)))).Methods("POST")
+	api.HandleFunc("/admin/categories/{id}/history", middleware.RequireRole(models.RoleModerator, revision.GetHistory(revisionRepo, models.EntityCategory))).Methods("GET")
+	api.HandleFunc("/admin/categories/{id}/history/{revisionId}/revert", recorder.Log("category.revert", models.EntityCategory, middleware.RequireRole(models.RoleModerator, category.RevertCategory(categoryRepo, revisionRepo)))).Methods("POST")
+
+	// Draws
+	api.HandleFunc("/draws/{id}", draw.GetDrawByID(drawRepo)).Methods("GET")
+
+	// Schedules
+	api.HandleFunc("/schedules/{id}/today", schedule.GetTodayPick(scheduleRepo, placeRepo, holidays)).Methods("GET")
+	api.HandleFunc("/calendars/{token:[0-9a-f]+}.ics", schedule.GetCalendar(scheduleRepo, placeRepo)).Methods("GET")
+	api.HandleFunc("/schedules/{id:[0-9]+}/calendar.ics", schedule.GetCalendar(scheduleRepo, placeRepo)).Methods("GET")
+	api.HandleFunc("/admin/schedules", middleware.RequireRole(models.RoleAdmin, schedule.GetAllSchedules(scheduleRepo))).Methods("GET")
+	api.HandleFunc("/admin/schedules/{id}", middleware.RequireRole(models.RoleAdmin, schedule.GetScheduleByID(scheduleRepo))).Methods("GET")
+	api.HandleFunc("/admin/updateSchedule", recorder.Log("schedule.save", "schedule", middleware.RequireRole(models.RoleAdmin, schedule.EditSchedule(scheduleRepo)))).Methods("PUT")
+	api.HandleFunc("/admin/deleteSchedule/{id}", recorder.Log("schedule.delete", "schedule", middleware.RequireRole(models.RoleAdmin, schedule.DeleteSchedule(scheduleRepo)))).Methods("DELETE")
+
+	// Webhooks
+	api.HandleFunc("/admin/webhooks", middleware.RequireRole(models.RoleAdmin, webhook.GetAllWebhooks(webhookRepo))).Methods("GET")
+	api.HandleFunc("/admin/webhooks/{id}", middleware.RequireRole(models.RoleAdmin, webhook.GetWebhookByID(webhookRepo))).Methods("GET")
+	api.HandleFunc("/admin/webhooks/{id}/deliveries", middleware.RequireRole(models.RoleAdmin, webhook.GetWebhookDeliveries(webhookRepo))).Methods("GET")
+	api.HandleFunc("/admin/updateWebhook", recorder.Log("webhook.save", "webhook", middleware.RequireRole(models.RoleAdmin, webhook.EditWebhook(webhookRepo)))).Methods("PUT")
+	api.HandleFunc("/admin/deleteWebhook/{id}", recorder.Log("webhook.delete", "webhook", middleware.RequireRole(models.RoleAdmin, webhook.DeleteWebhook(webhookRepo)))).Methods("DELETE")
+	api.HandleFunc("/admin/webhookDeliveries/{id}/replay", recorder.Log("webhook.replay", "webhook_delivery", middleware.RequireRole(models.RoleAdmin, webhook.ReplayDelivery(hooks)))).Methods("POST")
+
+	// Integrations
+	api.HandleFunc("/integrations/slack/command", slack.Verify(cfg.Slack.SigningSecret, workspace.FromQuery(workspaceRepo, slack.Command(placeRepo, areaRepo, preferenceRepo, drawRepo, hooks)))).Methods("POST")
+	api.HandleFunc("/integrations/slack/interactions", slack.Verify(cfg.Slack.SigningSecret, workspace.FromQuery(workspaceRepo, slack.Interact(placeRepo, areaRepo, preferenceRepo, drawRepo, slackRepo, hooks)))).Methods("POST")
+	api.HandleFunc("/integrations/discord/interactions", discord.Verify(cfg.Discord.PublicKey, workspace.FromQuery(workspaceRepo, discord.Interactions(placeRepo, areaRepo, preferenceRepo, drawRepo, hooks)))).Methods("POST")
+
+	// Reviews
+	api.HandleFunc("/places/{id}/reviews", review.GetPlaceReviews(reviewRepo)).Methods("GET")
+	api.HandleFunc("/places/{id}/reviews", middleware.RequireUser(review.CreateReview(reviewRepo))).Methods("POST")
+	api.HandleFunc("/me/reviews", middleware.RequireUser(review.GetMyReviews(reviewRepo))).Methods("GET")
+	api.HandleFunc("/reviews/{id}", middleware.RequireUser(review.EditReview(reviewRepo))).Methods("PUT")
+	api.HandleFunc("/reviews/{id}", middleware.RequireUser(review.DeleteReview(reviewRepo))).Methods("DELETE")
+	api.HandleFunc("/admin/reviews/{id}", recorder.Log("review.remove", "review", middleware.RequireRole(models.RoleModerator, review.RemoveReview(reviewRepo)))).Methods("DELETE")
+
+	// Favourites, Blocklist and Constraints
+	api.HandleFunc("/me/favourites", middleware.RequireUser(preference.GetPreferences(preferenceRepo, models.PreferenceFavourite))).Methods("GET")
+	api.HandleFunc("/me/favourites/{placeId}", middleware.RequireUser(preference.AddPreference(preferenceRepo, models.PreferenceFavourite))).Methods("PUT")
+	api.HandleFunc("/me/favourites/{placeId}", middleware.RequireUser(preference.RemovePreference(preferenceRepo, models.PreferenceFavourite))).Methods("DELETE")
+	api.HandleFunc("/me/blocked", middleware.RequireUser(preference.GetPreferences(preferenceRepo, models.PreferenceBlocked))).Methods("GET")
+	api.HandleFunc("/me/blocked/{placeId}", middleware.RequireUser(preference.AddPreference(preferenceRepo, models.PreferenceBlocked))).Methods("PUT")
+	api.HandleFunc("/me/blocked/{placeId}", middleware.RequireUser(preference.RemovePreference(preferenceRepo, models.PreferenceBlocked))).Methods("DELETE")
+	api.HandleFunc("/me/constraints", middleware.RequireUser(preference.GetConstraint(preferenceRepo))).Methods("GET")
+	api.HandleFunc("/me/constraints", middleware.RequireUser(preference.EditConstraint(preferenceRepo, dietaryTagRepo))).Methods("PUT")
+
+	// Dietary Tags
+	api.HandleFunc("/dietaryTags", dietary.GetAllDietaryTags(dietaryTagRepo)).Methods("GET")
+	// Tags are shared by every workspace, so only system admins manage them.
+	api.HandleFunc("/admin/dietaryTags/{id}", middleware.RequireSystemAdmin(dietary.GetDietaryTagByID(dietaryTagRepo))).Methods("GET")
+	api.HandleFunc("/admin/updateDietaryTag", recorder.Log("dietary_tag.save", "dietary_tag", middleware.RequireSystemAdmin(dietary.EditDietaryTag(dietaryTagRepo)))).Methods("PUT")
+	api.HandleFunc("/admin/deleteDietaryTag/{id}", recorder.Log("dietary_tag.delete", "dietary_tag", middleware.RequireSystemAdmin(dietary.DeleteDietaryTag(dietaryTagRepo)))).Methods("DELETE")
 
 	// Location
-	api.HandleFunc("/admin/locations", location.GetAllLocations(locationRepo)).Methods("GET")
-	api.HandleFunc("/admin/locations/:id", location.GetLocationByID(locationRepo)).Methods("GET")
-	api.HandleFunc("/admin/updateLocation", location.EditLocation(locationRepo)).Methods("PUT")
-	api.HandleFunc("/admin/deleteLocation/:id", location.DeleteLocation(locationRepo)).Methods("DELETE")
-	api.HandleFunc("/admin/deleteLocations", location.DeleteLocations(locationRepo)).Methods("POST")
-
-	api.HandleFunc("/auth/login", auth.Login(authRepo)).Methods("POST")
-	api.HandleFunc("/auth/logout", auth.Logout(authRepo)).Methods("POST")
-	api.HandleFunc("/auth/register", auth.Register(authRepo, cfg)).Methods("POST")
+	api.HandleFunc("/admin/locations", location.GetAllLocations(locationRepo, areaRepo)).Methods("GET")
+	api.HandleFunc("/admin/locations/{id:[0-9]+}", location.GetLocationByID(locationRepo)).Methods("GET")
+	api.HandleFunc("/admin/updateLocation", recorder.Log("location.save", models.EntityLocation, middleware.RequireRole(models.RoleModerator, location.EditLocation(locationRepo)))).Methods("PUT")
+	api.HandleFunc("/admin/deleteLocation/{id:[0-9]+}", recorder.Log("location.delete", models.EntityLocation, middleware.RequireRole(models.RoleModerator, location.DeleteLocation(locationRepo)))).Methods("DELETE")
+	api.HandleFunc("/admin/deleteLocations", recorder.Log("location.delete", models.EntityLocation, middleware.RequireRole(models.RoleModerator, location.DeleteLocations(locationRepo)))).Methods("POST")
+	api.HandleFunc("/admin/locations/{id}/history", middleware.RequireRole(models.RoleModerator, revision.GetHistory(revisionRepo, models.EntityLocation))).Methods("GET")
+	api.HandleFunc("/admin/locations/{id}/history/{revisionId}/revert", recorder.Log("location.revert", models.EntityLocation, middleware.RequireRole(models.RoleModerator, location.RevertLocation(locationRepo, revisionRepo)))).Methods("POST")
+
+	// Areas
+	api.HandleFunc("/areas", area.GetAllAreas(areaRepo)).Methods("GET")
+	api.HandleFunc("/areas/{id}", area.GetAreaByID(areaRepo)).Methods("GET")
+	// Areas are shared too.
+	api.HandleFunc("/admin/updateArea", recorder.Log("area.save", "area", middleware.RequireSystemAdmin(area.EditArea(areaRepo)))).Methods("PUT")
+	api.HandleFunc("/admin/areas/{id}/boundary", recorder.Log("area.boundary", "area", middleware.RequireSystemAdmin(area.UploadAreaBoundary(areaRepo)))).Methods("PUT")
+	api.HandleFunc("/admin/deleteArea/{id}", recorder.Log("area.delete", "area", middleware.RequireSystemAdmin(area.DeleteArea(areaRepo)))).Methods("DELETE")
+
+	// Workspaces
+	api.HandleFunc("/me/workspaces", middleware.RequireUser(workspace.GetMyWorkspaces(workspaceRepo))).Methods("GET")
+	api.HandleFunc("/workspaces", middleware.RequireUser(workspace.CreateWorkspace(workspaceRepo))).Methods("POST")
+	api.HandleFunc("/workspaces/join", middleware.RequireUser(workspace.JoinWorkspace(workspaceRepo, recorder))).Methods("POST")
+	api.HandleFunc("/workspaces/{id}/switch", middleware.RequireUser(workspace.SwitchWorkspace(workspaceRepo, authRepo, signer))).Methods("POST")
+	api.HandleFunc("/admin/invites", recorder.Log("invite.create", "invite", middleware.RequireRole(models.RoleAdmin, workspace.CreateInvite(workspaceRepo)))).Methods("POST")
+	api.HandleFunc("/admin/members/{id}/role", recorder.Log("member.role_change", "user", middleware.RequireRole(models.RoleAdmin, workspace.UpdateMemberRole(workspaceRepo)))).Methods("PUT")
+
+	// Audit
+	api.HandleFunc("/admin/audit", middleware.RequireRole(models.RoleAdmin, audit.GetAuditLog(auditRepo))).Methods("GET")
+
+	api.HandleFunc("/auth/login", auth.Login(authRepo, signer, recorder)).Methods("POST")
+	api.HandleFunc("/auth/logout", auth.Logout(authRepo, recorder)).Methods("POST")
+	api.HandleFunc("/auth/register", auth.Register(authRepo, cfg, recorder)).Methods("POST")
 	api.HandleFunc("/auth/forget-password", auth.ForgetPassword(authRepo)).Methods("POST")
 
 	// Telegram_Access
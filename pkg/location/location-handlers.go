@@ -8,26 +8,28 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/julienschmidt/httprouter"
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/revision"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
 )
 
 type LocationDto struct {
 	ID           int    `json:"id"`
 	LocationName string `json:"location_name"`
+	AreaID       *int   `json:"area_id"`
 }
 
 func GetLocationByID(repo LocationRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params := httprouter.ParamsFromContext(r.Context())
-		id, err := strconv.Atoi(params.ByName("id"))
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
 		if err != nil {
 			utils.ErrorJSON(w, err)
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 
 		location, err := repo.GetLocationByID(ctx, id)
@@ -44,9 +46,12 @@ func GetLocationByID(repo LocationRepository) http.HandlerFunc {
 	}
 }
 
-func GetAllLocations(repo LocationRepository) http.HandlerFunc {
+// GetAllLocations lists locations, or with area= those assigned to the
+// area. Locations are named neighbourhoods rather than points, so area
+// membership comes from their area_id.
+func GetAllLocations(repo LocationRepository, areaRepo area.AreaRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 
 		locations, err := repo.GetAllLocations(ctx)
@@ -55,6 +60,26 @@ func GetAllLocations(repo LocationRepository) http.HandlerFunc {
 			return
 		}
 
+		if key := r.URL.Query().Get("area"); key != "" {
+			a, err := area.Lookup(ctx, areaRepo, key)
+			if errors.Is(err, area.ErrAreaNotFound) {
+				utils.ErrorJSON(w, err, http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				utils.ErrorJSON(w, err)
+				return
+			}
+
+			var inArea []*models.Location
+			for _, l := range locations {
+				if l.AreaID != nil && *l.AreaID == a.ID {
+					inArea = append(inArea, l)
+				}
+			}
+			locations = inArea
+		}
+
 		err = utils.WriteJSON(w, http.StatusOK, locations, "locations")
 		if err != nil {
 			utils.ErrorJSON(w, err)
@@ -72,7 +97,7 @@ func EditLocation(repo LocationRepository) http.HandlerFunc {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 		var location models.Location
 		if payload.ID != 0 {
@@ -86,6 +111,7 @@ func EditLocation(repo LocationRepository) http.HandlerFunc {
 
 		location.ID = payload.ID
 		location.LocationName = payload.LocationName
+		location.AreaID = payload.AreaID
 		location.UpdatedAt = time.Now()
 
 		if location.ID == 0 {
@@ -112,14 +138,13 @@ func EditLocation(repo LocationRepository) http.HandlerFunc {
 
 func DeleteLocation(repo LocationRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params := httprouter.ParamsFromContext(r.Context())
-		id, err := strconv.Atoi(params.ByName("id"))
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
 		if err != nil {
 			utils.ErrorJSON(w, err)
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 		err = repo.DeleteLocation(ctx, id)
 		if err != nil {
@@ -150,7 +175,7 @@ func DeleteLocations(repo LocationRepository) http.HandlerFunc {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 
 		err = repo.DeleteLocations(ctx, idList)
@@ -166,3 +191,32 @@ func DeleteLocations(repo LocationRepository) http.HandlerFunc {
 		}
 	}
 }
+
+// RevertLocation restores the location to how it was after the revision in
+// the URL, recording the restore as a new revision.
+func RevertLocation(repo LocationRepository, revisionRepo revision.RevisionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		var location models.Location
+		id, revisionID, ok := revision.ReadRevert(ctx, w, r, revisionRepo, models.EntityLocation, &location)
+		if !ok {
+			return
+		}
+
+		location.ID = id
+		location.UpdatedAt = time.Now()
+		err := repo.RestoreLocation(ctx, location, revisionID)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, nil, "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
@@ -5,11 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/revision"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
 )
 
 type LocationRepository interface {
@@ -19,6 +19,10 @@ type LocationRepository interface {
 	UpdateLocation(ctx context.Context, location models.Location) error
 	DeleteLocation(ctx context.Context, id int) error
 	DeleteLocations(ctx context.Context, idList []int) error
+	RestoreLocation(ctx context.Context, location models.Location, revisionID int) error
+	GetDeletedLocations(ctx context.Context) ([]*models.TrashItem, error)
+	UndeleteLocation(ctx context.Context, id int) error
+	PurgeLocations(ctx context.Context, before time.Time) (int, error)
 }
 
 type SQLLocationRepository struct {
@@ -33,13 +37,25 @@ func NewSQLLocationRepository(db *sql.DB) *SQLLocationRepository {
 }
 
 func (r *SQLLocationRepository) GetLocationByID(ctx context.Context, id int) (*models.Location, error) {
-	query := `Select id, location_name, created_at, updated_at from location where id = ?`
+	return loadLocation(ctx, r.db, id, false)
+}
+
+// loadLocation reads a location that is not in the trash, locking it until
+// the end of q's transaction when lock is set.
+func loadLocation(ctx context.Context, q interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, id int, lock bool) (*models.Location, error) {
+	query := `Select id, location_name, area_id, created_at, updated_at from location where id = ? and workspace_id = ? and deleted_at is null`
+	if lock {
+		query += ` for update`
+	}
 
-	row := r.db.QueryRowContext(ctx, query, id)
+	row := q.QueryRowContext(ctx, query, id, workspace.FromContext(ctx))
 	var location models.Location
 	err := row.Scan(
 		&location.ID,
 		&location.LocationName,
+		&location.AreaID,
 		&location.CreatedAt,
 		&location.UpdatedAt,
 	)
@@ -51,8 +67,8 @@ func (r *SQLLocationRepository) GetLocationByID(ctx context.Context, id int) (*m
 }
 
 func (r *SQLLocationRepository) GetAllLocations(ctx context.Context) ([]*models.Location, error) {
-	query := fmt.Sprint(`select id, location_name, created_at, updated_at from location`)
-	rows, err := r.db.QueryContext(ctx, query)
+	query := fmt.Sprint(`select id, location_name, area_id, created_at, updated_at from location where workspace_id = ? and deleted_at is null`)
+	rows, err := r.db.QueryContext(ctx, query, workspace.FromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +81,7 @@ func (r *SQLLocationRepository) GetAllLocations(ctx context.Context) ([]*models.
 		err := rows.Scan(
 			&location.ID,
 			&location.LocationName,
+			&location.AreaID,
 			&location.CreatedAt,
 			&location.UpdatedAt,
 		)
@@ -78,61 +95,191 @@ func (r *SQLLocationRepository) GetAllLocations(ctx context.Context) ([]*models.
 
 func (r *SQLLocationRepository) InsertLocation(ctx context.Context, location models.Location) error {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	stmt := `
-		insert into location (location_name) value (?)
+		insert into location (location_name, area_id, workspace_id) value (?, ?, ?)
 	`
 
-	_, err := r.db.ExecContext(ctx, stmt, location.LocationName)
-	if err != nil {
-		return err
-	}
-	return nil
+	return r.write(ctx, func(tx *sql.Tx) (revision.Change, error) {
+		result, err := tx.ExecContext(ctx, stmt, location.LocationName, location.AreaID, workspace.FromContext(ctx))
+		if err != nil {
+			return revision.Change{}, err
+		}
+
+		id, err := result.LastInsertId()
+		return revision.Change{EntityID: int(id), Action: models.RevisionCreate}, err
+	})
 }
 
 func (r *SQLLocationRepository) UpdateLocation(ctx context.Context, location models.Location) error {
 	stmt := `
-		Update location set location_name = ?, updated_at = ? where id = ?
+		Update location set location_name = ?, area_id = ?, updated_at = ? where id = ? and workspace_id = ?
 	`
 
-	_, err := r.db.ExecContext(ctx, stmt, location.LocationName, location.UpdatedAt, location.ID)
+	return r.write(ctx, func(tx *sql.Tx) (revision.Change, error) {
+		before, err := loadLocation(ctx, tx, location.ID, true)
+		if err != nil {
+			return revision.Change{}, err
+		}
+
+		_, err = tx.ExecContext(ctx, stmt, location.LocationName, location.AreaID, location.UpdatedAt, location.ID, workspace.FromContext(ctx))
+		return revision.Change{EntityID: location.ID, Action: models.RevisionUpdate, Before: before}, err
+	})
+}
+
+// RestoreLocation writes the location back as it was in a snapshot taken
+// from revisionID, taking it out of the trash or re-creating it under the
+// same ID if it has been deleted.
+func (r *SQLLocationRepository) RestoreLocation(ctx context.Context, location models.Location, revisionID int) error {
+	return r.write(ctx, func(tx *sql.Tx) (revision.Change, error) {
+		change := revision.Change{EntityID: location.ID, Action: models.RevisionRevert, RevertOf: &revisionID}
+
+		before, err := loadLocation(ctx, tx, location.ID, true)
+		if errors.Is(err, sql.ErrNoRows) {
+			stmt := `
+				insert into location (id, location_name, area_id, workspace_id) value (?, ?, ?, ?)
+				on duplicate key update location_name = values(location_name), area_id = values(area_id), deleted_at = null
+			`
+			_, err = tx.ExecContext(ctx, stmt, location.ID, location.LocationName, location.AreaID, workspace.FromContext(ctx))
+			return change, err
+		}
+		if err != nil {
+			return change, err
+		}
+
+		change.Before = before
+		stmt := `Update location set location_name = ?, area_id = ?, updated_at = ? where id = ? and workspace_id = ?`
+		_, err = tx.ExecContext(ctx, stmt, location.LocationName, location.AreaID, location.UpdatedAt, location.ID, workspace.FromContext(ctx))
+		return change, err
+	})
+}
+
+func (r *SQLLocationRepository) DeleteLocation(ctx context.Context, id int) error {
+	return r.DeleteLocations(ctx, []int{id})
+}
+
+// DeleteLocations moves the locations to the trash, recording each one's
+// last state. IDs that are not locations in the workspace, or are already in
+// the trash, are skipped.
+func (r *SQLLocationRepository) DeleteLocations(ctx context.Context, idList []int) error {
+	if len(idList) == 0 {
+		return errors.New("the list is empty")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	for _, id := range idList {
+		before, err := loadLocation(ctx, tx, id, true)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `Update location set deleted_at = ? where id = ? and workspace_id = ?`, time.Now(), id, workspace.FromContext(ctx))
+		if err != nil {
+			return err
+		}
+
+		err = revision.Record(ctx, tx, revision.Change{
+			EntityType: models.EntityLocation,
+			EntityID:   id,
+			Action:     models.RevisionDelete,
+			Before:     before,
+		})
+		if err != nil {
+			return err
+		}
+	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (r *SQLLocationRepository) DeleteLocation(ctx context.Context, id int) error {
-	stmt := `Delete from location where id = ?`
-	_, err := r.db.ExecContext(ctx, stmt, id)
+// GetDeletedLocations lists the locations in the trash, most recently
+// deleted first.
+func (r *SQLLocationRepository) GetDeletedLocations(ctx context.Context) ([]*models.TrashItem, error) {
+	query := `select id, location_name, deleted_at from location where workspace_id = ? and deleted_at is not null order by deleted_at desc, id`
+	rows, err := r.db.QueryContext(ctx, query, workspace.FromContext(ctx))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	defer rows.Close()
+
+	var items []*models.TrashItem
+	for rows.Next() {
+		item := models.TrashItem{EntityType: models.EntityLocation}
+		err := rows.Scan(&item.ID, &item.Name, &item.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
 }
 
-func (r *SQLLocationRepository) DeleteLocations(ctx context.Context, idList []int) error {
-	if len(idList) == 0 {
-		return errors.New("the list is empty")
+// UndeleteLocation takes the location out of the trash. It returns
+// sql.ErrNoRows if the location is not in the trash.
+func (r *SQLLocationRepository) UndeleteLocation(ctx context.Context, id int) error {
+	return r.write(ctx, func(tx *sql.Tx) (revision.Change, error) {
+		change := revision.Change{EntityID: id, Action: models.RevisionRestore}
+
+		result, err := tx.ExecContext(ctx, `update location set deleted_at = null where id = ? and workspace_id = ? and deleted_at is not null`, id, workspace.FromContext(ctx))
+		if err != nil {
+			return change, err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return change, err
+		} else if n == 0 {
+			return change, sql.ErrNoRows
+		}
+		return change, nil
+	})
+}
+
+// PurgeLocations permanently removes the locations that were moved to the
+// trash before the given time and returns how many there were.
+func (r *SQLLocationRepository) PurgeLocations(ctx context.Context, before time.Time) (int, error) {
+	result, err := r.db.ExecContext(ctx, `delete from location where workspace_id = ? and deleted_at < ?`, workspace.FromContext(ctx), before)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// write runs fn in a transaction and records the change it reports, with the
+// location as stored afterwards.
+func (r *SQLLocationRepository) write(ctx context.Context, fn func(tx *sql.Tx) (revision.Change, error)) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	var sb strings.Builder
-	sb.WriteString("Delete from location where id in (")
-	sb.WriteString(strconv.Itoa(idList[0]))
-	for i := 1; i < len(idList); i++ {
-		sb.WriteString(",")
-		sb.WriteString(strconv.Itoa(idList[i]))
+	change, err := fn(tx)
+	if err != nil {
+		return err
+	}
+
+	change.EntityType = models.EntityLocation
+	change.After, err = loadLocation(ctx, tx, change.EntityID, false)
+	if err != nil {
+		return err
 	}
-	sb.WriteString(")")
 
-	_, err := r.db.ExecContext(ctx, sb.String())
+	err = revision.Record(ctx, tx, change)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
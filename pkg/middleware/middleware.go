@@ -1,13 +1,18 @@
 package middleware
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"log"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ngfenglong/food-randomizer-BE/pkg/auth"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/config"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
 	"github.com/pascaldekloe/jwt"
@@ -16,7 +21,8 @@ import (
 func EnableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization,X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 		// Add other headers as needed
 		if r.Method == "OPTIONS" {
@@ -80,3 +86,109 @@ func CheckToken(next http.Handler, cfg *config.Config) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+type contextKey string
+
+const (
+	userContextKey      contextKey = "user"
+	requestIDContextKey contextKey = "requestID"
+)
+
+// RequestIDHeader carries a request's ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern is what a caller-supplied request ID must look like to be
+// kept; anything else is replaced with a generated one.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives every request an ID, taken from the X-Request-ID header
+// when the caller sends a usable one and generated otherwise. The ID is put
+// in the request context and echoed in the response header so that a
+// response can be matched to its audit log entries.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				utils.ErrorJSON(w, err, http.StatusInternalServerError)
+				return
+			}
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey, id)))
+	})
+}
+
+// RequestIDFromContext returns the ID that RequestID gave the request, or ""
+// outside of one.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// Authenticate attaches the caller's token details to the request context
+// when a valid bearer token is sent. Requests without one, or with one that
+// does not verify, continue anonymously; use RequireUser to reject them.
+func Authenticate(signer *auth.Signer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Authorization")
+
+			headerParts := strings.Split(r.Header.Get("Authorization"), " ")
+			if len(headerParts) == 2 && headerParts[0] == "Bearer" {
+				td, err := signer.ParseAccessToken(headerParts[1])
+				if err == nil {
+					r = r.WithContext(context.WithValue(r.Context(), userContextKey, td))
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UserFromContext returns the authenticated caller, if any.
+func UserFromContext(ctx context.Context) (*auth.TokenDetail, bool) {
+	td, ok := ctx.Value(userContextKey).(*auth.TokenDetail)
+	return td, ok
+}
+
+// RequireUser rejects requests that were not authenticated.
+func RequireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := UserFromContext(r.Context()); !ok {
+			utils.ErrorJSON(w, errors.New("unauthorized"), http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireSystemAdmin rejects requests from callers who are not system admins.
+// It guards data shared by every workspace, where a workspace role means
+// nothing: anyone can create a workspace and be its admin.
+func RequireSystemAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		td, _ := UserFromContext(r.Context())
+		if !td.SystemAdmin {
+			utils.ErrorJSON(w, errors.New("forbidden"), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// RequireRole rejects requests from callers below the given role.
+func RequireRole(role int, next http.HandlerFunc) http.HandlerFunc {
+	return RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		td, _ := UserFromContext(r.Context())
+		if td.Role < role {
+			utils.ErrorJSON(w, errors.New("forbidden"), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/auth"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/config"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+func TestRequireSystemAdmin(t *testing.T) {
+	signer, err := auth.NewSigner(config.JWTConfig{Secret: "access secret", RefreshSecret: "refresh secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := Authenticate(signer)(RequireSystemAdmin(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name string
+		td   *auth.TokenDetail
+		want int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"workspace admin", &auth.TokenDetail{ID: 1, Role: models.RoleAdmin, WorkspaceID: 5}, http.StatusForbidden},
+		{"system admin", &auth.TokenDetail{ID: 2, Role: models.RoleUser, WorkspaceID: 1, SystemAdmin: true}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/v1/admin/updateArea", nil)
+		if tt.td != nil {
+			token, _, err := signer.GenerateAccessToken(tt.td)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
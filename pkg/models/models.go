@@ -1,21 +1,113 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
 )
 
 type Place struct {
-	ID           int       `json:"id"`
-	Name         string    `json:"name"`
-	Description  string    `json:"description"`
-	Category     string    `json:"category"`
-	IsHalal      bool      `json:"is_halal"`
-	IsVegetarian bool      `json:"is_vegetarian"`
-	Location     string    `json:"location"`
-	Lat          string    `json:"lat"`
-	Lon          string    `json:"lon"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID          int          `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Category    string       `json:"category"`
+	DietaryTags []DietaryTag `json:"dietary_tags"`
+	// IsHalal and IsVegetarian are derived from DietaryTags and kept for
+	// clients that predate the tag taxonomy.
+	IsHalal      bool     `json:"is_halal"`
+	IsVegetarian bool     `json:"is_vegetarian"`
+	Location     string   `json:"location"`
+	Lat          string   `json:"lat"`
+	Lon          string   `json:"lon"`
+	AreaID       *int     `json:"area_id"`
+	PriceLevel   int      `json:"price_level"`
+	MinSpend     *float64 `json:"min_spend"`
+	MaxSpend     *float64 `json:"max_spend"`
+	// OpeningHours is empty when the hours have not been captured yet.
+	OpeningHours []OpeningPeriod `json:"opening_hours"`
+	// AverageRating is 0 when the place has no reviews.
+	AverageRating float64   `json:"average_rating"`
+	RatingCount   int       `json:"rating_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// HasDietaryTag reports whether the place carries any of the given tag slugs.
+func (p *Place) HasDietaryTag(slugs ...string) bool {
+	for _, tag := range p.DietaryTags {
+		for _, slug := range slugs {
+			if tag.Slug == slug {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// OpeningPeriod is one stretch of a place's weekly opening hours in its
+// area's local time. Day is 0 for Sunday to 6 for Saturday; Opens and Closes
+// are "15:04" clock times. A period that closes at or before it opens runs
+// past midnight into the next day, and "24:00" closes at midnight.
+type OpeningPeriod struct {
+	Day    int    `json:"day"`
+	Opens  string `json:"opens"`
+	Closes string `json:"closes"`
+}
+
+// ClockMinutes parses a "15:04" clock time, or "24:00", into minutes after
+// midnight.
+func ClockMinutes(clock string) (int, bool) {
+	if clock == "24:00" {
+		return 24 * 60, true
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// OpenAt reports whether one of the place's periods covers t, read as a
+// local time. Periods that do not parse are ignored.
+func (p *Place) OpenAt(t time.Time) bool {
+	weekday := int(t.Weekday())
+	minute := t.Hour()*60 + t.Minute()
+
+	for _, period := range p.OpeningHours {
+		opens, ok := ClockMinutes(period.Opens)
+		if !ok {
+			continue
+		}
+		closes, ok := ClockMinutes(period.Closes)
+		if !ok {
+			continue
+		}
+
+		if closes > opens {
+			if period.Day == weekday && minute >= opens && minute < closes {
+				return true
+			}
+			continue
+		}
+
+		// Overnight: the evening of Day, then the early hours of the next.
+		if period.Day == weekday && minute >= opens {
+			return true
+		}
+		if (period.Day+1)%7 == weekday && minute < closes {
+			return true
+		}
+	}
+	return false
+}
+
+type DietaryTag struct {
+	ID        int       `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
 }
 
 type Category struct {
@@ -40,10 +132,23 @@ type Location struct {
 	StreetName   string    `json:"street_name"`
 	Lat          string    `json:"lat"`
 	Lon          string    `json:"lon"`
+	AreaID       *int      `json:"area_id"`
 	CreatedAt    time.Time `json:"-"`
 	UpdatedAt    time.Time `json:"-"`
 }
 
+// Area is a service region such as Novena. Places and locations inside the
+// boundary belong to it; Timezone is an IANA name used for local times in
+// the area.
+type Area struct {
+	ID        int              `json:"id"`
+	Name      string           `json:"name"`
+	Timezone  string           `json:"timezone"`
+	Boundary  geo.MultiPolygon `json:"boundary"`
+	CreatedAt time.Time        `json:"-"`
+	UpdatedAt time.Time        `json:"-"`
+}
+
 type PlaceLocation struct {
 	ID         int       `json:"-"`
 	PlaceID    int       `json:"-"`
@@ -53,16 +158,279 @@ type PlaceLocation struct {
 	UpdatedAt  time.Time `json:"-"`
 }
 
-type User struct {
+// User roles, in increasing order of privilege.
+const (
+	RoleUser      = 0
+	RoleModerator = 1
+	RoleAdmin     = 2
+)
+
+// Review ratings are whole stars within this range.
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+type Review struct {
 	ID        int       `json:"id"`
+	PlaceID   int       `json:"place_id"`
+	UserID    int       `json:"user_id"`
 	UserName  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
+	Rating    int       `json:"rating"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Kinds and statuses of a place suggestion.
+const (
+	SuggestionCreate = "create"
+	SuggestionUpdate = "update"
+
+	SuggestionPending  = "pending"
+	SuggestionApproved = "approved"
+	SuggestionRejected = "rejected"
+)
+
+// PlaceSuggestion is a new place or a correction to one, sent in by a user
+// and held until a moderator approves or rejects it. Proposed is the place as
+// the user would have it; once approved it is the place as applied.
+type PlaceSuggestion struct {
+	ID            int        `json:"id"`
+	Kind          string     `json:"kind"`
+	PlaceID       *int       `json:"place_id"`
+	UserID        *int       `json:"user_id"`
+	Status        string     `json:"status"`
+	Proposed      Place      `json:"proposed"`
+	Comment       string     `json:"comment"`
+	ModeratorID   *int       `json:"moderator_id"`
+	ModeratorNote string     `json:"moderator_note"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Entities and actions recorded in revisions.
+const (
+	EntityPlace    = "place"
+	EntityCategory = "category"
+	EntityLocation = "location"
+
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRevert  = "revert"
+	RevisionRestore = "restore"
+)
+
+// Revision is an immutable record of one write to a place, category or
+// location. Before and After are JSON snapshots of the entity; Before is null
+// for creations and After is null for deletions. RevertOf is the revision a
+// revert restored.
+type Revision struct {
+	ID         int             `json:"id"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int             `json:"entity_id"`
+	Action     string          `json:"action"`
+	UserID     *int            `json:"user_id"`
+	UserName   string          `json:"username"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	RevertOf   *int            `json:"revert_of"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// TrashItem is a deleted place, category or location that can still be
+// restored until it is purged.
+type TrashItem struct {
+	EntityType string    `json:"type"`
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	DeletedAt  time.Time `json:"deleted_at"`
+}
+
+// Draw records one run of the place generator: the seed, the filters it was
+// asked for and the pool it picked from, so the result can be reproduced.
+type Draw struct {
+	ID             string          `json:"id"`
+	Seed           int64           `json:"seed"`
+	Filters        json.RawMessage `json:"filters"`
+	Candidates     []DrawCandidate `json:"candidates"`
+	PickedPlaceIDs []int           `json:"picked_place_ids"`
+	UserID         *int            `json:"-"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type DrawCandidate struct {
+	PlaceID  int    `json:"place_id"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+	Weight   int    `json:"weight"`
+}
+
+// Schedule picks a place on the days its cron expression matches. FeedToken
+// names its calendar feed, which calendar apps fetch without logging in.
+type Schedule struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	CronExpr     string    `json:"cron"`
+	Timezone     string    `json:"timezone"`
+	Filters      string    `json:"filters"`
+	SkipHolidays bool      `json:"skip_holidays"`
+	Enabled      bool      `json:"enabled"`
+	FeedToken    string    `json:"feed_token"`
+	WorkspaceID  int       `json:"-"`
+	CreatedAt    time.Time `json:"-"`
+	UpdatedAt    time.Time `json:"-"`
+}
+
+// SchedulePick is the place a schedule picked for one day. PickDate is the
+// calendar date in the schedule's timezone.
+type SchedulePick struct {
+	ScheduleID int       `json:"schedule_id"`
+	PickDate   time.Time `json:"-"`
+	PlaceID    int       `json:"place_id"`
+	DrawID     string    `json:"draw_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookSubscription struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event sent, or waiting to be sent, to one
+// subscription. NextAttemptAt is nil once the delivery has succeeded or
+// given up.
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	SubscriptionID int             `json:"subscription_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      string          `json:"last_error"`
+	ReplayOf       *int            `json:"replay_of"`
+	WorkspaceID    int             `json:"-"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// Kinds of per-user place preference.
+const (
+	PreferenceFavourite = "favourite"
+	PreferenceBlocked   = "blocked"
+)
+
+type PlacePreference struct {
+	PlaceID   int       `json:"place_id"`
+	PlaceName string    `json:"place_name"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserConstraint holds the requirements a user always brings to a group pick.
+type UserConstraint struct {
+	UserID      int      `json:"user_id"`
+	DietaryTags []string `json:"dietary_tags"`
+	MaxPrice    int      `json:"max_price"`
+	Budget      *float64 `json:"budget"`
+}
+
+type User struct {
+	ID       int    `json:"id"`
+	UserName string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"-"`
+	// Role is the user's instance-wide role, which predates workspaces.
+	// Only RoleAdmin means anything now: it makes the user a system admin,
+	// who manages the areas and dietary tags that every workspace shares.
+	// Creating or joining a workspace never changes it.
 	Role      int       `json:"role"`
 	CreatedAt time.Time `json:"-"`
 	UpdatedAt time.Time `json:"-"`
 }
 
+// DefaultWorkspaceID is the workspace that holds the data from before
+// workspaces existed. Anonymous callers see it.
+const DefaultWorkspaceID = 1
+
+// Workspace is a team's own set of places, categories, locations, schedules
+// and webhooks.
+type Workspace struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// WorkspaceMember gives a user a role in a workspace. Roles are the same
+// RoleUser, RoleModerator and RoleAdmin levels as before workspaces.
+type WorkspaceMember struct {
+	WorkspaceID int       `json:"workspace_id"`
+	UserID      int       `json:"user_id"`
+	Role        int       `json:"role"`
+	CreatedAt   time.Time `json:"-"`
+}
+
+// WorkspaceInvite lets one user join a workspace with Role by redeeming Code
+// before ExpiresAt.
+type WorkspaceInvite struct {
+	Code        string     `json:"code"`
+	WorkspaceID int        `json:"workspace_id"`
+	Role        int        `json:"role"`
+	CreatedBy   *int       `json:"-"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedBy  *int       `json:"-"`
+	AcceptedAt  *time.Time `json:"-"`
+	CreatedAt   time.Time  `json:"-"`
+}
+
+// Audit actions recorded by the auth and workspace handlers themselves.
+// Admin endpoints are recorded by the router under their own action names.
+const (
+	AuditLogin       = "auth.login"
+	AuditLoginFailed = "auth.login_failed"
+	AuditLogout      = "auth.logout"
+	AuditRegister    = "auth.register"
+	AuditJoin        = "member.join"
+)
+
+// AuditEntry is one administrative or auth event. TargetID is a string so
+// that it can hold a list of IDs or an email address. Summary is the request
+// body with secrets redacted, cut short when long. WorkspaceID, when set,
+// files the entry under that workspace rather than the request's, as for a
+// login.
+type AuditEntry struct {
+	ID          int64     `json:"id"`
+	WorkspaceID int       `json:"-"`
+	ActorID     *int      `json:"actor_id"`
+	ActorName   string    `json:"actor_name"`
+	Action      string    `json:"action"`
+	TargetType  string    `json:"target_type"`
+	TargetID    string    `json:"target_id"`
+	RequestID   string    `json:"request_id"`
+	IP          string    `json:"ip"`
+	Status      int       `json:"status"`
+	Summary     string    `json:"summary"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
 type Token struct {
 	ID     int
 	UserID int
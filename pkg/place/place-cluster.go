@@ -0,0 +1,126 @@
+package place
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+const maxClusterZoom = 22
+
+// ClusterDto is a group of places that fall in the same geohash cell.
+type ClusterDto struct {
+	Geohash       string  `json:"geohash"`
+	Count         int     `json:"count"`
+	Lat           float64 `json:"lat"`
+	Lon           float64 `json:"lon"`
+	SamplePlaceID int     `json:"sample_place_id"`
+}
+
+type ClusterResultDto struct {
+	Zoom      int          `json:"zoom"`
+	Precision int          `json:"precision"`
+	Clusters  []ClusterDto `json:"clusters"`
+}
+
+// clusterPrecision picks the geohash length whose cells are roughly an
+// eighth of a web map tile wide at the zoom level. A tile spans 360/2^zoom
+// degrees of longitude and a geohash of length p spans 360/2^ceil(5p/2).
+func clusterPrecision(zoom int) int {
+	p := (2*(zoom+3) + 4) / 5
+	if p < 1 {
+		p = 1
+	}
+	if p > geo.MaxGeohashPrecision {
+		p = geo.MaxGeohashPrecision
+	}
+	return p
+}
+
+// GetPlaceClusters groups the places inside bbox into geohash cells sized for
+// the zoom level. It accepts the same filters as GetAllPlaces. Each cluster
+// reports its centroid and the lowest place ID in it as a sample.
+func GetPlaceClusters(index *PlaceIndex, areaRepo area.AreaRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := ParsePlaceFilter(r)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+		if filter.BBox == nil {
+			utils.ErrorJSON(w, errors.New("bbox is required"), http.StatusBadRequest)
+			return
+		}
+
+		zoom, err := strconv.Atoi(r.URL.Query().Get("zoom"))
+		if err != nil || zoom < 0 || zoom > maxClusterZoom {
+			utils.ErrorJSON(w, fmt.Errorf("zoom must be between 0 and %d", maxClusterZoom), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		err = filter.ResolveArea(ctx, areaRepo)
+		if errors.Is(err, area.ErrAreaNotFound) {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		places, err := index.snapshot(ctx)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		result := ClusterResultDto{Zoom: zoom, Precision: clusterPrecision(zoom), Clusters: []ClusterDto{}}
+		cs := filter.criteria()
+		byHash := make(map[string]*ClusterDto)
+		for _, p := range places {
+			if !matchesAll(cs, p.Place) {
+				continue
+			}
+
+			hash := p.Geohash[:result.Precision]
+			c, ok := byHash[hash]
+			if !ok {
+				c = &ClusterDto{Geohash: hash, SamplePlaceID: p.Place.ID}
+				byHash[hash] = c
+			}
+			c.Count++
+			// Lat and Lon hold running sums until the centroid is taken below.
+			c.Lat += p.Lat
+			c.Lon += p.Lon
+			if p.Place.ID < c.SamplePlaceID {
+				c.SamplePlaceID = p.Place.ID
+			}
+		}
+
+		for _, c := range byHash {
+			c.Lat /= float64(c.Count)
+			c.Lon /= float64(c.Count)
+			result.Clusters = append(result.Clusters, *c)
+		}
+		sort.Slice(result.Clusters, func(i, j int) bool {
+			return result.Clusters[i].Geohash < result.Clusters[j].Geohash
+		})
+
+		err = utils.WriteJSON(w, http.StatusOK, result, "result")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package place
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
+)
+
+const (
+	// duplicateSimilarity is the least name similarity, from 0 to 1, at
+	// which two places are taken for the same one.
+	duplicateSimilarity = 0.75
+	// duplicateUnplacedSimilarity applies instead when neither coordinates
+	// nor a location tie the two places to the same spot.
+	duplicateUnplacedSimilarity = 0.9
+	// duplicateDistanceKm is how far apart two places with coordinates can
+	// be and still be the same one.
+	duplicateDistanceKm = 0.15
+)
+
+// nameStopWords carry no weight when names are compared.
+var nameStopWords = map[string]bool{"the": true, "and": true}
+
+// DuplicateDto is a place that is likely the same as another one.
+// DistanceKm is missing when either place has no coordinates.
+type DuplicateDto struct {
+	ID         int      `json:"id"`
+	Name       string   `json:"name"`
+	Location   string   `json:"location"`
+	Similarity float64  `json:"similarity"`
+	DistanceKm *float64 `json:"distance_km"`
+}
+
+// DuplicatePairDto is two places that are likely the same one.
+type DuplicatePairDto struct {
+	Places     [2]*models.Place `json:"places"`
+	Similarity float64          `json:"similarity"`
+	DistanceKm *float64         `json:"distance_km"`
+}
+
+// SavedPlaceDto is returned by EditPlace. Duplicates lists other places that
+// look like the one just saved, so that editors can merge them.
+type SavedPlaceDto struct {
+	ID         int            `json:"id"`
+	Duplicates []DuplicateDto `json:"duplicates"`
+}
+
+type MergeDto struct {
+	Into int `json:"into"`
+}
+
+// normalizeName lowercases the name and drops punctuation, spacing and stop
+// words, so that "The Ah Hock's" and "ah hock s" compare equal.
+func normalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var b strings.Builder
+	for _, word := range words {
+		if !nameStopWords[word] {
+			b.WriteString(word)
+		}
+	}
+	return b.String()
+}
+
+// nameSimilarity is the Dice coefficient of the character bigrams of the
+// two normalized names: 1 for the same name, 0 for nothing in common.
+func nameSimilarity(a, b string) float64 {
+	ra, rb := []rune(normalizeName(a)), []rune(normalizeName(b))
+	if len(ra) < 2 || len(rb) < 2 {
+		if string(ra) == string(rb) && len(ra) > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	bigrams := make(map[[2]rune]int, len(ra)-1)
+	for i := 0; i < len(ra)-1; i++ {
+		bigrams[[2]rune{ra[i], ra[i+1]}]++
+	}
+
+	shared := 0
+	for i := 0; i < len(rb)-1; i++ {
+		bigram := [2]rune{rb[i], rb[i+1]}
+		if bigrams[bigram] > 0 {
+			bigrams[bigram]--
+			shared++
+		}
+	}
+
+	return float64(2*shared) / float64(len(ra)-1+len(rb)-1)
+}
+
+// matchDuplicate reports whether a and b are likely the same place. Places
+// with coordinates must be close together; otherwise they must share a
+// location, or failing that have nearly the same name.
+func matchDuplicate(a, b *models.Place) (float64, *float64, bool) {
+	similarity := nameSimilarity(a.Name, b.Name)
+	if similarity < duplicateSimilarity {
+		return similarity, nil, false
+	}
+
+	latA, lonA, okA := geo.ParseLatLon(a.Lat, a.Lon)
+	latB, lonB, okB := geo.ParseLatLon(b.Lat, b.Lon)
+	if okA && okB {
+		distance := geo.DistanceKm(latA, lonA, latB, lonB)
+		return similarity, &distance, distance <= duplicateDistanceKm
+	}
+
+	locationA, locationB := strings.TrimSpace(a.Location), strings.TrimSpace(b.Location)
+	if locationA != "" && strings.EqualFold(locationA, locationB) {
+		return similarity, nil, true
+	}
+
+	return similarity, nil, similarity >= duplicateUnplacedSimilarity
+}
+
+// findDuplicates returns the places that look like place, most similar
+// first.
+func findDuplicates(place *models.Place, places []*models.Place) []DuplicateDto {
+	duplicates := []DuplicateDto{}
+	for _, other := range places {
+		if other.ID == place.ID {
+			continue
+		}
+
+		similarity, distance, ok := matchDuplicate(place, other)
+		if ok {
+			duplicates = append(duplicates, DuplicateDto{
+				ID:         other.ID,
+				Name:       other.Name,
+				Location:   other.Location,
+				Similarity: similarity,
+				DistanceKm: distance,
+			})
+		}
+	}
+
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].Similarity > duplicates[j].Similarity
+	})
+	return duplicates
+}
+
+// placeDuplicates loads the workspace's places and returns those that look
+// like the place with the given ID.
+func placeDuplicates(ctx context.Context, repo PlaceRepository, id int) ([]DuplicateDto, error) {
+	places, err := repo.GetAllPlaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, place := range places {
+		if place.ID == id {
+			return findDuplicates(place, places), nil
+		}
+	}
+	return []DuplicateDto{}, nil
+}
+
+// GetDuplicatePlaces lists the pairs of places that are likely the same one,
+// most similar first. Every pair is compared, which is fine for the few
+// hundred places a workspace has.
+func GetDuplicatePlaces(repo PlaceRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		places, err := repo.GetAllPlaces(ctx)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		pairs := []DuplicatePairDto{}
+		for i, a := range places {
+			for _, b := range places[i+1:] {
+				similarity, distance, ok := matchDuplicate(a, b)
+				if ok {
+					pairs = append(pairs, DuplicatePairDto{
+						Places:     [2]*models.Place{a, b},
+						Similarity: similarity,
+						DistanceKm: distance,
+					})
+				}
+			}
+		}
+
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return pairs[i].Similarity > pairs[j].Similarity
+		})
+
+		err = utils.WriteJSON(w, http.StatusOK, pairs, "duplicates")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// MergePlace merges the place in the URL into the place named by into in
+// the body. Webhooks see the merged place deleted and the survivor updated,
+// and the merged place's ID redirects to the survivor from then on.
+//
+// Categories are not combined. A place has a single category, so there is
+// nothing to combine them into, and taking the duplicate's would quietly
+// recategorise the place being kept. The survivor's category wins unless it
+// has none; the duplicate's stays in its history for an admin to apply.
+func MergePlace(repo PlaceRepository, hooks webhook.Publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+
+		var payload MergeDto
+		err = json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		if payload.Into == 0 {
+			utils.ErrorJSON(w, errors.New("into must be the ID of the place to keep"), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		err = repo.MergePlaces(ctx, id, payload.Into)
+		if errors.Is(err, ErrMergeIntoItself) {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.ErrorJSON(w, errors.New("both places must exist and not be in the trash"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		hooks.Publish(ctx, webhook.EventPlaceDeleted, webhook.PlaceDeletedEvent{ID: id})
+		PublishPlace(ctx, repo, hooks, webhook.EventPlaceUpdated, payload.Into)
+
+		place, err := repo.GetPlaceByID(ctx, payload.Into)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, place, "place")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
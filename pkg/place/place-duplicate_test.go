@@ -0,0 +1,81 @@
+package place
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Ah Hock Hokkien Mee", "ahhockhokkienmee"},
+		{"The Ah Hock's", "ahhocks"},
+		{"ah  hock-s", "ahhocks"},
+		{"Rice and Noodles", "ricenoodles"},
+		{"鸡饭 Chicken Rice", "鸡饭chickenrice"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeName(tt.in); got != tt.want {
+			t.Errorf("normalizeName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"Ah Hock Fried Hokkien Mee", "Ah Hock Hokkien Mee", 0.8},
+		{"Ah Hock Hokkien Mee", "ah hock hokkien mee", 1},
+		{"The Ah Hock's", "Ah Hock S", 1},
+		{"Ah Hock Hokkien Mee", "Sushi Tei", 0},
+		{"A", "A", 1},
+		{"A", "B", 0},
+		{"", "", 0},
+	}
+
+	for _, tt := range tests {
+		got := nameSimilarity(tt.a, tt.b)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("nameSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if back := nameSimilarity(tt.b, tt.a); back != got {
+			t.Errorf("nameSimilarity(%q, %q) = %v, but %v the other way round", tt.a, tt.b, got, back)
+		}
+	}
+}
+
+func TestMatchDuplicate(t *testing.T) {
+	fried := models.Place{Name: "Ah Hock Fried Hokkien Mee", Location: "Novena", Lat: "1.3204", Lon: "103.8437"}
+
+	tests := []struct {
+		name  string
+		other models.Place
+		want  bool
+	}{
+		{"close by", models.Place{Name: "Ah Hock Hokkien Mee", Location: "Novena Square", Lat: "1.3205", Lon: "103.8438"}, true},
+		{"far apart", models.Place{Name: "Ah Hock Hokkien Mee", Location: "Novena", Lat: "1.3504", Lon: "103.8437"}, false},
+		{"same location without coordinates", models.Place{Name: "Ah Hock Hokkien Mee", Location: "novena", Lat: " ", Lon: " "}, true},
+		{"other location without coordinates", models.Place{Name: "Ah Hock Hokkien Mee", Location: "Toa Payoh", Lat: " ", Lon: " "}, false},
+		{"nearly the same name anywhere", models.Place{Name: "Ah Hock Fried Hokkien Mee!", Location: "Toa Payoh", Lat: " ", Lon: " "}, true},
+		{"different name close by", models.Place{Name: "Tian Tian Chicken Rice", Location: "Novena", Lat: "1.3204", Lon: "103.8437"}, false},
+	}
+
+	for _, tt := range tests {
+		similarity, distance, ok := matchDuplicate(&fried, &tt.other)
+		if ok != tt.want {
+			t.Errorf("%s: matchDuplicate = %v (similarity %v), want %v", tt.name, ok, similarity, tt.want)
+		}
+		_, _, hasCoordinates := geo.ParseLatLon(tt.other.Lat, tt.other.Lon)
+		if (distance != nil) != hasCoordinates && similarity >= duplicateSimilarity {
+			t.Errorf("%s: distance = %v, want one only when both places have coordinates", tt.name, distance)
+		}
+	}
+}
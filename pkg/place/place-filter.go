@@ -0,0 +1,435 @@
+package place
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/dietary"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+const (
+	MinPriceLevel = 1
+	MaxPriceLevel = 4
+)
+
+// PlaceFilter holds the optional criteria accepted by the place listing and
+// generation endpoints. Zero values mean "no filter".
+type PlaceFilter struct {
+	// DietaryTags lists tag slugs that a place must all carry.
+	DietaryTags []string
+	// IsHalal and IsVegetarian are aliases for "any halal tag" and "any
+	// vegetarian tag" kept for existing clients.
+	IsHalal      bool
+	IsVegetarian bool
+	// Categories keeps places in any of these categories, ignoring case.
+	Categories []string
+	// OpenAt keeps places open at that moment in their area's local time.
+	// Places without opening hours are kept so that uncaptured data does not
+	// hide them. ResolveArea must load the area timezones first.
+	OpenAt *time.Time
+	// zones holds each area's timezone, and under 0 the timezone of places
+	// outside any area.
+	zones map[int]*time.Location
+	// MaxPrice keeps places at or below this price level. Places without a
+	// price level are kept so that uncaptured data does not hide them.
+	MaxPrice int
+	// Budget keeps places whose typical minimum spend in SGD fits within it.
+	// Places without spend data are kept.
+	Budget *float64
+	// MinRating keeps places whose average review rating is at least this
+	// value. Places without reviews are dropped when it is set.
+	MinRating float64
+	// RadiusKm keeps places within this distance of (Lat, Lon). Places
+	// without usable coordinates are dropped when it is set.
+	Lat, Lon float64
+	RadiusKm float64
+	// BBox keeps places inside the box. Places without usable coordinates
+	// are dropped when it is set.
+	BBox *geo.BBox
+	// Area names an area by ID or name. ResolveArea must load it before the
+	// filter is applied. Places inside its boundary are kept, as are places
+	// without usable coordinates that are assigned to it.
+	Area string
+	area *models.Area
+	// RecentPlaceIDs and BlockedPlaceIDs are filled from draw history and the
+	// caller's blocklist, not from the query string.
+	RecentPlaceIDs  map[int]bool
+	BlockedPlaceIDs map[int]bool
+}
+
+// ParsePlaceFilter reads the filter query parameters from the request.
+func ParsePlaceFilter(r *http.Request) (PlaceFilter, error) {
+	return ParsePlaceFilterQuery(r.URL.Query())
+}
+
+// ParsePlaceFilterQuery reads filter parameters from a parsed query string, as
+// sent to the endpoints or saved with a schedule.
+func ParsePlaceFilterQuery(queryParams url.Values) (PlaceFilter, error) {
+	var filter PlaceFilter
+	var err error
+
+	if v := queryParams.Get("is_halal"); v != "" {
+		filter.IsHalal, err = strconv.ParseBool(v)
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	if v := queryParams.Get("is_vegetarian"); v != "" {
+		filter.IsVegetarian, err = strconv.ParseBool(v)
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	for _, v := range queryParams["dietary"] {
+		for _, slug := range strings.Split(v, ",") {
+			slug = strings.TrimSpace(slug)
+			if slug != "" {
+				filter.DietaryTags = append(filter.DietaryTags, slug)
+			}
+		}
+	}
+
+	for _, v := range queryParams["category"] {
+		for _, category := range strings.Split(v, ",") {
+			category = strings.TrimSpace(category)
+			if category != "" {
+				filter.Categories = append(filter.Categories, category)
+			}
+		}
+	}
+
+	if v := queryParams.Get("open_now"); v != "" {
+		openNow, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, err
+		}
+		if openNow {
+			now := time.Now()
+			filter.OpenAt = &now
+		}
+	}
+
+	if v := queryParams.Get("open_at"); v != "" {
+		openAt, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, errors.New("open_at must be an RFC 3339 time")
+		}
+		filter.OpenAt = &openAt
+	}
+
+	if v := queryParams.Get("max_price"); v != "" {
+		filter.MaxPrice, err = strconv.Atoi(v)
+		if err != nil {
+			return filter, err
+		}
+		if filter.MaxPrice < MinPriceLevel || filter.MaxPrice > MaxPriceLevel {
+			return filter, errors.New("max_price must be between 1 and 4")
+		}
+	}
+
+	if v := queryParams.Get("budget"); v != "" {
+		budget, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return filter, err
+		}
+		if budget < 0 {
+			return filter, errors.New("budget must not be negative")
+		}
+		filter.Budget = &budget
+	}
+
+	if v := queryParams.Get("min_rating"); v != "" {
+		filter.MinRating, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return filter, err
+		}
+		if filter.MinRating < models.MinRating || filter.MinRating > models.MaxRating {
+			return filter, errors.New("min_rating must be between 1 and 5")
+		}
+	}
+
+	lat, lon, radius := queryParams.Get("lat"), queryParams.Get("lon"), queryParams.Get("radius_km")
+	if lat != "" || lon != "" || radius != "" {
+		var ok bool
+		filter.Lat, filter.Lon, ok = geo.ParseLatLon(lat, lon)
+		if !ok {
+			return filter, errors.New("lat and lon must be valid coordinates")
+		}
+		filter.RadiusKm, err = strconv.ParseFloat(radius, 64)
+		if err != nil || filter.RadiusKm <= 0 {
+			return filter, errors.New("radius_km must be a positive number")
+		}
+	}
+
+	if v := queryParams.Get("bbox"); v != "" {
+		bbox, err := geo.ParseBBox(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.BBox = &bbox
+	}
+
+	filter.Area = strings.TrimSpace(queryParams.Get("area"))
+
+	return filter, nil
+}
+
+// ResolveArea loads the area named by the filter and, for OpenAt, the
+// timezone of every area. It returns area.ErrAreaNotFound for unknown areas.
+func (f *PlaceFilter) ResolveArea(ctx context.Context, areas area.AreaRepository) error {
+	if f.OpenAt != nil {
+		zones, err := area.Timezones(ctx, areas)
+		if err != nil {
+			return err
+		}
+		zones[0], err = time.LoadLocation(area.DefaultTimezone)
+		if err != nil {
+			return err
+		}
+		f.zones = zones
+	}
+
+	if f.Area == "" {
+		return nil
+	}
+
+	a, err := area.Lookup(ctx, areas, f.Area)
+	if err != nil {
+		return err
+	}
+	f.area = a
+
+	return nil
+}
+
+// localTime returns t in the timezone of the place's area, or in
+// area.DefaultTimezone for places outside any area.
+func (f PlaceFilter) localTime(p *models.Place, t time.Time) time.Time {
+	if p.AreaID != nil {
+		if loc, ok := f.zones[*p.AreaID]; ok {
+			return t.In(loc)
+		}
+	}
+	if loc, ok := f.zones[0]; ok {
+		return t.In(loc)
+	}
+	return t
+}
+
+// Filter stages group criteria for reporting which part of a filter removed
+// candidates.
+const (
+	StageDietary       = "dietary"
+	StageCategory      = "category"
+	StageOpenHours     = "open-hours"
+	StagePrice         = "price"
+	StageRating        = "rating"
+	StageArea          = "area"
+	StageDistance      = "distance"
+	StageRecentHistory = "recent-history"
+	StageBlocklist     = "blocklist"
+)
+
+// stageOrder is the order in which Explain reports stages.
+var stageOrder = []string{
+	StageDietary,
+	StageCategory,
+	StageOpenHours,
+	StagePrice,
+	StageRating,
+	StageArea,
+	StageDistance,
+	StageRecentHistory,
+	StageBlocklist,
+}
+
+// criterion is a single named test that a place must pass.
+type criterion struct {
+	Stage  string
+	Detail string
+	Keep   func(p *models.Place) bool
+}
+
+// criteria breaks the filter down into the individual tests it applies.
+func (f PlaceFilter) criteria() []criterion {
+	var cs []criterion
+
+	if f.IsHalal {
+		cs = append(cs, criterion{StageDietary, "is_halal", func(p *models.Place) bool {
+			return p.HasDietaryTag(dietary.HalalTags...)
+		}})
+	}
+
+	if f.IsVegetarian {
+		cs = append(cs, criterion{StageDietary, "is_vegetarian", func(p *models.Place) bool {
+			return p.HasDietaryTag(dietary.VegetarianTags...)
+		}})
+	}
+
+	for _, slug := range f.DietaryTags {
+		slug := slug
+		cs = append(cs, criterion{StageDietary, "dietary " + slug, func(p *models.Place) bool {
+			return p.HasDietaryTag(slug)
+		}})
+	}
+
+	if len(f.Categories) > 0 {
+		cs = append(cs, criterion{StageCategory, "category " + strings.Join(f.Categories, ","), func(p *models.Place) bool {
+			for _, category := range f.Categories {
+				if strings.EqualFold(p.Category, category) {
+					return true
+				}
+			}
+			return false
+		}})
+	}
+
+	if f.OpenAt != nil {
+		cs = append(cs, criterion{StageOpenHours, "open at " + f.OpenAt.Format(time.RFC3339), func(p *models.Place) bool {
+			return len(p.OpeningHours) == 0 || p.OpenAt(f.localTime(p, *f.OpenAt))
+		}})
+	}
+
+	if f.MaxPrice > 0 {
+		cs = append(cs, criterion{StagePrice, fmt.Sprintf("max_price %d", f.MaxPrice), func(p *models.Place) bool {
+			return p.PriceLevel <= f.MaxPrice
+		}})
+	}
+
+	if f.Budget != nil {
+		cs = append(cs, criterion{StagePrice, fmt.Sprintf("budget %.2f", *f.Budget), func(p *models.Place) bool {
+			return p.MinSpend == nil || *p.MinSpend <= *f.Budget
+		}})
+	}
+
+	if f.MinRating > 0 {
+		cs = append(cs, criterion{StageRating, fmt.Sprintf("min_rating %g", f.MinRating), func(p *models.Place) bool {
+			return p.RatingCount > 0 && p.AverageRating >= f.MinRating
+		}})
+	}
+
+	if f.area != nil {
+		a := f.area
+		cs = append(cs, criterion{StageArea, "area " + a.Name, func(p *models.Place) bool {
+			if lat, lon, ok := geo.ParseLatLon(p.Lat, p.Lon); ok {
+				return a.Boundary.Contains(lat, lon)
+			}
+			return p.AreaID != nil && *p.AreaID == a.ID
+		}})
+	}
+
+	if f.RadiusKm > 0 {
+		cs = append(cs, criterion{StageDistance, fmt.Sprintf("within %gkm", f.RadiusKm), func(p *models.Place) bool {
+			lat, lon, ok := geo.ParseLatLon(p.Lat, p.Lon)
+			return ok && geo.DistanceKm(f.Lat, f.Lon, lat, lon) <= f.RadiusKm
+		}})
+	}
+
+	if f.BBox != nil {
+		cs = append(cs, criterion{StageDistance, "within bbox", func(p *models.Place) bool {
+			lat, lon, ok := geo.ParseLatLon(p.Lat, p.Lon)
+			return ok && f.BBox.Contains(lat, lon)
+		}})
+	}
+
+	if len(f.RecentPlaceIDs) > 0 {
+		cs = append(cs, criterion{StageRecentHistory, "recently picked", func(p *models.Place) bool {
+			return !f.RecentPlaceIDs[p.ID]
+		}})
+	}
+
+	if len(f.BlockedPlaceIDs) > 0 {
+		cs = append(cs, criterion{StageBlocklist, "blocklist", func(p *models.Place) bool {
+			return !f.BlockedPlaceIDs[p.ID]
+		}})
+	}
+
+	return cs
+}
+
+// Matches reports whether the place satisfies every criterion of the filter.
+func (f PlaceFilter) Matches(p *models.Place) bool {
+	return matchesAll(f.criteria(), p)
+}
+
+// Apply returns the places that match the filter, preserving their order.
+func (f PlaceFilter) Apply(places []*models.Place) []*models.Place {
+	cs := f.criteria()
+	var filtered []*models.Place
+	for _, p := range places {
+		if matchesAll(cs, p) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// StageResult reports how many candidates one filter stage removed.
+type StageResult struct {
+	Stage     string `json:"stage"`
+	Removed   int    `json:"removed"`
+	Remaining int    `json:"remaining"`
+}
+
+// FilterExplanation breaks a filter run down by stage.
+type FilterExplanation struct {
+	Total     int           `json:"total"`
+	Stages    []StageResult `json:"stages"`
+	Remaining int           `json:"remaining"`
+}
+
+// Explain applies the filter stage by stage and reports how many places each
+// stage removed. Stages the filter does not use are reported with nothing
+// removed so that clients see every stage that could apply.
+func (f PlaceFilter) Explain(places []*models.Place) FilterExplanation {
+	cs := f.criteria()
+	explanation := FilterExplanation{Total: len(places)}
+
+	remaining := places
+	for _, stage := range stageOrder {
+		before := len(remaining)
+		for _, c := range cs {
+			if c.Stage == stage {
+				remaining = keepMatching(remaining, c)
+			}
+		}
+		explanation.Stages = append(explanation.Stages, StageResult{
+			Stage:     stage,
+			Removed:   before - len(remaining),
+			Remaining: len(remaining),
+		})
+	}
+	explanation.Remaining = len(remaining)
+
+	return explanation
+}
+
+func matchesAll(cs []criterion, p *models.Place) bool {
+	for _, c := range cs {
+		if !c.Keep(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func keepMatching(places []*models.Place, c criterion) []*models.Place {
+	var kept []*models.Place
+	for _, p := range places {
+		if c.Keep(p) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,273 @@
+package place
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/draw"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/preference"
+)
+
+// favouriteWeight is how many times more likely a favourite place is to be
+// drawn than any other place when favourites are preferred.
+const favouriteWeight = 3
+
+const (
+	maxGenerateCount = 20
+	maxRecentDays    = 365
+)
+
+// ErrNoPlaceMatches is returned by the generator when the filters leave no
+// candidates.
+var ErrNoPlaceMatches = errors.New("no place matches the filters")
+
+// GenerateParams are the generator options read from a query string.
+type GenerateParams struct {
+	Filter PlaceFilter
+	// Count is 0 when the caller wants the single-place response.
+	Count            int
+	PreferFavourites bool
+	Explain          bool
+	RecentDays       int
+	Seed             int64
+	// Query is recorded with the draw.
+	Query url.Values
+}
+
+// GenerateOutcome is the result of one generator run. On ErrNoPlaceMatches
+// only Explanation is set.
+type GenerateOutcome struct {
+	Candidates  []*models.Place
+	Picked      []*models.Place
+	DrawID      string
+	Seed        int64
+	Explanation *FilterExplanation
+}
+
+// ParseGenerateParams reads the generator options, including the place
+// filter, from a query string.
+func ParseGenerateParams(query url.Values) (GenerateParams, error) {
+	params := GenerateParams{Query: query}
+	var err error
+
+	params.Filter, err = ParsePlaceFilterQuery(query)
+	if err != nil {
+		return params, err
+	}
+
+	if v := query.Get("count"); v != "" {
+		params.Count, err = strconv.Atoi(v)
+		if err != nil {
+			return params, err
+		}
+		if params.Count < 1 || params.Count > maxGenerateCount {
+			return params, fmt.Errorf("count must be between 1 and %d", maxGenerateCount)
+		}
+	}
+
+	if v := query.Get("prefer_favourites"); v != "" {
+		params.PreferFavourites, err = strconv.ParseBool(v)
+		if err != nil {
+			return params, err
+		}
+	}
+
+	if v := query.Get("explain"); v != "" {
+		params.Explain, err = strconv.ParseBool(v)
+		if err != nil {
+			return params, err
+		}
+	}
+
+	if v := query.Get("exclude_recent_days"); v != "" {
+		params.RecentDays, err = strconv.Atoi(v)
+		if err != nil {
+			return params, err
+		}
+		if params.RecentDays < 1 || params.RecentDays > maxRecentDays {
+			return params, fmt.Errorf("exclude_recent_days must be between 1 and %d", maxRecentDays)
+		}
+	}
+
+	params.Seed, err = parseSeed(query)
+	if err != nil {
+		return params, err
+	}
+
+	return params, nil
+}
+
+// Generate runs the place generator and records the draw. userID is the
+// caller whose blocklist, favourites and recent picks apply, or nil for
+// anonymous runs such as scheduled picks, which avoid the whole workspace's
+// recent picks.
+func Generate(ctx context.Context, repo PlaceRepository, areaRepo area.AreaRepository, prefRepo preference.PreferenceRepository, drawRepo draw.DrawRepository, params GenerateParams, userID *int) (*GenerateOutcome, error) {
+	filter := params.Filter
+
+	err := filter.ResolveArea(ctx, areaRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.RecentDays > 0 {
+		filter.RecentPlaceIDs, err = drawRepo.GetPickedPlaceIDsSince(ctx, time.Now().AddDate(0, 0, -params.RecentDays), userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var favourites map[int]bool
+	if userID != nil {
+		filter.BlockedPlaceIDs, err = prefRepo.GetPlaceIDs(ctx, *userID, models.PreferenceBlocked)
+		if err != nil {
+			return nil, err
+		}
+
+		if params.PreferFavourites {
+			favourites, err = prefRepo.GetPlaceIDs(ctx, *userID, models.PreferenceFavourite)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	allPlaces, err := repo.GetAllPlaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := &GenerateOutcome{Seed: params.Seed}
+	outcome.Candidates = filter.Apply(allPlaces)
+	if params.Explain {
+		e := filter.Explain(allPlaces)
+		outcome.Explanation = &e
+	}
+
+	if len(outcome.Candidates) == 0 {
+		return outcome, ErrNoPlaceMatches
+	}
+
+	n := params.Count
+	if n == 0 {
+		n = 1
+	}
+	rng := rand.New(rand.NewSource(params.Seed))
+	outcome.Picked = pickPlaces(rng, outcome.Candidates, favourites, n)
+
+	outcome.DrawID, err = recordDraw(ctx, drawRepo, params.Seed, params.Query, outcome.Candidates, favourites, outcome.Picked, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return outcome, nil
+}
+
+// parseSeed returns the seed given in the query string, or a fresh random one.
+// Fresh seeds come from crypto/rand because the global math/rand source is
+// not seeded for this module's Go version.
+func parseSeed(query url.Values) (int64, error) {
+	if v := query.Get("seed"); v != "" {
+		return strconv.ParseInt(v, 10, 64)
+	}
+
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(b[:]) >> 1), nil
+}
+
+// pickPlace draws one place at random. Places in favourites are weighted by
+// favouriteWeight; a nil map gives every place the same chance.
+func pickPlace(rng *rand.Rand, places []*models.Place, favourites map[int]bool) *models.Place {
+	total := 0
+	for _, p := range places {
+		total += placeWeight(p, favourites)
+	}
+
+	n := rng.Intn(total)
+	for _, p := range places {
+		n -= placeWeight(p, favourites)
+		if n < 0 {
+			return p
+		}
+	}
+	return places[len(places)-1]
+}
+
+// pickPlaces draws up to n distinct places without replacement, applying the
+// same weighting as pickPlace to every draw.
+func pickPlaces(rng *rand.Rand, places []*models.Place, favourites map[int]bool, n int) []*models.Place {
+	remaining := append([]*models.Place(nil), places...)
+	picked := make([]*models.Place, 0, n)
+	for len(picked) < n && len(remaining) > 0 {
+		p := pickPlace(rng, remaining, favourites)
+		picked = append(picked, p)
+		for i := range remaining {
+			if remaining[i] == p {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	return picked
+}
+
+func placeWeight(p *models.Place, favourites map[int]bool) int {
+	if favourites[p.ID] {
+		return favouriteWeight
+	}
+	return 1
+}
+
+// recordDraw stores the draw with a snapshot of its candidate pool and
+// returns the new draw's ID.
+func recordDraw(ctx context.Context, drawRepo draw.DrawRepository, seed int64, filters interface{}, candidates []*models.Place, favourites map[int]bool, picked []*models.Place, userID *int) (string, error) {
+	id, err := draw.NewID()
+	if err != nil {
+		return "", err
+	}
+
+	filtersJSON, err := json.Marshal(filters)
+	if err != nil {
+		return "", err
+	}
+
+	d := models.Draw{
+		ID:             id,
+		Seed:           seed,
+		Filters:        filtersJSON,
+		Candidates:     make([]models.DrawCandidate, 0, len(candidates)),
+		PickedPlaceIDs: make([]int, 0, len(picked)),
+		UserID:         userID,
+		CreatedAt:      time.Now(),
+	}
+	for _, p := range candidates {
+		d.Candidates = append(d.Candidates, models.DrawCandidate{
+			PlaceID:  p.ID,
+			Name:     p.Name,
+			Location: p.Location,
+			Weight:   placeWeight(p, favourites),
+		})
+	}
+	for _, p := range picked {
+		d.PickedPlaceIDs = append(d.PickedPlaceIDs, p.ID)
+	}
+
+	err = drawRepo.InsertDraw(ctx, d)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
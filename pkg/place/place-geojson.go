@@ -0,0 +1,137 @@
+package place
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+// FeatureCollection is a GeoJSON (RFC 7946) feature collection of places.
+type FeatureCollection struct {
+	Type     string     `json:"type"`
+	Features []*Feature `json:"features"`
+}
+
+// Feature is a place as a GeoJSON feature. Geometry is nil for places without
+// usable coordinates, which GeoJSON allows.
+type Feature struct {
+	Type       string          `json:"type"`
+	ID         int             `json:"id"`
+	Geometry   *Point          `json:"geometry"`
+	Properties PlaceProperties `json:"properties"`
+}
+
+// Point holds its position as [longitude, latitude], the GeoJSON order.
+type Point struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type PlaceProperties struct {
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	Category      string   `json:"category"`
+	Location      string   `json:"location"`
+	DietaryTags   []string `json:"dietary_tags"`
+	IsHalal       bool     `json:"is_halal"`
+	IsVegetarian  bool     `json:"is_vegetarian"`
+	PriceLevel    int      `json:"price_level"`
+	MinSpend      *float64 `json:"min_spend"`
+	MaxSpend      *float64 `json:"max_spend"`
+	AverageRating float64  `json:"average_rating"`
+	RatingCount   int      `json:"rating_count"`
+}
+
+// NewFeature converts a place to a GeoJSON feature.
+func NewFeature(p *models.Place) *Feature {
+	f := &Feature{
+		Type: "Feature",
+		ID:   p.ID,
+		Properties: PlaceProperties{
+			Name:          p.Name,
+			Description:   p.Description,
+			Category:      p.Category,
+			Location:      p.Location,
+			DietaryTags:   DietaryTagSlugs(p),
+			IsHalal:       p.IsHalal,
+			IsVegetarian:  p.IsVegetarian,
+			PriceLevel:    p.PriceLevel,
+			MinSpend:      p.MinSpend,
+			MaxSpend:      p.MaxSpend,
+			AverageRating: p.AverageRating,
+			RatingCount:   p.RatingCount,
+		},
+	}
+
+	if lat, lon, ok := geo.ParseLatLon(p.Lat, p.Lon); ok {
+		f.Geometry = &Point{Type: "Point", Coordinates: [2]float64{lon, lat}}
+	}
+
+	return f
+}
+
+// DietaryTagSlugs returns the slugs of the place's dietary tags.
+func DietaryTagSlugs(p *models.Place) []string {
+	slugs := make([]string, 0, len(p.DietaryTags))
+	for _, tag := range p.DietaryTags {
+		slugs = append(slugs, tag.Slug)
+	}
+	return slugs
+}
+
+// GetPlacesGeoJSON returns the places matching the same filters as
+// GetAllPlaces, plus bbox, as a FeatureCollection for map rendering. Places
+// without coordinates cannot be drawn and are left out.
+func GetPlacesGeoJSON(repo PlaceRepository, areaRepo area.AreaRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := ParsePlaceFilter(r)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		err = filter.ResolveArea(ctx, areaRepo)
+		if errors.Is(err, area.ErrAreaNotFound) {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		places, err := repo.GetAllPlaces(ctx)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		collection := FeatureCollection{Type: "FeatureCollection", Features: []*Feature{}}
+		for _, p := range filter.Apply(places) {
+			f := NewFeature(p)
+			if f.Geometry != nil {
+				collection.Features = append(collection.Features, f)
+			}
+		}
+
+		js, err := json.Marshal(collection)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(js)
+	}
+}
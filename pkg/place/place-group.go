@@ -0,0 +1,265 @@
+package place
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/draw"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/middleware"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/preference"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+const maxGroupSize = 50
+
+// GroupProfileDto describes an ad-hoc member who has no account, or whose
+// account constraints should be supplemented for this pick.
+type GroupProfileDto struct {
+	Name            string   `json:"name"`
+	DietaryTags     []string `json:"dietary_tags"`
+	MaxPrice        int      `json:"max_price"`
+	Budget          *float64 `json:"budget"`
+	BlockedPlaceIDs []int    `json:"blocked_place_ids"`
+}
+
+type GroupRequestDto struct {
+	UserIDs  []int             `json:"user_ids"`
+	Profiles []GroupProfileDto `json:"profiles"`
+}
+
+// ErrNotMember is returned for user_ids that are not members of the
+// caller's workspace.
+var ErrNotMember = errors.New("every user in the group must be a member of the workspace")
+
+// GroupConflictDto explains which constraint removed the last candidates
+// when no place satisfies the whole group. Members with accounts are not
+// named, nor are their saved constraints shown, since they are private.
+type GroupConflictDto struct {
+	Message    string `json:"message"`
+	Member     string `json:"member"`
+	Stage      string `json:"stage"`
+	Constraint string `json:"constraint"`
+	Removed    int    `json:"removed"`
+}
+
+// groupMember pairs a member's label with the filter built from their
+// constraints. Private members' constraints are their saved account ones,
+// which a conflict must not reveal.
+type groupMember struct {
+	Label   string
+	Filter  PlaceFilter
+	Private bool
+}
+
+// GenerateGroupPlace picks a place that satisfies every member of a group.
+// Query-string filters apply to the whole group on top of the members'
+// constraints. user_ids must be members of the caller's workspace.
+func GenerateGroupPlace(repo PlaceRepository, areaRepo area.AreaRepository, prefRepo preference.PreferenceRepository, workspaceRepo workspace.WorkspaceRepository, drawRepo draw.DrawRepository, hooks webhook.Publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := ParsePlaceFilter(r)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		var payload GroupRequestDto
+		err = json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		seed, err := parseSeed(r.URL.Query())
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		size := len(payload.UserIDs) + len(payload.Profiles)
+		if size == 0 {
+			utils.ErrorJSON(w, errors.New("the group has no members"), http.StatusBadRequest)
+			return
+		}
+		if size > maxGroupSize {
+			utils.ErrorJSON(w, fmt.Errorf("a group can have at most %d members", maxGroupSize), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		err = filter.ResolveArea(ctx, areaRepo)
+		if errors.Is(err, area.ErrAreaNotFound) {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		members, err := loadGroupMembers(ctx, prefRepo, workspaceRepo, payload)
+		if errors.Is(err, ErrNotMember) {
+			utils.ErrorJSON(w, err, http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+
+		places, err := repo.GetAllPlaces(ctx)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		candidates, conflict := applyGroup(places, filter, members)
+		if conflict != nil {
+			err = utils.WriteJSON(w, http.StatusNotFound, conflict, "error")
+			if err != nil {
+				utils.ErrorJSON(w, err)
+			}
+			return
+		}
+
+		rng := rand.New(rand.NewSource(seed))
+		picked := pickPlace(rng, candidates, nil)
+
+		filters := map[string]interface{}{"query": r.URL.Query(), "group": payload}
+		var userID *int
+		if user, ok := middleware.UserFromContext(r.Context()); ok {
+			userID = &user.ID
+		}
+		drawID, err := recordDraw(ctx, drawRepo, seed, filters, candidates, nil, []*models.Place{picked}, userID)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		hooks.Publish(ctx, webhook.EventPickGenerated, webhook.PickEvent{
+			DrawID: drawID,
+			Seed:   seed,
+			Source: PickSourceGroup,
+			Places: []*models.Place{picked},
+		})
+
+		err = utils.WriteJSON(w, http.StatusOK, DrawnPlaceDto{Place: picked, DrawID: drawID, Seed: seed}, "place")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+		}
+	}
+}
+
+// loadGroupMembers turns account members and ad-hoc profiles into filters.
+// Account members must belong to the workspace in ctx.
+func loadGroupMembers(ctx context.Context, prefRepo preference.PreferenceRepository, workspaceRepo workspace.WorkspaceRepository, payload GroupRequestDto) ([]groupMember, error) {
+	var members []groupMember
+
+	for _, userID := range payload.UserIDs {
+		_, err := workspaceRepo.GetMember(ctx, workspace.FromContext(ctx), userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotMember
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		constraint, err := prefRepo.GetConstraint(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		blocked, err := prefRepo.GetPlaceIDs(ctx, userID, models.PreferenceBlocked)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, groupMember{
+			Label: "a member's saved constraints",
+			Filter: PlaceFilter{
+				DietaryTags:     constraint.DietaryTags,
+				MaxPrice:        constraint.MaxPrice,
+				Budget:          constraint.Budget,
+				BlockedPlaceIDs: blocked,
+			},
+			Private: true,
+		})
+	}
+
+	for i, profile := range payload.Profiles {
+		if profile.MaxPrice < 0 || profile.MaxPrice > MaxPriceLevel {
+			return nil, errors.New("max_price must be between 1 and 4")
+		}
+		if profile.Budget != nil && *profile.Budget < 0 {
+			return nil, errors.New("budget must not be negative")
+		}
+
+		label := profile.Name
+		if label == "" {
+			label = fmt.Sprintf("profile %d", i+1)
+		}
+
+		blocked := make(map[int]bool)
+		for _, id := range profile.BlockedPlaceIDs {
+			blocked[id] = true
+		}
+
+		members = append(members, groupMember{
+			Label: label,
+			Filter: PlaceFilter{
+				DietaryTags:     profile.DietaryTags,
+				MaxPrice:        profile.MaxPrice,
+				Budget:          profile.Budget,
+				BlockedPlaceIDs: blocked,
+			},
+		})
+	}
+
+	return members, nil
+}
+
+// applyGroup narrows places one constraint at a time so that, if nothing is
+// left, it can name the constraint that removed the last candidates.
+func applyGroup(places []*models.Place, filter PlaceFilter, members []groupMember) ([]*models.Place, *GroupConflictDto) {
+	members = append([]groupMember{{Label: "request filters", Filter: filter}}, members...)
+
+	if len(places) == 0 {
+		return nil, &GroupConflictDto{Message: "there are no places to choose from"}
+	}
+
+	candidates := places
+	for _, member := range members {
+		for _, c := range member.Filter.criteria() {
+			kept := keepMatching(candidates, c)
+			if len(kept) == 0 && member.Private {
+				return nil, &GroupConflictDto{
+					Message: fmt.Sprintf("no place satisfies everyone: %s removed the last %d candidates", member.Label, len(candidates)),
+					Removed: len(candidates),
+				}
+			}
+			if len(kept) == 0 {
+				return nil, &GroupConflictDto{
+					Message:    fmt.Sprintf("no place satisfies everyone: %s of %s removed the last %d candidates", c.Detail, member.Label, len(candidates)),
+					Member:     member.Label,
+					Stage:      c.Stage,
+					Constraint: c.Detail,
+					Removed:    len(candidates),
+				}
+			}
+			candidates = kept
+		}
+	}
+
+	return candidates, nil
+}
@@ -5,85 +5,274 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
-	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/julienschmidt/httprouter"
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/dietary"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/draw"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/middleware"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/preference"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/revision"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
 )
 
 // Dto
 type PlaceDto struct {
-	ID           int    `json:"id"`
-	Name         string `json:"name"`
-	Description  string `json:"description"`
-	Category     string `json:"category"`
-	IsHalal      bool   `json:"is_halal"`
-	IsVegetarian bool   `json:"is_vegetarian"`
-	Location     string `json:"location"`
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Category     string   `json:"category"`
+	DietaryTags  []string `json:"dietary_tags"`
+	IsHalal      bool     `json:"is_halal"`
+	IsVegetarian bool     `json:"is_vegetarian"`
+	Location     string   `json:"location"`
+	// Lat and Lon replace the place's coordinates when both are given.
+	Lat        string   `json:"lat"`
+	Lon        string   `json:"lon"`
+	AreaID     *int     `json:"area_id"`
+	PriceLevel int      `json:"price_level"`
+	MinSpend   *float64 `json:"min_spend"`
+	MaxSpend   *float64 `json:"max_spend"`
+	// OpeningHours replaces the place's hours; leave it out when they are
+	// unknown.
+	OpeningHours []models.OpeningPeriod `json:"opening_hours"`
 }
 
-func GeneratePlace(repo PlaceRepository) http.HandlerFunc {
+// ErrUnknownDietaryTag is returned by Fill for tag slugs that do not exist.
+var ErrUnknownDietaryTag = errors.New("unknown dietary tag")
+
+func (dto PlaceDto) Validate() error {
+	if dto.PriceLevel != 0 && (dto.PriceLevel < MinPriceLevel || dto.PriceLevel > MaxPriceLevel) {
+		return errors.New("price_level must be between 1 and 4")
+	}
+
+	if (dto.MinSpend != nil && *dto.MinSpend < 0) || (dto.MaxSpend != nil && *dto.MaxSpend < 0) {
+		return errors.New("spend must not be negative")
+	}
+
+	if dto.MinSpend != nil && dto.MaxSpend != nil && *dto.MinSpend > *dto.MaxSpend {
+		return errors.New("min_spend must not be greater than max_spend")
+	}
+
+	if dto.Lat != "" || dto.Lon != "" {
+		if _, _, ok := geo.ParseLatLon(dto.Lat, dto.Lon); !ok {
+			return errors.New("lat and lon must be valid coordinates")
+		}
+	}
+
+	for _, period := range dto.OpeningHours {
+		if period.Day < 0 || period.Day > 6 {
+			return errors.New("opening_hours day must be between 0 (Sunday) and 6 (Saturday)")
+		}
+		opens, ok := models.ClockMinutes(period.Opens)
+		if !ok || opens == 24*60 {
+			return errors.New("opening_hours opens must be a time such as 11:30")
+		}
+		closes, ok := models.ClockMinutes(period.Closes)
+		if !ok {
+			return errors.New("opening_hours closes must be a time such as 21:00 or 24:00")
+		}
+		if opens == closes {
+			return errors.New("opening_hours must not open and close at the same time")
+		}
+	}
+
+	return nil
+}
+
+// dietaryTagSlugs returns the tag slugs requested by the payload. Clients that
+// only send the legacy is_halal/is_vegetarian flags get the equivalent tags.
+func (dto PlaceDto) dietaryTagSlugs() []string {
+	if dto.DietaryTags != nil {
+		return dto.DietaryTags
+	}
+
+	var slugs []string
+	if dto.IsHalal {
+		slugs = append(slugs, dietary.TagHalalCertified)
+	}
+	if dto.IsVegetarian {
+		slugs = append(slugs, dietary.TagVegetarian)
+	}
+	return slugs
+}
+
+// Fill copies the fields that editors set onto place and resolves its
+// dietary tags. The place's ID and timestamps are left alone, and so are its
+// coordinates unless the payload has new ones.
+func (dto PlaceDto) Fill(ctx context.Context, tagRepo dietary.DietaryTagRepository, place *models.Place) error {
+	slugs := dietary.UniqueSlugs(dto.dietaryTagSlugs())
+	tags, err := tagRepo.GetDietaryTagsBySlugs(ctx, slugs)
+	if err != nil {
+		return err
+	}
+	if len(tags) != len(slugs) {
+		return ErrUnknownDietaryTag
+	}
+
+	place.Name = dto.Name
+	place.Description = dto.Description
+	place.Category = dto.Category
+	place.Location = dto.Location
+	if dto.Lat != "" && dto.Lon != "" {
+		place.Lat = dto.Lat
+		place.Lon = dto.Lon
+	}
+	place.AreaID = dto.AreaID
+	place.PriceLevel = dto.PriceLevel
+	place.MinSpend = dto.MinSpend
+	place.MaxSpend = dto.MaxSpend
+	place.OpeningHours = dto.OpeningHours
+	if place.OpeningHours == nil {
+		place.OpeningHours = []models.OpeningPeriod{}
+	}
+
+	var placeTags []models.DietaryTag
+	for _, tag := range tags {
+		placeTags = append(placeTags, *tag)
+	}
+	setDietaryTags(place, placeTags)
+	return nil
+}
+
+// GenerateResultDto is returned when the caller asks for several places with
+// count. Fewer places than requested are returned, with a message, when not
+// enough candidates match.
+type GenerateResultDto struct {
+	Places    []*models.Place `json:"places"`
+	Requested int             `json:"requested"`
+	Available int             `json:"available"`
+	Message   string          `json:"message,omitempty"`
+	DrawID    string          `json:"draw_id"`
+	Seed      int64           `json:"seed"`
+	// Explanation is set when the caller asks for explain=true.
+	Explanation *FilterExplanation `json:"explanation,omitempty"`
+}
+
+// DrawnPlaceDto is a single generated place along with how to reproduce it.
+type DrawnPlaceDto struct {
+	*models.Place
+	DrawID      string             `json:"draw_id"`
+	Seed        int64              `json:"seed"`
+	Explanation *FilterExplanation `json:"explanation,omitempty"`
+}
+
+// NoMatchDto is the error body returned when no place matches the filters.
+type NoMatchDto struct {
+	Message     string             `json:"message"`
+	Explanation *FilterExplanation `json:"explanation,omitempty"`
+}
+
+func GeneratePlace(repo PlaceRepository, areaRepo area.AreaRepository, prefRepo preference.PreferenceRepository, drawRepo draw.DrawRepository, hooks webhook.Publisher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		queryParams := r.URL.Query()
-		isHalalParam, isHalalExists := queryParams["is_halal"]
-		isVegetarianParam, isVegetarianExists := queryParams["is_vegetarian"]
+		params, err := ParseGenerateParams(r.URL.Query())
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
 
-		var isHalal, isVegetarian bool
-		var err error
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		var userID *int
+		if user, ok := middleware.UserFromContext(r.Context()); ok {
+			userID = &user.ID
+		}
 
-		if isHalalExists && len(isHalalParam) > 0 {
-			isHalal, err = strconv.ParseBool(isHalalParam[0])
+		outcome, err := Generate(ctx, repo, areaRepo, prefRepo, drawRepo, params, userID)
+		if errors.Is(err, area.ErrAreaNotFound) {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, ErrNoPlaceMatches) {
+			noMatch := NoMatchDto{Message: err.Error(), Explanation: outcome.Explanation}
+			err = utils.WriteJSON(w, http.StatusNotFound, noMatch, "error")
 			if err != nil {
 				utils.ErrorJSON(w, err)
-				return
 			}
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
 		}
 
-		if isVegetarianExists && len(isVegetarianParam) > 0 {
-			isVegetarian, err = strconv.ParseBool(isVegetarianParam[0])
+		hooks.Publish(ctx, webhook.EventPickGenerated, webhook.PickEvent{
+			DrawID: outcome.DrawID,
+			Seed:   outcome.Seed,
+			Source: PickSourceGenerate,
+			Places: outcome.Picked,
+		})
+
+		if params.Count == 0 {
+			drawn := DrawnPlaceDto{
+				Place:       outcome.Picked[0],
+				DrawID:      outcome.DrawID,
+				Seed:        outcome.Seed,
+				Explanation: outcome.Explanation,
+			}
+			err = utils.WriteJSON(w, http.StatusOK, drawn, "place")
 			if err != nil {
 				utils.ErrorJSON(w, err)
-				return
 			}
+			return
 		}
 
-		var places []*models.Place
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
-		if isHalalExists || isVegetarianExists {
-			places, err = repo.GetAllPlacesWithFilter(ctx, isHalal, isVegetarian)
-		} else {
-			places, err = repo.GetAllPlaces(ctx)
+		result := GenerateResultDto{
+			Places:      outcome.Picked,
+			Requested:   params.Count,
+			Available:   len(outcome.Candidates),
+			DrawID:      outcome.DrawID,
+			Seed:        outcome.Seed,
+			Explanation: outcome.Explanation,
 		}
-
-		if err != nil {
-			utils.ErrorJSON(w, err)
-			return
+		if result.Available < params.Count {
+			result.Message = fmt.Sprintf("only %d places match the filters", result.Available)
 		}
 
-		idx := rand.Intn(len(places))
-		err = utils.WriteJSON(w, http.StatusOK, places[idx], "place")
+		err = utils.WriteJSON(w, http.StatusOK, result, "result")
 		if err != nil {
 			utils.ErrorJSON(w, err)
 		}
 	}
 }
 
-func GetAllPlaces(repo PlaceRepository) http.HandlerFunc {
+func GetAllPlaces(repo PlaceRepository, areaRepo area.AreaRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		filter, err := ParsePlaceFilter(r)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
+
+		err = filter.ResolveArea(ctx, areaRepo)
+		if errors.Is(err, area.ErrAreaNotFound) {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
 		places, err := repo.GetAllPlaces(ctx)
 
 		if err != nil {
 			utils.ErrorJSON(w, err)
 			return
 		}
+		places = filter.Apply(places)
 
 		err = utils.WriteJSON(w, http.StatusOK, places, "places")
 		if err != nil {
@@ -93,18 +282,35 @@ func GetAllPlaces(repo PlaceRepository) http.HandlerFunc {
 	}
 }
 
+// GetPlaceByID returns the place in the URL. The ID of a place that was
+// merged into another redirects to the survivor.
 func GetPlaceByID(repo PlaceRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params := httprouter.ParamsFromContext(r.Context())
-		id, err := strconv.Atoi(params.ByName("id"))
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
 		if err != nil {
 			utils.ErrorJSON(w, err)
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 		place, err := repo.GetPlaceByID(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			to, err := repo.ResolvePlaceID(ctx, id)
+			if err != nil {
+				utils.ErrorJSON(w, err)
+				return
+			}
+			if to != id {
+				target := *r.URL
+				target.Path = strings.TrimSuffix(r.URL.Path, vars["id"]) + strconv.Itoa(to)
+				http.Redirect(w, r, target.String(), http.StatusMovedPermanently)
+				return
+			}
+			utils.ErrorJSON(w, errors.New("place not found"), http.StatusNotFound)
+			return
+		}
 		if err != nil {
 			utils.ErrorJSON(w, err)
 			return
@@ -118,16 +324,15 @@ func GetPlaceByID(repo PlaceRepository) http.HandlerFunc {
 	}
 }
 
-func DeletePlace(repo PlaceRepository) http.HandlerFunc {
+func DeletePlace(repo PlaceRepository, hooks webhook.Publisher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params := httprouter.ParamsFromContext(r.Context())
-		id, err := strconv.Atoi(params.ByName("id"))
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
 		if err != nil {
 			utils.ErrorJSON(w, err)
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 		// Check if ID exists
 		place, err := repo.GetPlaceByID(ctx, id)
@@ -138,6 +343,7 @@ func DeletePlace(repo PlaceRepository) http.HandlerFunc {
 
 		if place == nil {
 			utils.ErrorJSON(w, errors.New("ID does not exists"))
+			return
 		}
 
 		err = repo.DeletePlace(ctx, id)
@@ -146,6 +352,8 @@ func DeletePlace(repo PlaceRepository) http.HandlerFunc {
 			return
 		}
 
+		hooks.Publish(ctx, webhook.EventPlaceDeleted, webhook.PlaceDeletedEvent{ID: id})
+
 		err = utils.WriteJSON(w, http.StatusOK, nil, "response")
 		if err != nil {
 			utils.ErrorJSON(w, err)
@@ -154,25 +362,34 @@ func DeletePlace(repo PlaceRepository) http.HandlerFunc {
 	}
 }
 
-func DeletePlaces(repo PlaceRepository) http.HandlerFunc {
+func DeletePlaces(repo PlaceRepository, hooks webhook.Publisher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var idList []int
 
 		err := json.NewDecoder(r.Body).Decode(&idList)
 		if err != nil {
-			utils.ErrorJSON(w, err)
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
 		}
 
 		if len(idList) == 0 {
-			utils.ErrorJSON(w, errors.New("the ID list is empty"))
+			utils.ErrorJSON(w, errors.New("the ID list is empty"), http.StatusBadRequest)
+			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 
-		err = repo.DeletePlaces(ctx, idList)
+		// Only the places actually trashed are announced; the rest were
+		// missing, in another workspace or already in the trash.
+		deleted, err := repo.DeletePlaces(ctx, idList)
 		if err != nil {
 			utils.ErrorJSON(w, err)
+			return
+		}
+
+		for _, id := range deleted {
+			hooks.Publish(ctx, webhook.EventPlaceDeleted, webhook.PlaceDeletedEvent{ID: id})
 		}
 
 		err = utils.WriteJSON(w, http.StatusOK, "Deleted Successfully", "response")
@@ -182,7 +399,7 @@ func DeletePlaces(repo PlaceRepository) http.HandlerFunc {
 	}
 }
 
-func EditPlace(repo PlaceRepository) http.HandlerFunc {
+func EditPlace(repo PlaceRepository, tagRepo dietary.DietaryTagRepository, hooks webhook.Publisher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var payload PlaceDto
 
@@ -193,7 +410,13 @@ func EditPlace(repo PlaceRepository) http.HandlerFunc {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		err = payload.Validate()
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 		defer cancel()
 
 		var place models.Place
@@ -213,19 +436,27 @@ func EditPlace(repo PlaceRepository) http.HandlerFunc {
 		}
 
 		place.ID = payload.ID
-		place.Name = payload.Name
-		place.Description = payload.Description
-		place.Category = payload.Category
-		place.IsHalal = payload.IsHalal
-		place.IsVegetarian = payload.IsVegetarian
-		place.Location = payload.Location
-		place.Lat = " "
-		place.Lon = " "
-		place.CreatedAt = time.Now()
+		if place.ID == 0 {
+			place.Lat = " "
+			place.Lon = " "
+			place.CreatedAt = time.Now()
+		}
 		place.UpdatedAt = time.Now()
 
+		err = payload.Fill(ctx, tagRepo, &place)
+		if errors.Is(err, ErrUnknownDietaryTag) {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		event := webhook.EventPlaceUpdated
 		if place.ID == 0 {
-			err = repo.InsertPlace(ctx, place)
+			event = webhook.EventPlaceCreated
+			place.ID, err = repo.InsertPlace(ctx, place)
 			if err != nil {
 				utils.ErrorJSON(w, err)
 				return
@@ -238,6 +469,67 @@ func EditPlace(repo PlaceRepository) http.HandlerFunc {
 			}
 		}
 
+		PublishPlace(ctx, repo, hooks, event, place.ID)
+
+		// Duplicates are only a warning, so failing to look for them does
+		// not fail the save.
+		duplicates, err := placeDuplicates(ctx, repo, place.ID)
+		if err != nil {
+			log.Printf("place: looking for duplicates of %d: %v", place.ID, err)
+			duplicates = []DuplicateDto{}
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, SavedPlaceDto{ID: place.ID, Duplicates: duplicates}, "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// Sources of a pick.generated event.
+const (
+	PickSourceGenerate = "generate"
+	PickSourceGroup    = "group"
+	PickSourceSchedule = "schedule"
+	PickSourceSlack    = "slack"
+	PickSourceDiscord  = "discord"
+)
+
+// PublishPlace reloads the place so that subscribers see it as stored,
+// including derived fields such as its rating.
+func PublishPlace(ctx context.Context, repo PlaceRepository, hooks webhook.Publisher, event string, id int) {
+	place, err := repo.GetPlaceByID(ctx, id)
+	if err != nil {
+		log.Printf("webhook: loading place %d for %s: %v", id, event, err)
+		return
+	}
+	hooks.Publish(ctx, event, place)
+}
+
+// RevertPlace restores the place to how it was after the revision in the URL,
+// recording the restore as a new revision.
+func RevertPlace(repo PlaceRepository, revisionRepo revision.RevisionRepository, hooks webhook.Publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		var place models.Place
+		id, revisionID, ok := revision.ReadRevert(ctx, w, r, revisionRepo, models.EntityPlace, &place)
+		if !ok {
+			return
+		}
+
+		place.ID = id
+		place.UpdatedAt = time.Now()
+		err := repo.RestorePlace(ctx, place, revisionID)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		PublishPlace(ctx, repo, hooks, webhook.EventPlaceUpdated, id)
+
 		err = utils.WriteJSON(w, http.StatusOK, nil, "response")
 		if err != nil {
 			utils.ErrorJSON(w, err)
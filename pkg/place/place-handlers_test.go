@@ -0,0 +1,77 @@
+package place
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
+)
+
+// trashingRepo trashes the places it serves to the index and skips any other
+// ID, like the SQL repository does for places that are missing or already in
+// the trash.
+type trashingRepo struct {
+	staticRepo
+}
+
+func (r *trashingRepo) DeletePlaces(ctx context.Context, idList []int) ([]int, error) {
+	var deleted []int
+	for _, id := range idList {
+		for i, p := range r.places {
+			if p.ID == id {
+				r.places = append(r.places[:i], r.places[i+1:]...)
+				deleted = append(deleted, id)
+				break
+			}
+		}
+	}
+	return deleted, nil
+}
+
+type recordingPublisher []webhook.PlaceDeletedEvent
+
+func (p *recordingPublisher) Publish(ctx context.Context, event string, data interface{}) {
+	if event == webhook.EventPlaceDeleted {
+		*p = append(*p, data.(webhook.PlaceDeletedEvent))
+	}
+}
+
+func TestDeletePlacesAnnouncesOnlyTrashedPlaces(t *testing.T) {
+	repo := &trashingRepo{staticRepo{places: []*models.Place{
+		{ID: 1, Lat: "1.3000", Lon: "103.8000"},
+		{ID: 2, Lat: "1.3100", Lon: "103.8100"},
+	}}}
+	index := NewPlaceIndex(repo)
+	indexed := NewIndexedPlaceRepository(repo, index)
+	ctx := context.Background()
+
+	// Load the index before the delete so that removal is what clears it.
+	if _, err := index.Nearest(ctx, 1.3, 103.8, 10, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var hooks recordingPublisher
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/admin/deletePlaces", strings.NewReader(`[1, 99]`))
+	DeletePlaces(indexed, &hooks).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if want := (recordingPublisher{{ID: 1}}); !reflect.DeepEqual(hooks, want) {
+		t.Errorf("published %v, want %v", hooks, want)
+	}
+
+	found, err := index.Nearest(ctx, 1.3, 103.8, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := neighbourIDs(found); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("after the delete, nearest = %v, want [2]", got)
+	}
+}
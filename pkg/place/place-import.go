@@ -0,0 +1,370 @@
+package place
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/dietary"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
+)
+
+const (
+	maxImportRows  = 1000
+	maxImportBytes = 5 << 20
+)
+
+// importColumns are the CSV header names accepted by the import, matching the
+// JSON field names of ImportRowDto. id is accepted so that an export can be
+// imported again, but ignored: places are matched by name and location.
+var importColumns = map[string]bool{
+	"id":           true,
+	"name":         true,
+	"description":  true,
+	"category":     true,
+	"location":     true,
+	"dietary_tags": true,
+	"price_level":  true,
+	"min_spend":    true,
+	"max_spend":    true,
+	"lat":          true,
+	"lon":          true,
+	"area_id":      true,
+}
+
+// ImportRowDto is one place in an import file.
+type ImportRowDto struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Category    string   `json:"category"`
+	Location    string   `json:"location"`
+	DietaryTags []string `json:"dietary_tags"`
+	PriceLevel  int      `json:"price_level"`
+	MinSpend    *float64 `json:"min_spend"`
+	MaxSpend    *float64 `json:"max_spend"`
+	Lat         string   `json:"lat"`
+	Lon         string   `json:"lon"`
+	AreaID      *int     `json:"area_id"`
+}
+
+// ImportRowResultDto reports what happened to one row. Row counts from 1 and
+// excludes the CSV header.
+type ImportRowResultDto struct {
+	Row     int      `json:"row"`
+	Name    string   `json:"name"`
+	Action  string   `json:"action"`
+	PlaceID int      `json:"place_id,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+type ImportReportDto struct {
+	DryRun   bool                 `json:"dry_run"`
+	Created  int                  `json:"created"`
+	Updated  int                  `json:"updated"`
+	Rejected int                  `json:"rejected"`
+	Rows     []ImportRowResultDto `json:"rows"`
+}
+
+// ImportPlaces creates or updates places in bulk from a CSV or JSON body. The
+// format comes from ?format=csv|json or else the Content-Type. Rows that fail
+// validation are rejected and reported; the rest are applied in one
+// transaction, or only checked with ?dry_run=true.
+func ImportPlaces(repo PlaceRepository, tagRepo dietary.DietaryTagRepository, hooks webhook.Publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun := false
+		if v := r.URL.Query().Get("dry_run"); v != "" {
+			var err error
+			dryRun, err = strconv.ParseBool(v)
+			if err != nil {
+				utils.ErrorJSON(w, err, http.StatusBadRequest)
+				return
+			}
+		}
+
+		body := io.LimitReader(r.Body, maxImportBytes)
+
+		var rows []ImportRowDto
+		var rowErrors [][]string
+		var err error
+		switch importFormat(r) {
+		case "csv":
+			rows, rowErrors, err = readImportCSV(body)
+		case "json":
+			err = json.NewDecoder(body).Decode(&rows)
+			rowErrors = make([][]string, len(rows))
+		default:
+			err = errors.New("send text/csv or application/json, or set format=csv|json")
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if len(rows) == 0 {
+			utils.ErrorJSON(w, errors.New("the import has no rows"), http.StatusBadRequest)
+			return
+		}
+		if len(rows) > maxImportRows {
+			utils.ErrorJSON(w, fmt.Errorf("an import can have at most %d rows", maxImportRows), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+
+		tags, err := tagRepo.GetAllDietaryTags(ctx)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+		tagsBySlug := make(map[string]models.DietaryTag, len(tags))
+		for _, tag := range tags {
+			tagsBySlug[tag.Slug] = *tag
+		}
+
+		report := ImportReportDto{DryRun: dryRun, Rows: make([]ImportRowResultDto, len(rows))}
+		var places []models.Place
+		var placeRows []int
+		seen := make(map[string]int)
+
+		for i, row := range rows {
+			result := &report.Rows[i]
+			result.Row = i + 1
+			result.Name = row.Name
+
+			place, errs := row.toPlace(tagsBySlug)
+			errs = append(rowErrors[i], errs...)
+
+			key := strings.ToLower(place.Name) + "\x00" + strings.ToLower(place.Location)
+			if first, ok := seen[key]; ok {
+				errs = append(errs, fmt.Sprintf("duplicates row %d", first))
+			}
+
+			if len(errs) > 0 {
+				result.Action = ImportRejected
+				result.Errors = errs
+				report.Rejected++
+				continue
+			}
+
+			seen[key] = result.Row
+			places = append(places, place)
+			placeRows = append(placeRows, i)
+		}
+
+		if len(places) > 0 {
+			results, err := repo.ImportPlaces(ctx, places, dryRun)
+			if err != nil {
+				utils.ErrorJSON(w, err)
+				return
+			}
+
+			for j, result := range results {
+				row := &report.Rows[placeRows[j]]
+				row.Action = result.Action
+				row.PlaceID = result.PlaceID
+				if result.Action == ImportCreated {
+					report.Created++
+				} else {
+					report.Updated++
+				}
+
+				if dryRun {
+					continue
+				}
+				event := webhook.EventPlaceUpdated
+				if result.Action == ImportCreated {
+					event = webhook.EventPlaceCreated
+				}
+				PublishPlace(ctx, repo, hooks, event, result.PlaceID)
+			}
+
+			// Created rows have no ID once a dry run is rolled back.
+			if dryRun {
+				for _, i := range placeRows {
+					if report.Rows[i].Action == ImportCreated {
+						report.Rows[i].PlaceID = 0
+					}
+				}
+			}
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, report, "report")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func importFormat(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return strings.ToLower(f)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv", "application/csv":
+		return "csv"
+	case "application/json":
+		return "json"
+	}
+	return ""
+}
+
+// readImportCSV reads rows by header name. Values that cannot be parsed are
+// reported per row rather than failing the whole import. dietary_tags may be
+// separated by commas or semicolons.
+func readImportCSV(body io.Reader) ([]ImportRowDto, [][]string, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if !importColumns[header[i]] {
+			return nil, nil, fmt.Errorf("unknown CSV column %q", column)
+		}
+	}
+
+	var rows []ImportRowDto
+	var rowErrors [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var row ImportRowDto
+		var errs []string
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch header[i] {
+			case "name":
+				row.Name = value
+			case "description":
+				row.Description = value
+			case "category":
+				row.Category = value
+			case "location":
+				row.Location = value
+			case "dietary_tags":
+				row.DietaryTags = strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' })
+			case "price_level":
+				if value != "" {
+					row.PriceLevel, err = strconv.Atoi(value)
+					if err != nil {
+						errs = append(errs, "price_level must be a whole number")
+					}
+				}
+			case "min_spend", "max_spend":
+				if value != "" {
+					spend, err := strconv.ParseFloat(value, 64)
+					if err != nil {
+						errs = append(errs, header[i]+" must be a number")
+					} else if header[i] == "min_spend" {
+						row.MinSpend = &spend
+					} else {
+						row.MaxSpend = &spend
+					}
+				}
+			case "lat":
+				row.Lat = value
+			case "lon":
+				row.Lon = value
+			case "area_id":
+				if value != "" {
+					areaID, err := strconv.Atoi(value)
+					if err != nil {
+						errs = append(errs, "area_id must be a whole number")
+					} else {
+						row.AreaID = &areaID
+					}
+				}
+			}
+		}
+
+		rows = append(rows, row)
+		rowErrors = append(rowErrors, errs)
+	}
+
+	return rows, rowErrors, nil
+}
+
+// toPlace validates the row and converts it, collecting every problem rather
+// than stopping at the first.
+func (row ImportRowDto) toPlace(tagsBySlug map[string]models.DietaryTag) (models.Place, []string) {
+	var errs []string
+
+	row.Name = strings.TrimSpace(row.Name)
+	row.Category = strings.TrimSpace(row.Category)
+	row.Location = strings.TrimSpace(row.Location)
+	if row.Name == "" {
+		errs = append(errs, "name is required")
+	}
+	if row.Category == "" {
+		errs = append(errs, "category is required")
+	}
+	if row.Location == "" {
+		errs = append(errs, "location is required")
+	}
+
+	dto := PlaceDto{PriceLevel: row.PriceLevel, MinSpend: row.MinSpend, MaxSpend: row.MaxSpend}
+	if err := dto.Validate(); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if row.Lat != "" || row.Lon != "" {
+		if _, _, ok := geo.ParseLatLon(row.Lat, row.Lon); !ok {
+			errs = append(errs, "lat and lon must be valid coordinates")
+		}
+	}
+
+	place := models.Place{
+		Name:        row.Name,
+		Description: row.Description,
+		Category:    row.Category,
+		Location:    row.Location,
+		Lat:         row.Lat,
+		Lon:         row.Lon,
+		AreaID:      row.AreaID,
+		PriceLevel:  row.PriceLevel,
+		MinSpend:    row.MinSpend,
+		MaxSpend:    row.MaxSpend,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	seen := make(map[string]bool)
+	for _, slug := range row.DietaryTags {
+		slug = strings.TrimSpace(slug)
+		tag, ok := tagsBySlug[slug]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("unknown dietary tag %q", slug))
+			continue
+		}
+		if !seen[slug] {
+			seen[slug] = true
+			place.DietaryTags = append(place.DietaryTags, tag)
+		}
+	}
+
+	return place, errs
+}
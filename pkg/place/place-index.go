@@ -0,0 +1,369 @@
+package place
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+const (
+	// indexTTL bounds how stale the index can get when places are changed by
+	// another instance, or their ratings change through reviews, neither of
+	// which the repository wrapper sees.
+	indexTTL = 5 * time.Minute
+
+	// maxSearchKm is half the earth's circumference; a radius this large
+	// covers every point.
+	maxSearchKm = 20016.0
+	// firstNearestKm is the radius Nearest starts from before widening.
+	firstNearestKm = 0.5
+)
+
+// indexedPlace is a place with parsed coordinates and its full-precision
+// geohash.
+type indexedPlace struct {
+	Place   *models.Place
+	Lat     float64
+	Lon     float64
+	Geohash string
+}
+
+// Neighbour is a place found by a proximity query.
+type Neighbour struct {
+	Place      *models.Place
+	DistanceKm float64
+}
+
+// PlaceIndex is an in-memory geohash prefix index of the places that have
+// coordinates. Entries are kept sorted by geohash, so the places in any
+// geohash cell are a contiguous run found by binary search. Proximity queries
+// look only at the few cells covering the search circle instead of every
+// place.
+//
+// Each workspace has its own shard, chosen by the workspace in the context
+// of each call, with its own lock, so loading one workspace's places never
+// holds up queries in another. A shard loads lazily and is then kept in step
+// place by place with the writes made through IndexedPlaceRepository. It
+// reloads fully only after Invalidate or once indexTTL has passed. Updates
+// replace the entries slice rather than modifying it, so a slice handed out
+// by snapshot never changes under its reader.
+type PlaceIndex struct {
+	repo PlaceRepository
+
+	mu     sync.Mutex // guards shards
+	shards map[int]*indexShard
+}
+
+// indexShard is the index of one workspace's places.
+type indexShard struct {
+	mu       sync.Mutex
+	entries  []indexedPlace
+	loadedAt time.Time
+	stale    bool
+}
+
+func NewPlaceIndex(repo PlaceRepository) *PlaceIndex {
+	return &PlaceIndex{repo: repo, shards: make(map[int]*indexShard)}
+}
+
+// shard returns the shard of the workspace in ctx, creating it if needed.
+func (idx *PlaceIndex) shard(ctx context.Context) *indexShard {
+	id := workspace.FromContext(ctx)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	sh, ok := idx.shards[id]
+	if !ok {
+		sh = &indexShard{stale: true}
+		idx.shards[id] = sh
+	}
+	return sh
+}
+
+// Invalidate makes the next query in the workspace reload every place.
+func (idx *PlaceIndex) Invalidate(ctx context.Context) {
+	sh := idx.shard(ctx)
+	sh.mu.Lock()
+	sh.stale = true
+	sh.mu.Unlock()
+}
+
+// snapshot returns the workspace's indexed places sorted by geohash,
+// reloading them first if needed. The returned slice must not be modified.
+func (idx *PlaceIndex) snapshot(ctx context.Context) ([]indexedPlace, error) {
+	sh := idx.shard(ctx)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if !sh.stale && time.Since(sh.loadedAt) < indexTTL {
+		return sh.entries, nil
+	}
+
+	places, err := idx.repo.GetAllPlaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]indexedPlace, 0, len(places))
+	for _, p := range places {
+		if e, ok := newIndexedPlace(p); ok {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entryLess(entries[i], entries[j])
+	})
+
+	sh.entries = entries
+	sh.loadedAt = time.Now()
+	sh.stale = false
+	return sh.entries, nil
+}
+
+func newIndexedPlace(p *models.Place) (indexedPlace, bool) {
+	lat, lon, ok := geo.ParseLatLon(p.Lat, p.Lon)
+	if !ok {
+		return indexedPlace{}, false
+	}
+	return indexedPlace{
+		Place:   p,
+		Lat:     lat,
+		Lon:     lon,
+		Geohash: geo.EncodeGeohash(lat, lon, geo.MaxGeohashPrecision),
+	}, true
+}
+
+func entryLess(a, b indexedPlace) bool {
+	if a.Geohash != b.Geohash {
+		return a.Geohash < b.Geohash
+	}
+	return a.Place.ID < b.Place.ID
+}
+
+// Upsert adds or replaces a place in the workspace. A place without usable
+// coordinates is removed, since it can no longer be found by location.
+func (idx *PlaceIndex) Upsert(ctx context.Context, p *models.Place) {
+	sh := idx.shard(ctx)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if sh.stale {
+		return
+	}
+
+	entries := withoutPlace(sh.entries, p.ID)
+	if e, ok := newIndexedPlace(p); ok {
+		i := sort.Search(len(entries), func(i int) bool {
+			return !entryLess(entries[i], e)
+		})
+		entries = append(entries, indexedPlace{})
+		copy(entries[i+1:], entries[i:])
+		entries[i] = e
+	}
+	sh.entries = entries
+}
+
+// Remove drops the workspace's places with the given IDs.
+func (idx *PlaceIndex) Remove(ctx context.Context, ids ...int) {
+	sh := idx.shard(ctx)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	if sh.stale {
+		return
+	}
+
+	entries := sh.entries
+	for _, id := range ids {
+		entries = withoutPlace(entries, id)
+	}
+	sh.entries = entries
+}
+
+// withoutPlace returns a copy of entries without the place, or entries
+// itself when the place is not there.
+func withoutPlace(entries []indexedPlace, id int) []indexedPlace {
+	for i := range entries {
+		if entries[i].Place.ID == id {
+			out := make([]indexedPlace, 0, len(entries))
+			out = append(out, entries[:i]...)
+			return append(out, entries[i+1:]...)
+		}
+	}
+	return append(make([]indexedPlace, 0, len(entries)+1), entries...)
+}
+
+// WithinRadius returns the places within radiusKm of the point, nearest
+// first. keep, when not nil, further limits the places returned.
+func (idx *PlaceIndex) WithinRadius(ctx context.Context, lat, lon, radiusKm float64, keep func(p *models.Place) bool) ([]Neighbour, error) {
+	entries, err := idx.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return withinRadius(entries, lat, lon, radiusKm, keep), nil
+}
+
+// Nearest returns up to k places nearest to the point that keep accepts,
+// nearest first; a nil keep accepts every place. It searches circles of
+// doubling radius until one holds k places; everything inside a searched
+// circle has been seen, so those k are the nearest.
+func (idx *PlaceIndex) Nearest(ctx context.Context, lat, lon float64, k int, keep func(p *models.Place) bool) ([]Neighbour, error) {
+	entries, err := idx.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for radius := firstNearestKm; ; radius *= 2 {
+		found := withinRadius(entries, lat, lon, radius, keep)
+		if len(found) >= k || radius >= maxSearchKm {
+			if len(found) > k {
+				found = found[:k]
+			}
+			return found, nil
+		}
+	}
+}
+
+func withinRadius(entries []indexedPlace, lat, lon, radiusKm float64, keep func(p *models.Place) bool) []Neighbour {
+	var found []Neighbour
+	for _, prefix := range geo.GeohashesCovering(lat, lon, radiusKm, geo.GeohashPrecisionForRadius(lat, radiusKm)) {
+		i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].Geohash >= prefix
+		})
+		for ; i < len(entries) && strings.HasPrefix(entries[i].Geohash, prefix); i++ {
+			e := entries[i]
+			if keep != nil && !keep(e.Place) {
+				continue
+			}
+			if d := geo.DistanceKm(lat, lon, e.Lat, e.Lon); d <= radiusKm {
+				found = append(found, Neighbour{Place: e.Place, DistanceKm: d})
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].DistanceKm != found[j].DistanceKm {
+			return found[i].DistanceKm < found[j].DistanceKm
+		}
+		return found[i].Place.ID < found[j].Place.ID
+	})
+	return found
+}
+
+// IndexedPlaceRepository keeps a PlaceIndex in step with the places written
+// through it.
+type IndexedPlaceRepository struct {
+	PlaceRepository
+	index *PlaceIndex
+}
+
+var _ PlaceRepository = &IndexedPlaceRepository{}
+
+func NewIndexedPlaceRepository(repo PlaceRepository, index *PlaceIndex) *IndexedPlaceRepository {
+	return &IndexedPlaceRepository{PlaceRepository: repo, index: index}
+}
+
+// reindex reloads the places as stored and updates their index entries.
+// Places that are gone, such as ones a restore put back in the trash, are
+// removed. If a place cannot be loaded for any other reason the whole index
+// is reloaded on its next query instead.
+func (r *IndexedPlaceRepository) reindex(ctx context.Context, ids ...int) {
+	for _, id := range ids {
+		p, err := r.PlaceRepository.GetPlaceByID(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			r.index.Remove(ctx, id)
+			continue
+		}
+		if err != nil {
+			r.index.Invalidate(ctx)
+			return
+		}
+		r.index.Upsert(ctx, p)
+	}
+}
+
+func (r *IndexedPlaceRepository) InsertPlace(ctx context.Context, place models.Place) (int, error) {
+	id, err := r.PlaceRepository.InsertPlace(ctx, place)
+	if err == nil {
+		r.reindex(ctx, id)
+	}
+	return id, err
+}
+
+func (r *IndexedPlaceRepository) UpdatePlace(ctx context.Context, place models.Place) error {
+	err := r.PlaceRepository.UpdatePlace(ctx, place)
+	if err == nil {
+		r.reindex(ctx, place.ID)
+	}
+	return err
+}
+
+func (r *IndexedPlaceRepository) SavePlace(ctx context.Context, place models.Place, within func(tx *sql.Tx, placeID int) error) (int, error) {
+	id, err := r.PlaceRepository.SavePlace(ctx, place, within)
+	if err == nil {
+		r.reindex(ctx, id)
+	}
+	return id, err
+}
+
+func (r *IndexedPlaceRepository) RestorePlace(ctx context.Context, place models.Place, revisionID int) error {
+	err := r.PlaceRepository.RestorePlace(ctx, place, revisionID)
+	if err == nil {
+		r.reindex(ctx, place.ID)
+	}
+	return err
+}
+
+func (r *IndexedPlaceRepository) DeletePlace(ctx context.Context, id int) error {
+	err := r.PlaceRepository.DeletePlace(ctx, id)
+	if err == nil {
+		r.index.Remove(ctx, id)
+	}
+	return err
+}
+
+func (r *IndexedPlaceRepository) DeletePlaces(ctx context.Context, idList []int) ([]int, error) {
+	deleted, err := r.PlaceRepository.DeletePlaces(ctx, idList)
+	if err == nil && len(deleted) > 0 {
+		r.index.Remove(ctx, deleted...)
+	}
+	return deleted, err
+}
+
+func (r *IndexedPlaceRepository) UndeletePlace(ctx context.Context, id int) error {
+	err := r.PlaceRepository.UndeletePlace(ctx, id)
+	if err == nil {
+		r.reindex(ctx, id)
+	}
+	return err
+}
+
+func (r *IndexedPlaceRepository) MergePlaces(ctx context.Context, fromID, intoID int) error {
+	err := r.PlaceRepository.MergePlaces(ctx, fromID, intoID)
+	if err == nil {
+		r.index.Remove(ctx, fromID)
+		r.reindex(ctx, intoID)
+	}
+	return err
+}
+
+func (r *IndexedPlaceRepository) ImportPlaces(ctx context.Context, places []models.Place, dryRun bool) ([]ImportResult, error) {
+	results, err := r.PlaceRepository.ImportPlaces(ctx, places, dryRun)
+	if err == nil && !dryRun {
+		ids := make([]int, 0, len(results))
+		for _, result := range results {
+			ids = append(ids, result.PlaceID)
+		}
+		r.reindex(ctx, ids...)
+	}
+	return results, err
+}
@@ -0,0 +1,224 @@
+package place
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+// staticRepo serves a fixed set of places to the index and counts how often
+// they are loaded.
+type staticRepo struct {
+	PlaceRepository
+	places []*models.Place
+	loads  int
+}
+
+func (r *staticRepo) GetAllPlaces(ctx context.Context) ([]*models.Place, error) {
+	r.loads++
+	return r.places, nil
+}
+
+// syntheticPlaces scatters n places over Singapore. Every tenth place has no
+// coordinates, as places added by hand often don't.
+func syntheticPlaces(n int) []*models.Place {
+	rng := rand.New(rand.NewSource(1))
+	places := make([]*models.Place, n)
+	for i := range places {
+		places[i] = &models.Place{ID: i + 1, Lat: " ", Lon: " "}
+		if i%10 != 0 {
+			places[i].Lat = fmt.Sprint(1.22 + rng.Float64()*0.25)
+			places[i].Lon = fmt.Sprint(103.6 + rng.Float64()*0.4)
+		}
+	}
+	return places
+}
+
+// queryPoint spreads benchmark and test queries over the same area.
+func queryPoint(i int) (float64, float64) {
+	return 1.25 + float64(i%97)/97*0.2, 103.65 + float64(i%89)/89*0.3
+}
+
+// scan is the brute-force baseline: it parses and measures every place, as
+// the radius filter does. A negative radius means no limit; k of 0 means
+// every match.
+func scan(places []*models.Place, lat, lon, radiusKm float64, k int) []Neighbour {
+	var found []Neighbour
+	for _, p := range places {
+		plat, plon, ok := geo.ParseLatLon(p.Lat, p.Lon)
+		if !ok {
+			continue
+		}
+		d := geo.DistanceKm(lat, lon, plat, plon)
+		if radiusKm < 0 || d <= radiusKm {
+			found = append(found, Neighbour{Place: p, DistanceKm: d})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].DistanceKm != found[j].DistanceKm {
+			return found[i].DistanceKm < found[j].DistanceKm
+		}
+		return found[i].Place.ID < found[j].Place.ID
+	})
+	if k > 0 && len(found) > k {
+		found = found[:k]
+	}
+	return found
+}
+
+func neighbourIDs(found []Neighbour) []int {
+	ids := make([]int, len(found))
+	for i, n := range found {
+		ids[i] = n.Place.ID
+	}
+	return ids
+}
+
+func TestPlaceIndexMatchesScan(t *testing.T) {
+	places := syntheticPlaces(2000)
+	index := NewPlaceIndex(&staticRepo{places: places})
+	ctx := context.Background()
+	even := func(p *models.Place) bool { return p.ID%2 == 0 }
+
+	for i := 0; i < 50; i++ {
+		lat, lon := queryPoint(i * 7)
+
+		for _, k := range []int{1, 5, 25} {
+			got, err := index.Nearest(ctx, lat, lon, k, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := scan(places, lat, lon, -1, k); !reflect.DeepEqual(neighbourIDs(got), neighbourIDs(want)) {
+				t.Errorf("Nearest(%f, %f, %d) = %v, want %v", lat, lon, k, neighbourIDs(got), neighbourIDs(want))
+			}
+		}
+
+		for _, radius := range []float64{0.2, 1, 5} {
+			got, err := index.WithinRadius(ctx, lat, lon, radius, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := scan(places, lat, lon, radius, 0); !reflect.DeepEqual(neighbourIDs(got), neighbourIDs(want)) {
+				t.Errorf("WithinRadius(%f, %f, %g) = %v, want %v", lat, lon, radius, neighbourIDs(got), neighbourIDs(want))
+			}
+		}
+
+		got, err := index.Nearest(ctx, lat, lon, 5, even)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var kept []*models.Place
+		for _, p := range places {
+			if even(p) {
+				kept = append(kept, p)
+			}
+		}
+		if want := scan(kept, lat, lon, -1, 5); !reflect.DeepEqual(neighbourIDs(got), neighbourIDs(want)) {
+			t.Errorf("Nearest(%f, %f, 5, even) = %v, want %v", lat, lon, neighbourIDs(got), neighbourIDs(want))
+		}
+	}
+}
+
+func TestPlaceIndexUpsertAndRemove(t *testing.T) {
+	repo := &staticRepo{places: []*models.Place{
+		{ID: 1, Lat: "1.3000", Lon: "103.8000"},
+		{ID: 2, Lat: "1.3100", Lon: "103.8100"},
+	}}
+	index := NewPlaceIndex(repo)
+	ctx := context.Background()
+
+	nearest := func() []int {
+		found, err := index.Nearest(ctx, 1.3, 103.8, 10, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return neighbourIDs(found)
+	}
+
+	if got := nearest(); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("nearest = %v, want [1 2]", got)
+	}
+
+	index.Upsert(ctx, &models.Place{ID: 3, Lat: "1.3001", Lon: "103.8001"})
+	if got := nearest(); !reflect.DeepEqual(got, []int{1, 3, 2}) {
+		t.Errorf("after inserting 3, nearest = %v, want [1 3 2]", got)
+	}
+
+	index.Upsert(ctx, &models.Place{ID: 1, Lat: "1.3200", Lon: "103.8200"})
+	if got := nearest(); !reflect.DeepEqual(got, []int{3, 2, 1}) {
+		t.Errorf("after moving 1, nearest = %v, want [3 2 1]", got)
+	}
+
+	index.Upsert(ctx, &models.Place{ID: 2, Lat: " ", Lon: " "})
+	if got := nearest(); !reflect.DeepEqual(got, []int{3, 1}) {
+		t.Errorf("after clearing 2's coordinates, nearest = %v, want [3 1]", got)
+	}
+
+	index.Remove(ctx, 3)
+	if got := nearest(); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("after removing 3, nearest = %v, want [1]", got)
+	}
+
+	if repo.loads != 1 {
+		t.Errorf("places were loaded %d times, want once", repo.loads)
+	}
+
+	index.Invalidate(ctx)
+	if got := nearest(); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("after a reload, nearest = %v, want [1 2]", got)
+	}
+	if repo.loads != 2 {
+		t.Errorf("places were loaded %d times, want twice", repo.loads)
+	}
+}
+
+func benchmarkPlaces(b *testing.B) (*PlaceIndex, []*models.Place) {
+	places := syntheticPlaces(10000)
+	index := NewPlaceIndex(&staticRepo{places: places})
+	if _, err := index.Nearest(context.Background(), 1.32, 103.84, 1, nil); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	return index, places
+}
+
+func BenchmarkNearest(b *testing.B) {
+	index, _ := benchmarkPlaces(b)
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		lat, lon := queryPoint(i)
+		index.Nearest(ctx, lat, lon, 10, nil)
+	}
+}
+
+func BenchmarkNearestScan(b *testing.B) {
+	_, places := benchmarkPlaces(b)
+	for i := 0; i < b.N; i++ {
+		lat, lon := queryPoint(i)
+		scan(places, lat, lon, -1, 10)
+	}
+}
+
+func BenchmarkRadius(b *testing.B) {
+	index, _ := benchmarkPlaces(b)
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		lat, lon := queryPoint(i)
+		index.WithinRadius(ctx, lat, lon, 1, nil)
+	}
+}
+
+func BenchmarkRadiusScan(b *testing.B) {
+	_, places := benchmarkPlaces(b)
+	for i := 0; i < b.N; i++ {
+		lat, lon := queryPoint(i)
+		scan(places, lat, lon, 1, 0)
+	}
+}
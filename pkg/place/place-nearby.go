@@ -0,0 +1,103 @@
+package place
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+const (
+	defaultNearbyCount = 10
+	maxNearbyCount     = 100
+)
+
+type NearbyPlaceDto struct {
+	*models.Place
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// GetNearbyPlaces returns the k places nearest to lat and lon, or with
+// radius_km every place within that distance, nearest first. area limits
+// the search to one area.
+func GetNearbyPlaces(index *PlaceIndex, areaRepo area.AreaRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		lat, lon, ok := geo.ParseLatLon(query.Get("lat"), query.Get("lon"))
+		if !ok {
+			utils.ErrorJSON(w, errors.New("lat and lon must be valid coordinates"), http.StatusBadRequest)
+			return
+		}
+
+		k := defaultNearbyCount
+		if v := query.Get("k"); v != "" {
+			var err error
+			k, err = strconv.Atoi(v)
+			if err != nil || k < 1 || k > maxNearbyCount {
+				utils.ErrorJSON(w, fmt.Errorf("k must be between 1 and %d", maxNearbyCount), http.StatusBadRequest)
+				return
+			}
+		}
+
+		var radius float64
+		if v := query.Get("radius_km"); v != "" {
+			var err error
+			radius, err = strconv.ParseFloat(v, 64)
+			if err != nil || radius <= 0 {
+				utils.ErrorJSON(w, errors.New("radius_km must be a positive number"), http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		filter := PlaceFilter{Area: query.Get("area")}
+		err := filter.ResolveArea(ctx, areaRepo)
+		if errors.Is(err, area.ErrAreaNotFound) {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		var keep func(p *models.Place) bool
+		if cs := filter.criteria(); len(cs) > 0 {
+			keep = func(p *models.Place) bool {
+				return matchesAll(cs, p)
+			}
+		}
+
+		var neighbours []Neighbour
+		if radius > 0 {
+			neighbours, err = index.WithinRadius(ctx, lat, lon, radius, keep)
+		} else {
+			neighbours, err = index.Nearest(ctx, lat, lon, k, keep)
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		places := make([]NearbyPlaceDto, 0, len(neighbours))
+		for _, n := range neighbours {
+			places = append(places, NearbyPlaceDto{Place: n.Place, DistanceKm: n.DistanceKm})
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, places, "places")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
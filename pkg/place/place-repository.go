@@ -3,12 +3,18 @@ package place
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ngfenglong/food-randomizer-BE/pkg/dietary"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/middleware"
 	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/revision"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
 )
 
 var _ PlaceRepository = &SQLPlaceRepository{}
@@ -16,14 +22,29 @@ var _ PlaceRepository = &SQLPlaceRepository{}
 type PlaceRepository interface {
 	// Add more methods as needed
 	GetPlaceByID(ctx context.Context, id int) (*models.Place, error)
-	GetAllPlaces(ctx context.Context, category ...string) ([]*models.Place, error)
-	GetAllPlacesWithFilter(ctx context.Context, isHalal, isVegetarian bool) ([]*models.Place, error)
-	InsertPlace(ctx context.Context, place models.Place) error
+	GetAllPlaces(ctx context.Context) ([]*models.Place, error)
+	GetPlacesByIDs(ctx context.Context, ids []int) (map[int]*models.Place, error)
+	InsertPlace(ctx context.Context, place models.Place) (int, error)
 	UpdatePlace(ctx context.Context, place models.Place) error
+	SavePlace(ctx context.Context, place models.Place, within func(tx *sql.Tx, placeID int) error) (int, error)
+	RestorePlace(ctx context.Context, place models.Place, revisionID int) error
 	DeletePlace(ctx context.Context, id int) error
-	DeletePlaces(ctx context.Context, idList []int) error
+	DeletePlaces(ctx context.Context, idList []int) ([]int, error)
+	GetDeletedPlaces(ctx context.Context) ([]*models.TrashItem, error)
+	UndeletePlace(ctx context.Context, id int) error
+	PurgePlaces(ctx context.Context, before time.Time) (int, error)
+	ImportPlaces(ctx context.Context, places []models.Place, dryRun bool) ([]ImportResult, error)
+	EachPlace(ctx context.Context, fn func(place *models.Place) error) error
+	MergePlaces(ctx context.Context, fromID, intoID int) error
+	ResolvePlaceID(ctx context.Context, id int) (int, error)
 }
 
+// ErrMergeIntoItself is returned by MergePlaces when both IDs are the same.
+var ErrMergeIntoItself = errors.New("a place cannot be merged into itself")
+
+// SQLPlaceRepository reads and writes the places of the workspace in each
+// call's context. Deleted places stay in the trash, hidden from every read
+// but the trash's own, until they are purged.
 type SQLPlaceRepository struct {
 	db *sql.DB
 }
@@ -32,40 +53,91 @@ func NewSQLPlaceRepository(db *sql.DB) *SQLPlaceRepository {
 	return &SQLPlaceRepository{db: db}
 }
 
-func (r *SQLPlaceRepository) GetPlaceByID(ctx context.Context, id int) (*models.Place, error) {
-	query := `select id, name, description, is_halal, is_vegetarian, location, lat, lon, created_at, updated_at, category from place where id = ?`
+// placeColumns and placeTables are shared by every query that loads full
+// place rows so that scanPlace sees the same column order everywhere.
+const placeColumns = `id, name, description, location, lat, lon, area_id, price_level, min_spend, max_spend, coalesce(rating_count, 0), coalesce(rating_sum, 0), created_at, updated_at, category, opening_hours`
+const placeTables = `place left join place_rating on place_rating.place_id = place.id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
-	row := r.db.QueryRowContext(ctx, query, id)
+// queryer is a *sql.DB or *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func scanPlace(row rowScanner) (*models.Place, error) {
 	var place models.Place
+	var ratingSum int
+	var openingHours []byte
 	err := row.Scan(
 		&place.ID,
 		&place.Name,
 		&place.Description,
-		&place.IsHalal,
-		&place.IsVegetarian,
 		&place.Location,
 		&place.Lat,
 		&place.Lon,
+		&place.AreaID,
+		&place.PriceLevel,
+		&place.MinSpend,
+		&place.MaxSpend,
+		&place.RatingCount,
+		&ratingSum,
 		&place.CreatedAt,
 		&place.UpdatedAt,
 		&place.Category,
+		&openingHours,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	place.OpeningHours = []models.OpeningPeriod{}
+	if openingHours != nil {
+		err = json.Unmarshal(openingHours, &place.OpeningHours)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if place.RatingCount > 0 {
+		place.AverageRating = float64(ratingSum) / float64(place.RatingCount)
+	}
+
 	return &place, nil
 }
 
-func (r *SQLPlaceRepository) GetAllPlaces(ctx context.Context, category ...string) ([]*models.Place, error) {
-	where := ""
-	if len(category) > 0 {
-		println(category)
-		where = fmt.Sprintf("where category =  %s)", category)
+func (r *SQLPlaceRepository) GetPlaceByID(ctx context.Context, id int) (*models.Place, error) {
+	return loadPlace(ctx, r.db, id, false)
+}
+
+// loadPlace reads a place that is not in the trash with its dietary tags.
+// With lock the place row is locked until the end of q's transaction.
+func loadPlace(ctx context.Context, q queryer, id int, lock bool) (*models.Place, error) {
+	query := `select ` + placeColumns + ` from ` + placeTables + ` where id = ? and place.workspace_id = ? and place.deleted_at is null`
+	if lock {
+		query += ` for update`
+	}
+
+	place, err := scanPlace(q.QueryRowContext(ctx, query, id, workspace.FromContext(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := getDietaryTags(ctx, q, id)
+	if err != nil {
+		return nil, err
 	}
+	setDietaryTags(place, tags[id])
+
+	return place, nil
+}
 
-	query := fmt.Sprintf(`select id, name, description, is_halal, is_vegetarian, location, lat, lon, created_at, updated_at, category from place %s order by name`, where)
-	rows, err := r.db.QueryContext(ctx, query)
+func (r *SQLPlaceRepository) GetAllPlaces(ctx context.Context) ([]*models.Place, error) {
+	query := `select ` + placeColumns + ` from ` + placeTables + ` where place.workspace_id = ? and place.deleted_at is null order by name, id`
+	rows, err := r.db.QueryContext(ctx, query, workspace.FromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -74,119 +146,338 @@ func (r *SQLPlaceRepository) GetAllPlaces(ctx context.Context, category ...strin
 
 	var places []*models.Place
 	for rows.Next() {
-		var place models.Place
-		err := rows.Scan(
-			&place.ID,
-			&place.Name,
-			&place.Description,
-			&place.IsHalal,
-			&place.IsVegetarian,
-			&place.Location,
-			&place.Lat,
-			&place.Lon,
-			&place.CreatedAt,
-			&place.UpdatedAt,
-			&place.Category,
-		)
+		place, err := scanPlace(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		places = append(places, &place)
+		places = append(places, place)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tags, err := getDietaryTags(ctx, r.db, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, place := range places {
+		setDietaryTags(place, tags[place.ID])
+	}
+
 	return places, nil
 }
 
-func (r *SQLPlaceRepository) GetAllPlacesWithFilter(ctx context.Context, isHalal, isVegetarian bool) ([]*models.Place, error) {
-	where := ""
-	if isHalal {
-		where = fmt.Sprintf("where is_halal = 1")
+// GetPlacesByIDs loads the places with the given IDs, keyed by ID, for
+// history such as past picks. Unlike every other read it includes places in
+// the trash; their dietary tags are not loaded.
+func (r *SQLPlaceRepository) GetPlacesByIDs(ctx context.Context, ids []int) (map[int]*models.Place, error) {
+	places := make(map[int]*models.Place, len(ids))
+	if len(ids) == 0 {
+		return places, nil
+	}
+
+	args := []interface{}{workspace.FromContext(ctx)}
+	for _, id := range ids {
+		args = append(args, id)
 	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	query := `select ` + placeColumns + ` from ` + placeTables + ` where place.workspace_id = ? and id in (` + placeholders + `)`
 
-	if isVegetarian {
-		if len(where) > 0 {
-			where += fmt.Sprintf(" and is_vegetarian = 1")
-		} else {
-			where = fmt.Sprintf("where is_vegetarian = 1")
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		place, err := scanPlace(rows)
+		if err != nil {
+			return nil, err
 		}
+		setDietaryTags(place, nil)
+		places[place.ID] = place
 	}
+	return places, rows.Err()
+}
 
-	query := fmt.Sprintf(`select id, name, description, is_halal, is_vegetarian, location, lat, lon, created_at, updated_at, category from place %s order by name`, where)
-	rows, err := r.db.QueryContext(ctx, query)
+// getDietaryTags returns the dietary tags of one place, or of every place when
+// placeID is 0, keyed by place ID.
+func getDietaryTags(ctx context.Context, q queryer, placeID int) (map[int][]models.DietaryTag, error) {
+	query := `
+		select pdt.place_id, t.id, t.slug, t.name, t.created_at, t.updated_at
+		from place_dietary_tag pdt
+		join dietary_tag t on t.id = pdt.dietary_tag_id
+		join place p on p.id = pdt.place_id
+		where p.workspace_id = ? and p.deleted_at is null and (? = 0 or pdt.place_id = ?)
+		order by t.slug
+	`
+	rows, err := q.QueryContext(ctx, query, workspace.FromContext(ctx), placeID, placeID)
 	if err != nil {
 		return nil, err
 	}
 
 	defer rows.Close()
 
-	var places []*models.Place
+	tags := make(map[int][]models.DietaryTag)
 	for rows.Next() {
-		var place models.Place
+		var id int
+		var tag models.DietaryTag
 		err := rows.Scan(
-			&place.ID,
-			&place.Name,
-			&place.Description,
-			&place.IsHalal,
-			&place.IsVegetarian,
-			&place.Location,
-			&place.Lat,
-			&place.Lon,
-			&place.CreatedAt,
-			&place.UpdatedAt,
-			&place.Category,
+			&id,
+			&tag.ID,
+			&tag.Slug,
+			&tag.Name,
+			&tag.CreatedAt,
+			&tag.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		places = append(places, &place)
+		tags[id] = append(tags[id], tag)
 	}
-	return places, nil
+	return tags, rows.Err()
+}
+
+func setDietaryTags(place *models.Place, tags []models.DietaryTag) {
+	place.DietaryTags = tags
+	if place.DietaryTags == nil {
+		place.DietaryTags = []models.DietaryTag{}
+	}
+	place.IsHalal = place.HasDietaryTag(dietary.HalalTags...)
+	place.IsVegetarian = place.HasDietaryTag(dietary.VegetarianTags...)
+}
+
+// replaceDietaryTags makes the tags stored for a place match place.DietaryTags.
+func replaceDietaryTags(ctx context.Context, tx *sql.Tx, placeID int, tags []models.DietaryTag) error {
+	_, err := tx.ExecContext(ctx, `delete from place_dietary_tag where place_id = ?`, placeID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		_, err = tx.ExecContext(ctx, `insert into place_dietary_tag (place_id, dietary_tag_id) values (?, ?)`, placeID, tag.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *SQLPlaceRepository) InsertPlace(ctx context.Context, place models.Place) (int, error) {
+	return r.SavePlace(ctx, place, nil)
+}
+
+func (r *SQLPlaceRepository) UpdatePlace(ctx context.Context, place models.Place) error {
+	_, err := r.SavePlace(ctx, place, nil)
+	return err
+}
+
+// SavePlace inserts the place when its ID is 0 and updates it otherwise,
+// returning its ID. within, when not nil, runs in the same transaction after
+// the place is written, so that a caller's own writes commit or roll back
+// together with the place.
+func (r *SQLPlaceRepository) SavePlace(ctx context.Context, place models.Place, within func(tx *sql.Tx, placeID int) error) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	change := revision.Change{EntityType: models.EntityPlace, Action: models.RevisionCreate}
+	if place.ID == 0 {
+		place.ID, err = insertPlace(ctx, tx, place)
+	} else {
+		// Locking the place also makes sure it is in this workspace before
+		// its dietary tags, which are keyed by place ID alone, are replaced.
+		change.Before, err = loadPlace(ctx, tx, place.ID, true)
+		if err != nil {
+			return 0, err
+		}
+		change.Action = models.RevisionUpdate
+		err = updatePlace(ctx, tx, place)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	err = replaceDietaryTags(ctx, tx, place.ID, place.DietaryTags)
+	if err != nil {
+		return 0, err
+	}
+
+	change.EntityID = place.ID
+	err = recordPlace(ctx, tx, change)
+	if err != nil {
+		return 0, err
+	}
+
+	if within != nil {
+		err = within(tx, place.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return place.ID, tx.Commit()
 }
 
-func (r *SQLPlaceRepository) InsertPlace(ctx context.Context, place models.Place) error {
+// RestorePlace writes the place back as it was in a snapshot taken from
+// revisionID, re-creating it under the same ID if it has been deleted.
+func (r *SQLPlaceRepository) RestorePlace(ctx context.Context, place models.Place, revisionID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A place in the trash is taken out of it; one that has been purged is
+	// inserted again.
+	var before *models.Place
+	var deletedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `select deleted_at from place where id = ? and workspace_id = ? for update`, place.ID, workspace.FromContext(ctx)).Scan(&deletedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		_, err = insertPlace(ctx, tx, place)
+		if err == nil {
+			err = dropRedirect(ctx, tx, place.ID)
+		}
+	case err != nil:
+	case deletedAt.Valid:
+		_, err = tx.ExecContext(ctx, `update place set deleted_at = null where id = ? and workspace_id = ?`, place.ID, workspace.FromContext(ctx))
+		if err == nil {
+			err = updatePlace(ctx, tx, place)
+		}
+		if err == nil {
+			err = dropRedirect(ctx, tx, place.ID)
+		}
+	default:
+		before, err = loadPlace(ctx, tx, place.ID, false)
+		if err == nil {
+			err = updatePlace(ctx, tx, place)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	err = replaceDietaryTags(ctx, tx, place.ID, place.DietaryTags)
+	if err != nil {
+		return err
+	}
+
+	err = recordPlace(ctx, tx, revision.Change{
+		EntityType: models.EntityPlace,
+		EntityID:   place.ID,
+		Action:     models.RevisionRevert,
+		Before:     before,
+		RevertOf:   &revisionID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// recordPlace records the change with the place as now stored in tx as its
+// after snapshot, or none for deletions.
+func recordPlace(ctx context.Context, tx *sql.Tx, change revision.Change) error {
+	if change.Action != models.RevisionDelete {
+		after, err := loadPlace(ctx, tx, change.EntityID, false)
+		if err != nil {
+			return err
+		}
+		change.After = after
+	}
+	return revision.Record(ctx, tx, change)
+}
+
+// openingHoursValue is the opening_hours column value for the place: NULL
+// when its hours are unknown.
+func openingHoursValue(place models.Place) (interface{}, error) {
+	if len(place.OpeningHours) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(place.OpeningHours)
+}
+
+// insertPlace inserts the place, under its own ID when it has one.
+func insertPlace(ctx context.Context, tx *sql.Tx, place models.Place) (int, error) {
 	stmt := `
 		insert into place 
-		(name, description, is_halal, is_vegetarian, location, lat, lon, created_at, updated_at, category) 
-		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		(id, name, description, location, lat, lon, area_id, price_level, min_spend, max_spend, created_at, updated_at, category, opening_hours, workspace_id) 
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := r.db.ExecContext(ctx, stmt,
+	var id interface{}
+	if place.ID != 0 {
+		id = place.ID
+	}
+
+	openingHours, err := openingHoursValue(place)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.ExecContext(ctx, stmt,
+		id,
 		place.Name,
 		place.Description,
-		place.IsHalal,
-		place.IsVegetarian,
 		place.Location,
 		place.Lat,
 		place.Lon,
+		place.AreaID,
+		place.PriceLevel,
+		place.MinSpend,
+		place.MaxSpend,
 		place.CreatedAt,
 		place.UpdatedAt,
 		place.Category,
+		openingHours,
+		workspace.FromContext(ctx),
 	)
+	if err != nil {
+		return 0, err
+	}
 
+	newID, err := result.LastInsertId()
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+
+	return int(newID), nil
 }
 
-func (r *SQLPlaceRepository) UpdatePlace(ctx context.Context, place models.Place) error {
-	stmt := `Update place set name = ?, description = ?, is_halal = ?, is_vegetarian = ?, location = ?, lat = ?, lon = ?, created_at = ? , updated_at = ? , category = ? where id = ?`
+// updatePlace writes every editable field of the place. created_at is left
+// as it was.
+func updatePlace(ctx context.Context, tx *sql.Tx, place models.Place) error {
+	stmt := `Update place set name = ?, description = ?, location = ?, lat = ?, lon = ?, area_id = ?, price_level = ?, min_spend = ?, max_spend = ?, updated_at = ? , category = ?, opening_hours = ? where id = ? and workspace_id = ?`
+
+	openingHours, err := openingHoursValue(place)
+	if err != nil {
+		return err
+	}
 
-	_, err := r.db.ExecContext(ctx, stmt,
+	_, err = tx.ExecContext(ctx, stmt,
 		place.Name,
 		place.Description,
-		place.IsHalal,
-		place.IsVegetarian,
 		place.Location,
 		place.Lat,
 		place.Lon,
-		place.CreatedAt,
+		place.AreaID,
+		place.PriceLevel,
+		place.MinSpend,
+		place.MaxSpend,
 		place.UpdatedAt,
 		place.Category,
+		openingHours,
 		place.ID,
+		workspace.FromContext(ctx),
 	)
-
 	if err != nil {
 		return err
 	}
@@ -194,32 +485,625 @@ func (r *SQLPlaceRepository) UpdatePlace(ctx context.Context, place models.Place
 	return nil
 }
 
+// DeletePlace moves the place to the trash. It returns sql.ErrNoRows if the
+// place is not in the workspace or is already in the trash.
 func (r *SQLPlaceRepository) DeletePlace(ctx context.Context, id int) error {
-	stmt := "Delete from place where id = ?"
-
-	_, err := r.db.ExecContext(ctx, stmt, id)
+	deleted, err := r.DeletePlaces(ctx, []int{id})
 	if err != nil {
 		return err
 	}
+	if len(deleted) == 0 {
+		return sql.ErrNoRows
+	}
 
 	return nil
 }
 
-func (r *SQLPlaceRepository) DeletePlaces(ctx context.Context, idList []int) error {
+// DeletePlaces moves the places to the trash, recording each one's last
+// state, and returns the IDs it trashed. IDs that are not places in the
+// workspace, or are already in the trash, are skipped.
+func (r *SQLPlaceRepository) DeletePlaces(ctx context.Context, idList []int) ([]int, error) {
 	if len(idList) == 0 {
-		return errors.New("The list is empty")
+		return nil, errors.New("The list is empty")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var deleted []int
+	for _, id := range idList {
+		before, err := loadPlace(ctx, tx, id, true)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, "Update place set deleted_at = ? where id = ? and workspace_id = ?", time.Now(), id, workspace.FromContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		err = recordPlace(ctx, tx, revision.Change{
+			EntityType: models.EntityPlace,
+			EntityID:   id,
+			Action:     models.RevisionDelete,
+			Before:     before,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		deleted = append(deleted, id)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// GetDeletedPlaces lists the places in the trash, most recently deleted
+// first.
+func (r *SQLPlaceRepository) GetDeletedPlaces(ctx context.Context) ([]*models.TrashItem, error) {
+	query := `select id, name, deleted_at from place where workspace_id = ? and deleted_at is not null order by deleted_at desc, id`
+	rows, err := r.db.QueryContext(ctx, query, workspace.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var items []*models.TrashItem
+	for rows.Next() {
+		item := models.TrashItem{EntityType: models.EntityPlace}
+		err := rows.Scan(&item.ID, &item.Name, &item.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+// UndeletePlace takes the place out of the trash. It returns sql.ErrNoRows if
+// the place is not in the trash.
+func (r *SQLPlaceRepository) UndeletePlace(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `update place set deleted_at = null where id = ? and workspace_id = ? and deleted_at is not null`, id, workspace.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	err = dropRedirect(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = recordPlace(ctx, tx, revision.Change{
+		EntityType: models.EntityPlace,
+		EntityID:   id,
+		Action:     models.RevisionRestore,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgePlaces permanently removes the places that were moved to the trash
+// before the given time, along with their reviews and preferences, and
+// returns how many there were. Places that a schedule picked stay in the
+// trash, since purging them would take the picks off subscribed calendars.
+func (r *SQLPlaceRepository) PurgePlaces(ctx context.Context, before time.Time) (int, error) {
+	stmt := `
+		delete from place
+		where workspace_id = ? and deleted_at < ?
+		and not exists (select 1 from schedule_pick sp where sp.place_id = place.id)
+	`
+	result, err := r.db.ExecContext(ctx, stmt, workspace.FromContext(ctx), before)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// MergePlaces folds the place fromID into intoID in one transaction. The
+// survivor keeps its own category unless it has none and gains the other's
+// dietary tags. Reviews, preferences, suggestions, scheduled picks and draws
+// move to the survivor; a user who reviewed both keeps their latest review,
+// and a survivor's preference wins over the duplicate's. The duplicate goes
+// to the trash and its ID redirects to the survivor from then on. Both
+// places must be live places of the workspace, or sql.ErrNoRows is returned.
+func (r *SQLPlaceRepository) MergePlaces(ctx context.Context, fromID, intoID int) error {
+	if fromID == intoID {
+		return ErrMergeIntoItself
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock in ID order so that two merges of the same pair cannot deadlock.
+	order := []int{fromID, intoID}
+	if fromID > intoID {
+		order = []int{intoID, fromID}
+	}
+	locked := make(map[int]*models.Place, 2)
+	for _, id := range order {
+		locked[id], err = loadPlace(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+	}
+	from, into := locked[fromID], locked[intoID]
+
+	merged := *into
+	if strings.TrimSpace(merged.Category) == "" {
+		merged.Category = from.Category
+	}
+	merged.DietaryTags = append([]models.DietaryTag{}, into.DietaryTags...)
+	for _, tag := range from.DietaryTags {
+		if !merged.HasDietaryTag(tag.Slug) {
+			merged.DietaryTags = append(merged.DietaryTags, tag)
+		}
+	}
+	merged.UpdatedAt = time.Now()
+
+	err = updatePlace(ctx, tx, merged)
+	if err != nil {
+		return err
+	}
+
+	err = replaceDietaryTags(ctx, tx, intoID, merged.DietaryTags)
+	if err != nil {
+		return err
+	}
+
+	stmts := []struct {
+		query string
+		args  []interface{}
+	}{
+		// Of two reviews by the same user, the older one is dropped.
+		{`
+			delete older from review older
+			join review newer on newer.user_id = older.user_id and newer.id <> older.id
+			where older.place_id in (?, ?) and newer.place_id in (?, ?)
+			and (newer.updated_at > older.updated_at or (newer.updated_at = older.updated_at and newer.id > older.id))
+		`, []interface{}{fromID, intoID, fromID, intoID}},
+		{`update review set place_id = ? where place_id = ?`, []interface{}{intoID, fromID}},
+		{`
+			insert into place_rating (place_id, rating_count, rating_sum)
+			select ?, count(*), coalesce(sum(rating), 0) from review where place_id = ?
+			on duplicate key update rating_count = values(rating_count), rating_sum = values(rating_sum)
+		`, []interface{}{intoID, intoID}},
+		{`delete from place_rating where place_id = ?`, []interface{}{fromID}},
+		{`update ignore user_place_preference set place_id = ? where place_id = ?`, []interface{}{intoID, fromID}},
+		{`delete from user_place_preference where place_id = ?`, []interface{}{fromID}},
+		{`update place_suggestion set place_id = ? where place_id = ?`, []interface{}{intoID, fromID}},
+		{`update schedule_pick set place_id = ? where place_id = ?`, []interface{}{intoID, fromID}},
+		{`update place_redirect set to_place_id = ? where to_place_id = ?`, []interface{}{intoID, fromID}},
+		{`update place set deleted_at = ? where id = ? and workspace_id = ?`, []interface{}{time.Now(), fromID, workspace.FromContext(ctx)}},
+	}
+	for _, stmt := range stmts {
+		_, err = tx.ExecContext(ctx, stmt.query, stmt.args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = repointDraws(ctx, tx, fromID, intoID)
+	if err != nil {
+		return err
+	}
+
+	var mergedBy *int
+	if td, ok := middleware.UserFromContext(ctx); ok {
+		mergedBy = &td.ID
+	}
+	_, err = tx.ExecContext(ctx, `
+		insert into place_redirect (from_place_id, to_place_id, workspace_id, merged_by, created_at)
+		values (?, ?, ?, ?, ?)
+		on duplicate key update to_place_id = values(to_place_id), merged_by = values(merged_by), created_at = values(created_at)
+	`, fromID, intoID, workspace.FromContext(ctx), mergedBy, time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = recordPlace(ctx, tx, revision.Change{
+		EntityType: models.EntityPlace,
+		EntityID:   fromID,
+		Action:     models.RevisionDelete,
+		Before:     from,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = recordPlace(ctx, tx, revision.Change{
+		EntityType: models.EntityPlace,
+		EntityID:   intoID,
+		Action:     models.RevisionUpdate,
+		Before:     into,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// repointDraws replaces fromID with intoID among the picks and candidates of
+// the workspace's draws, so that repeat avoidance sees the survivor as
+// picked and replays still land on a candidate. Candidate names are left as
+// they were drawn.
+func repointDraws(ctx context.Context, tx *sql.Tx, fromID, intoID int) error {
+	query := `
+		select id, candidates, picked_place_ids from draw
+		where workspace_id = ?
+		and (json_contains(picked_place_ids, cast(? as json)) or json_contains(candidates, json_object('place_id', ?)))
+		for update
+	`
+	rows, err := tx.QueryContext(ctx, query, workspace.FromContext(ctx), strconv.Itoa(fromID), fromID)
+	if err != nil {
+		return err
+	}
+
+	var draws []models.Draw
+	for rows.Next() {
+		var d models.Draw
+		var candidates, picked []byte
+		err = rows.Scan(&d.ID, &candidates, &picked)
+		if err == nil {
+			err = json.Unmarshal(candidates, &d.Candidates)
+		}
+		if err == nil {
+			err = json.Unmarshal(picked, &d.PickedPlaceIDs)
+		}
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		draws = append(draws, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, d := range draws {
+		for i := range d.Candidates {
+			if d.Candidates[i].PlaceID == fromID {
+				d.Candidates[i].PlaceID = intoID
+			}
+		}
+		for i, id := range d.PickedPlaceIDs {
+			if id == fromID {
+				d.PickedPlaceIDs[i] = intoID
+			}
+		}
+
+		candidates, err := json.Marshal(d.Candidates)
+		if err != nil {
+			return err
+		}
+		picked, err := json.Marshal(d.PickedPlaceIDs)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `update draw set candidates = ?, picked_place_ids = ? where id = ?`, candidates, picked, d.ID)
+		if err != nil {
+			return err
+		}
 	}
 
-	var sb strings.Builder
-	sb.WriteString("Delete from place where id in (")
-	sb.WriteString(strconv.Itoa(idList[0]))
-	for i := 1; i < len(idList); i++ {
-		sb.WriteString(",")
-		sb.WriteString(strconv.Itoa(idList[i]))
+	return nil
+}
+
+// dropRedirect stops id redirecting to the place it was merged into, for
+// when a merged place is brought back. What the merge moved to the surviving
+// place stays there.
+func dropRedirect(ctx context.Context, tx *sql.Tx, id int) error {
+	_, err := tx.ExecContext(ctx, `delete from place_redirect where from_place_id = ? and workspace_id = ?`, id, workspace.FromContext(ctx))
+	return err
+}
+
+// ResolvePlaceID returns the place that id was merged into, or id itself
+// when it was never merged.
+func (r *SQLPlaceRepository) ResolvePlaceID(ctx context.Context, id int) (int, error) {
+	var to int
+	err := r.db.QueryRowContext(ctx, `select to_place_id from place_redirect where from_place_id = ? and workspace_id = ?`, id, workspace.FromContext(ctx)).Scan(&to)
+	if errors.Is(err, sql.ErrNoRows) {
+		return id, nil
 	}
-	sb.WriteString(")")
+	if err != nil {
+		return 0, err
+	}
+	return to, nil
+}
 
-	_, err := r.db.ExecContext(ctx, sb.String())
+// EachPlace calls fn with every place in ID order. Places and their dietary
+// tags are read from two cursors in step rather than loaded up front, so the
+// whole table never has to fit in memory. An error from fn stops the walk
+// and is returned.
+func (r *SQLPlaceRepository) EachPlace(ctx context.Context, fn func(place *models.Place) error) error {
+	workspaceID := workspace.FromContext(ctx)
+	rows, err := r.db.QueryContext(ctx, `select `+placeColumns+` from `+placeTables+` where place.workspace_id = ? and place.deleted_at is null order by id`, workspaceID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tagQuery := `
+		select pdt.place_id, t.id, t.slug, t.name, t.created_at, t.updated_at
+		from place_dietary_tag pdt
+		join dietary_tag t on t.id = pdt.dietary_tag_id
+		join place p on p.id = pdt.place_id
+		where p.workspace_id = ? and p.deleted_at is null
+		order by pdt.place_id, t.slug
+	`
+	tagRows, err := r.db.QueryContext(ctx, tagQuery, workspaceID)
+	if err != nil {
+		return err
+	}
+	defer tagRows.Close()
+
+	// pending holds the tag row read past the end of the previous place.
+	var pending *models.DietaryTag
+	pendingPlaceID := 0
+	nextTag := func() error {
+		pending = nil
+		if !tagRows.Next() {
+			return tagRows.Err()
+		}
+		var tag models.DietaryTag
+		err := tagRows.Scan(&pendingPlaceID, &tag.ID, &tag.Slug, &tag.Name, &tag.CreatedAt, &tag.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		pending = &tag
+		return nil
+	}
+	if err := nextTag(); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		place, err := scanPlace(rows)
+		if err != nil {
+			return err
+		}
+
+		var tags []models.DietaryTag
+		for pending != nil && pendingPlaceID <= place.ID {
+			if pendingPlaceID == place.ID {
+				tags = append(tags, *pending)
+			}
+			if err := nextTag(); err != nil {
+				return err
+			}
+		}
+		setDietaryTags(place, tags)
+
+		if err := fn(place); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Import actions reported per row.
+const (
+	ImportCreated  = "created"
+	ImportUpdated  = "updated"
+	ImportRejected = "rejected"
+)
+
+// ImportResult is what ImportPlaces did with one place.
+type ImportResult struct {
+	PlaceID int
+	Action  string
+}
+
+// ImportPlaces upserts places in one transaction, matching existing places by
+// name and location, ignoring case. Categories and locations that do not
+// exist yet are created, and each place takes the stored spelling of its
+// category and location. Blank coordinates leave an existing place's
+// coordinates unchanged. With dryRun every statement still runs, so the
+// results are accurate, but the transaction is rolled back.
+func (r *SQLPlaceRepository) ImportPlaces(ctx context.Context, places []models.Place, dryRun bool) ([]ImportResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	workspaceID := workspace.FromContext(ctx)
+	categories := make(map[string]string)
+	locations := make(map[string]string)
+	results := make([]ImportResult, 0, len(places))
+
+	for _, place := range places {
+		place.Category, err = resolveName(ctx, tx, workspaceID, "category", "category_name", place.Category, categories)
+		if err != nil {
+			return nil, err
+		}
+
+		place.Location, err = resolveName(ctx, tx, workspaceID, "location", "location_name", place.Location, locations)
+		if err != nil {
+			return nil, err
+		}
+
+		result := ImportResult{Action: ImportUpdated}
+		change := revision.Change{EntityType: models.EntityPlace, Action: models.RevisionUpdate}
+		query := `select id from place where lower(name) = lower(?) and lower(location) = lower(?) and workspace_id = ? and deleted_at is null order by id limit 1 for update`
+		err = tx.QueryRowContext(ctx, query, place.Name, place.Location, workspaceID).Scan(&result.PlaceID)
+		if errors.Is(err, sql.ErrNoRows) {
+			result.Action = ImportCreated
+			change.Action = models.RevisionCreate
+			result.PlaceID, err = insertImportedPlace(ctx, tx, workspaceID, place)
+		} else if err == nil {
+			place.ID = result.PlaceID
+			change.Before, err = loadPlace(ctx, tx, place.ID, false)
+			if err == nil {
+				err = updateImportedPlace(ctx, tx, place)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = replaceDietaryTags(ctx, tx, result.PlaceID, place.DietaryTags)
+		if err != nil {
+			return nil, err
+		}
+
+		change.EntityID = result.PlaceID
+		err = recordPlace(ctx, tx, change)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	if dryRun {
+		return results, nil
+	}
+	return results, tx.Commit()
+}
+
+// resolveName returns the stored spelling of a category or location name,
+// creating the row when there is none. cache maps lower-cased names to the
+// stored spelling.
+func resolveName(ctx context.Context, tx *sql.Tx, workspaceID int, table, column, name string, cache map[string]string) (string, error) {
+	key := strings.ToLower(name)
+	if stored, ok := cache[key]; ok {
+		return stored, nil
+	}
+
+	var stored string
+	query := fmt.Sprintf(`select %s from %s where lower(%s) = ? and workspace_id = ? and deleted_at is null order by id limit 1`, column, table, column)
+	err := tx.QueryRowContext(ctx, query, key, workspaceID).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		stored = name
+		err = insertName(ctx, tx, workspaceID, table, column, name)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	cache[key] = stored
+	return stored, nil
+}
+
+// insertName creates a category or location for resolveName and records its
+// creation. The table names double as revision entity types.
+func insertName(ctx context.Context, tx *sql.Tx, workspaceID int, table, column, name string) error {
+	result, err := tx.ExecContext(ctx, fmt.Sprintf(`insert into %s (%s, workspace_id) value (?, ?)`, table, column), name, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	var after interface{} = models.Category{ID: int(id), CategoryName: name}
+	if table == models.EntityLocation {
+		after = models.Location{ID: int(id), LocationName: name}
+	}
+	return revision.Record(ctx, tx, revision.Change{
+		EntityType: table,
+		EntityID:   int(id),
+		Action:     models.RevisionCreate,
+		After:      after,
+	})
+}
+
+func insertImportedPlace(ctx context.Context, tx *sql.Tx, workspaceID int, place models.Place) (int, error) {
+	stmt := `
+		insert into place
+		(name, description, location, lat, lon, area_id, price_level, min_spend, max_spend, created_at, updated_at, category, workspace_id)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	lat, lon := place.Lat, place.Lon
+	if lat == "" || lon == "" {
+		lat, lon = " ", " "
+	}
+
+	result, err := tx.ExecContext(ctx, stmt,
+		place.Name,
+		place.Description,
+		place.Location,
+		lat,
+		lon,
+		place.AreaID,
+		place.PriceLevel,
+		place.MinSpend,
+		place.MaxSpend,
+		place.CreatedAt,
+		place.UpdatedAt,
+		place.Category,
+		workspaceID,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+func updateImportedPlace(ctx context.Context, tx *sql.Tx, place models.Place) error {
+	stmt := `
+		update place set name = ?, description = ?, location = ?,
+		lat = coalesce(nullif(?, ''), lat), lon = coalesce(nullif(?, ''), lon),
+		area_id = coalesce(?, area_id),
+		price_level = ?, min_spend = ?, max_spend = ?, updated_at = ?, category = ?
+		where id = ?
+	`
+	_, err := tx.ExecContext(ctx, stmt,
+		place.Name,
+		place.Description,
+		place.Location,
+		place.Lat,
+		place.Lon,
+		place.AreaID,
+		place.PriceLevel,
+		place.MinSpend,
+		place.MaxSpend,
+		place.UpdatedAt,
+		place.Category,
+		place.ID,
+	)
 	if err != nil {
 		return err
 	}
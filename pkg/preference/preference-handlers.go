@@ -0,0 +1,191 @@
+package preference
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/dietary"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/middleware"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+// errNoReferencedRow is the MySQL error number for a missing foreign key.
+const errNoReferencedRow = 1452
+
+// GetPreferences lists the caller's places of the given kind.
+func GetPreferences(repo PreferenceRepository, kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := middleware.UserFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		preferences, err := repo.GetPreferences(ctx, user.ID, kind)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, preferences, "places")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// AddPreference marks the place in the URL with the given kind for the caller.
+func AddPreference(repo PreferenceRepository, kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := middleware.UserFromContext(r.Context())
+
+		placeID, err := strconv.Atoi(mux.Vars(r)["placeId"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		err = repo.SetPreference(ctx, user.ID, placeID, kind)
+		if err != nil {
+			var mysqlErr *mysql.MySQLError
+			if errors.Is(err, sql.ErrNoRows) || errors.As(err, &mysqlErr) && mysqlErr.Number == errNoReferencedRow {
+				utils.ErrorJSON(w, errors.New("place does not exist"), http.StatusNotFound)
+				return
+			}
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, nil, "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// RemovePreference clears the given kind of mark from the place in the URL.
+func RemovePreference(repo PreferenceRepository, kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := middleware.UserFromContext(r.Context())
+
+		placeID, err := strconv.Atoi(mux.Vars(r)["placeId"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		err = repo.DeletePreference(ctx, user.ID, placeID, kind)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, "Deleted Successfully", "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+type ConstraintDto struct {
+	DietaryTags []string `json:"dietary_tags"`
+	MaxPrice    int      `json:"max_price"`
+	Budget      *float64 `json:"budget"`
+}
+
+func GetConstraint(repo PreferenceRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := middleware.UserFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		constraint, err := repo.GetConstraint(ctx, user.ID)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, constraint, "constraint")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func EditConstraint(repo PreferenceRepository, tagRepo dietary.DietaryTagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := middleware.UserFromContext(r.Context())
+
+		var payload ConstraintDto
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		if payload.MaxPrice < 0 || payload.MaxPrice > 4 {
+			utils.ErrorJSON(w, errors.New("max_price must be between 1 and 4"), http.StatusBadRequest)
+			return
+		}
+
+		if payload.Budget != nil && *payload.Budget < 0 {
+			utils.ErrorJSON(w, errors.New("budget must not be negative"), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		slugs := dietary.UniqueSlugs(payload.DietaryTags)
+		tags, err := tagRepo.GetDietaryTagsBySlugs(ctx, slugs)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+		if len(tags) != len(slugs) {
+			utils.ErrorJSON(w, errors.New("unknown dietary tag"), http.StatusBadRequest)
+			return
+		}
+
+		var tagIDs []int
+		for _, tag := range tags {
+			tagIDs = append(tagIDs, tag.ID)
+		}
+
+		constraint := models.UserConstraint{
+			UserID:   user.ID,
+			MaxPrice: payload.MaxPrice,
+			Budget:   payload.Budget,
+		}
+
+		err = repo.SaveConstraint(ctx, constraint, tagIDs)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, nil, "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
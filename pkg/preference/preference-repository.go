@@ -0,0 +1,187 @@
+package preference
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+var _ PreferenceRepository = &SQLPreferenceRepository{}
+
+type PreferenceRepository interface {
+	GetPreferences(ctx context.Context, userID int, kind string) ([]*models.PlacePreference, error)
+	GetPlaceIDs(ctx context.Context, userID int, kind string) (map[int]bool, error)
+	SetPreference(ctx context.Context, userID, placeID int, kind string) error
+	DeletePreference(ctx context.Context, userID, placeID int, kind string) error
+	GetConstraint(ctx context.Context, userID int) (*models.UserConstraint, error)
+	SaveConstraint(ctx context.Context, constraint models.UserConstraint, tagIDs []int) error
+}
+
+type SQLPreferenceRepository struct {
+	db *sql.DB
+}
+
+func NewSQLPreferenceRepository(db *sql.DB) *SQLPreferenceRepository {
+	return &SQLPreferenceRepository{db: db}
+}
+
+func (repo *SQLPreferenceRepository) GetPreferences(ctx context.Context, userID int, kind string) ([]*models.PlacePreference, error) {
+	query := `
+		select upp.place_id, p.name, upp.kind, upp.created_at
+		from user_place_preference upp
+		join place p on p.id = upp.place_id and p.deleted_at is null
+		where upp.user_id = ? and upp.kind = ? and p.workspace_id = ?
+		order by p.name
+	`
+	rows, err := repo.db.QueryContext(ctx, query, userID, kind, workspace.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var preferences []*models.PlacePreference
+	for rows.Next() {
+		var preference models.PlacePreference
+		err := rows.Scan(
+			&preference.PlaceID,
+			&preference.PlaceName,
+			&preference.Kind,
+			&preference.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		preferences = append(preferences, &preference)
+	}
+	return preferences, rows.Err()
+}
+
+// GetPlaceIDs returns the set of place IDs the user has marked with kind.
+func (repo *SQLPreferenceRepository) GetPlaceIDs(ctx context.Context, userID int, kind string) (map[int]bool, error) {
+	query := `
+		select upp.place_id from user_place_preference upp
+		join place p on p.id = upp.place_id and p.deleted_at is null
+		where upp.user_id = ? and upp.kind = ? and p.workspace_id = ?
+	`
+	rows, err := repo.db.QueryContext(ctx, query, userID, kind, workspace.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// SetPreference marks the place for the user, replacing any preference of the
+// other kind.
+func (repo *SQLPreferenceRepository) SetPreference(ctx context.Context, userID, placeID int, kind string) error {
+	err := repo.db.QueryRowContext(ctx, `select id from place where id = ? and workspace_id = ? and deleted_at is null`, placeID, workspace.FromContext(ctx)).Scan(&placeID)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+		insert into user_place_preference (user_id, place_id, kind) values (?, ?, ?)
+		on duplicate key update created_at = if(kind = values(kind), created_at, current_timestamp), kind = values(kind)
+	`
+	_, err = repo.db.ExecContext(ctx, stmt, userID, placeID, kind)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *SQLPreferenceRepository) DeletePreference(ctx context.Context, userID, placeID int, kind string) error {
+	stmt := `delete from user_place_preference where user_id = ? and place_id = ? and kind = ?`
+	_, err := repo.db.ExecContext(ctx, stmt, userID, placeID, kind)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetConstraint returns the user's standing constraints. Users who never saved
+// any get an empty constraint rather than an error.
+func (repo *SQLPreferenceRepository) GetConstraint(ctx context.Context, userID int) (*models.UserConstraint, error) {
+	constraint := models.UserConstraint{UserID: userID, DietaryTags: []string{}}
+
+	row := repo.db.QueryRowContext(ctx, `select max_price, budget from user_constraint where user_id = ?`, userID)
+	err := row.Scan(&constraint.MaxPrice, &constraint.Budget)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	query := `
+		select t.slug
+		from user_dietary_tag udt
+		join dietary_tag t on t.id = udt.dietary_tag_id
+		where udt.user_id = ?
+		order by t.slug
+	`
+	rows, err := repo.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+		constraint.DietaryTags = append(constraint.DietaryTags, slug)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &constraint, nil
+}
+
+// SaveConstraint replaces the user's standing constraints.
+func (repo *SQLPreferenceRepository) SaveConstraint(ctx context.Context, constraint models.UserConstraint, tagIDs []int) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `
+		insert into user_constraint (user_id, max_price, budget, updated_at) values (?, ?, ?, current_timestamp)
+		on duplicate key update max_price = values(max_price), budget = values(budget), updated_at = values(updated_at)
+	`
+	_, err = tx.ExecContext(ctx, stmt, constraint.UserID, constraint.MaxPrice, constraint.Budget)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from user_dietary_tag where user_id = ?`, constraint.UserID)
+	if err != nil {
+		return err
+	}
+
+	for _, tagID := range tagIDs {
+		_, err = tx.ExecContext(ctx, `insert into user_dietary_tag (user_id, dietary_tag_id) values (?, ?)`, constraint.UserID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
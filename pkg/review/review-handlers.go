@@ -0,0 +1,269 @@
+package review
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/middleware"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+const maxCommentLength = 2000
+
+// MySQL error numbers surfaced by review inserts.
+const (
+	errDuplicateEntry  = 1062
+	errNoReferencedRow = 1452
+)
+
+type ReviewDto struct {
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment"`
+}
+
+func (dto ReviewDto) validate() error {
+	if dto.Rating < models.MinRating || dto.Rating > models.MaxRating {
+		return errors.New("rating must be between 1 and 5")
+	}
+
+	if len(dto.Comment) > maxCommentLength {
+		return errors.New("comment is too long")
+	}
+
+	return nil
+}
+
+func GetPlaceReviews(repo ReviewRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		placeID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		reviews, err := repo.GetReviewsByPlace(ctx, placeID)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, reviews, "reviews")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func GetMyReviews(repo ReviewRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := middleware.UserFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		reviews, err := repo.GetReviewsByUser(ctx, user.ID)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, reviews, "reviews")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func CreateReview(repo ReviewRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := middleware.UserFromContext(r.Context())
+
+		placeID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		var payload ReviewDto
+		err = json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = payload.validate()
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		review := models.Review{
+			PlaceID:   placeID,
+			UserID:    user.ID,
+			Rating:    payload.Rating,
+			Comment:   payload.Comment,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+
+		err = repo.InsertReview(ctx, review)
+		if err != nil {
+			var mysqlErr *mysql.MySQLError
+			switch {
+			case errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry:
+				utils.ErrorJSON(w, errors.New("you have already reviewed this place"), http.StatusConflict)
+			case errors.Is(err, sql.ErrNoRows), errors.As(err, &mysqlErr) && mysqlErr.Number == errNoReferencedRow:
+				utils.ErrorJSON(w, errors.New("place does not exist"), http.StatusNotFound)
+			default:
+				utils.ErrorJSON(w, err)
+			}
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusCreated, nil, "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func EditReview(repo ReviewRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload ReviewDto
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = payload.validate()
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		review, ok := getOwnReview(ctx, w, r, repo)
+		if !ok {
+			return
+		}
+
+		review.Rating = payload.Rating
+		review.Comment = payload.Comment
+		review.UpdatedAt = time.Now()
+
+		err = repo.UpdateReview(ctx, *review)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, nil, "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func DeleteReview(repo ReviewRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		review, ok := getOwnReview(ctx, w, r, repo)
+		if !ok {
+			return
+		}
+
+		err := repo.DeleteReview(ctx, review.ID)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, "Deleted Successfully", "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// RemoveReview lets a moderator delete any review.
+func RemoveReview(repo ReviewRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		err = repo.DeleteReview(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.ErrorJSON(w, errors.New("review does not exist"), http.StatusNotFound)
+				return
+			}
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, "Deleted Successfully", "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// getOwnReview loads the review named in the URL and checks that it belongs
+// to the caller, writing the error response itself when it does not.
+func getOwnReview(ctx context.Context, w http.ResponseWriter, r *http.Request, repo ReviewRepository) (*models.Review, bool) {
+	user, _ := middleware.UserFromContext(r.Context())
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorJSON(w, err)
+		return nil, false
+	}
+
+	review, err := repo.GetReviewByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.ErrorJSON(w, errors.New("review does not exist"), http.StatusNotFound)
+			return nil, false
+		}
+		utils.ErrorJSON(w, err)
+		return nil, false
+	}
+
+	if review.UserID != user.ID {
+		utils.ErrorJSON(w, errors.New("you can only change your own reviews"), http.StatusForbidden)
+		return nil, false
+	}
+
+	return review, true
+}
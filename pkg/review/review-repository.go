@@ -0,0 +1,193 @@
+package review
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+var _ ReviewRepository = &SQLReviewRepository{}
+
+type ReviewRepository interface {
+	GetReviewByID(ctx context.Context, id int) (*models.Review, error)
+	GetReviewsByPlace(ctx context.Context, placeID int) ([]*models.Review, error)
+	GetReviewsByUser(ctx context.Context, userID int) ([]*models.Review, error)
+	InsertReview(ctx context.Context, review models.Review) error
+	UpdateReview(ctx context.Context, review models.Review) error
+	DeleteReview(ctx context.Context, id int) error
+}
+
+type SQLReviewRepository struct {
+	db *sql.DB
+}
+
+func NewSQLReviewRepository(db *sql.DB) *SQLReviewRepository {
+	return &SQLReviewRepository{db: db}
+}
+
+const reviewQuery = `
+	select r.id, r.place_id, r.user_id, u.username, r.rating, coalesce(r.comment, ''), r.created_at, r.updated_at
+	from review r
+	join user u on u.id = r.user_id
+	join place p on p.id = r.place_id and p.deleted_at is null
+`
+
+func (repo *SQLReviewRepository) GetReviewByID(ctx context.Context, id int) (*models.Review, error) {
+	row := repo.db.QueryRowContext(ctx, reviewQuery+` where r.id = ? and p.workspace_id = ?`, id, workspace.FromContext(ctx))
+
+	var review models.Review
+	err := row.Scan(
+		&review.ID,
+		&review.PlaceID,
+		&review.UserID,
+		&review.UserName,
+		&review.Rating,
+		&review.Comment,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+func (repo *SQLReviewRepository) GetReviewsByPlace(ctx context.Context, placeID int) ([]*models.Review, error) {
+	return repo.queryReviews(ctx, reviewQuery+` where r.place_id = ? and p.workspace_id = ? order by r.created_at desc`, placeID, workspace.FromContext(ctx))
+}
+
+func (repo *SQLReviewRepository) GetReviewsByUser(ctx context.Context, userID int) ([]*models.Review, error) {
+	return repo.queryReviews(ctx, reviewQuery+` where r.user_id = ? and p.workspace_id = ? order by r.created_at desc`, userID, workspace.FromContext(ctx))
+}
+
+func (repo *SQLReviewRepository) queryReviews(ctx context.Context, query string, args ...interface{}) ([]*models.Review, error) {
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var reviews []*models.Review
+	for rows.Next() {
+		var review models.Review
+		err := rows.Scan(
+			&review.ID,
+			&review.PlaceID,
+			&review.UserID,
+			&review.UserName,
+			&review.Rating,
+			&review.Comment,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+	return reviews, rows.Err()
+}
+
+// InsertReview stores the review and adds it to the place's rating aggregate.
+func (repo *SQLReviewRepository) InsertReview(ctx context.Context, review models.Review) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `select id from place where id = ? and workspace_id = ? and deleted_at is null`, review.PlaceID, workspace.FromContext(ctx)).Scan(&review.PlaceID)
+	if err != nil {
+		return err
+	}
+
+	stmt := `insert into review (place_id, user_id, rating, comment, created_at, updated_at) values (?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, stmt,
+		review.PlaceID,
+		review.UserID,
+		review.Rating,
+		review.Comment,
+		review.CreatedAt,
+		review.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	stmt = `
+		insert into place_rating (place_id, rating_count, rating_sum) values (?, 1, ?)
+		on duplicate key update rating_count = rating_count + 1, rating_sum = rating_sum + values(rating_sum)
+	`
+	_, err = tx.ExecContext(ctx, stmt, review.PlaceID, review.Rating)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateReview changes the review's rating and comment, moving the place's
+// rating aggregate by the difference from the stored rating.
+func (repo *SQLReviewRepository) UpdateReview(ctx context.Context, review models.Review) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldRating int
+	query := `select r.rating from review r join place p on p.id = r.place_id and p.deleted_at is null where r.id = ? and p.workspace_id = ? for update`
+	err = tx.QueryRowContext(ctx, query, review.ID, workspace.FromContext(ctx)).Scan(&oldRating)
+	if err != nil {
+		return err
+	}
+
+	stmt := `update review set rating = ?, comment = ?, updated_at = ? where id = ?`
+	_, err = tx.ExecContext(ctx, stmt, review.Rating, review.Comment, review.UpdatedAt, review.ID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `update place_rating set rating_sum = rating_sum + ? where place_id = ?`
+	_, err = tx.ExecContext(ctx, stmt, review.Rating-oldRating, review.PlaceID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteReview removes the review and takes it out of the place's rating
+// aggregate.
+func (repo *SQLReviewRepository) DeleteReview(ctx context.Context, id int) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var placeID, rating int
+	query := `select r.place_id, r.rating from review r join place p on p.id = r.place_id and p.deleted_at is null where r.id = ? and p.workspace_id = ? for update`
+	err = tx.QueryRowContext(ctx, query, id, workspace.FromContext(ctx)).Scan(&placeID, &rating)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from review where id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	stmt := `update place_rating set rating_count = rating_count - 1, rating_sum = rating_sum - ? where place_id = ?`
+	_, err = tx.ExecContext(ctx, stmt, rating, placeID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,71 @@
+package revision
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+// GetHistory lists the revisions of the entity of entityType whose ID is in
+// the URL, newest first.
+func GetHistory(repo RevisionRepository, entityType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		revisions, err := repo.GetRevisions(ctx, entityType, id)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, revisions, "revisions")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// ReadRevert decodes into v the snapshot that the revert request in r asks
+// for and returns the entity and revision IDs from the URL. On failure it
+// writes the error response and returns ok false.
+func ReadRevert(ctx context.Context, w http.ResponseWriter, r *http.Request, repo RevisionRepository, entityType string, v interface{}) (id, revisionID int, ok bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		utils.ErrorJSON(w, err)
+		return 0, 0, false
+	}
+	revisionID, err = strconv.Atoi(vars["revisionId"])
+	if err != nil {
+		utils.ErrorJSON(w, err)
+		return 0, 0, false
+	}
+
+	err = Snapshot(ctx, repo, entityType, id, revisionID, v)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		utils.ErrorJSON(w, err, http.StatusNotFound)
+		return 0, 0, false
+	case errors.Is(err, ErrNothingToRestore):
+		utils.ErrorJSON(w, err, http.StatusBadRequest)
+		return 0, 0, false
+	case err != nil:
+		utils.ErrorJSON(w, err)
+		return 0, 0, false
+	}
+
+	return id, revisionID, true
+}
@@ -0,0 +1,183 @@
+package revision
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/middleware"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+var (
+	// ErrNotFound is returned by Snapshot for revisions that do not exist or
+	// belong to another entity.
+	ErrNotFound = errors.New("revision does not exist")
+	// ErrNothingToRestore is returned by Snapshot for deletions, which leave
+	// nothing behind to restore.
+	ErrNothingToRestore = errors.New("this revision deleted the entity; revert to an earlier revision")
+)
+
+// Change is one write to record as a revision.
+type Change struct {
+	EntityType string
+	EntityID   int
+	Action     string
+	Before     interface{}
+	After      interface{}
+	RevertOf   *int
+}
+
+// Record appends a revision for a change made in tx, attributed to the user
+// in ctx. It must run in the transaction that made the change so that the
+// two commit together.
+func Record(ctx context.Context, tx *sql.Tx, c Change) error {
+	before, err := snapshot(c.Before)
+	if err != nil {
+		return err
+	}
+
+	after, err := snapshot(c.After)
+	if err != nil {
+		return err
+	}
+
+	var userID *int
+	if td, ok := middleware.UserFromContext(ctx); ok {
+		userID = &td.ID
+	}
+
+	stmt := `
+		insert into revision (workspace_id, entity_type, entity_id, action, user_id, before_snapshot, after_snapshot, revert_of, created_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = tx.ExecContext(ctx, stmt,
+		workspace.FromContext(ctx),
+		c.EntityType,
+		c.EntityID,
+		c.Action,
+		userID,
+		before,
+		after,
+		c.RevertOf,
+		time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// snapshot encodes v for a snapshot column, or nil for a nil pointer.
+func snapshot(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(b) == "null" {
+		return nil, nil
+	}
+	return b, nil
+}
+
+// Snapshot decodes into v the entity as it was after the revision, for
+// restoring it. The revision must belong to the given entity.
+func Snapshot(ctx context.Context, repo RevisionRepository, entityType string, entityID, revisionID int, v interface{}) error {
+	rev, err := repo.GetRevisionByID(ctx, revisionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if rev.EntityType != entityType || rev.EntityID != entityID {
+		return ErrNotFound
+	}
+	if rev.After == nil {
+		return ErrNothingToRestore
+	}
+
+	return json.Unmarshal(rev.After, v)
+}
+
+var _ RevisionRepository = &SQLRevisionRepository{}
+
+type RevisionRepository interface {
+	GetRevisionByID(ctx context.Context, id int) (*models.Revision, error)
+	GetRevisions(ctx context.Context, entityType string, entityID int) ([]*models.Revision, error)
+}
+
+type SQLRevisionRepository struct {
+	db *sql.DB
+}
+
+func NewSQLRevisionRepository(db *sql.DB) *SQLRevisionRepository {
+	return &SQLRevisionRepository{db: db}
+}
+
+const revisionQuery = `
+	select r.id, r.entity_type, r.entity_id, r.action, r.user_id, coalesce(u.username, ''), r.before_snapshot, r.after_snapshot, r.revert_of, r.created_at
+	from revision r
+	left join user u on u.id = r.user_id
+`
+
+func scanRevision(row interface{ Scan(...interface{}) error }) (*models.Revision, error) {
+	var rev models.Revision
+	var before, after []byte
+	err := row.Scan(
+		&rev.ID,
+		&rev.EntityType,
+		&rev.EntityID,
+		&rev.Action,
+		&rev.UserID,
+		&rev.UserName,
+		&before,
+		&after,
+		&rev.RevertOf,
+		&rev.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if before != nil {
+		rev.Before = json.RawMessage(before)
+	}
+	if after != nil {
+		rev.After = json.RawMessage(after)
+	}
+	return &rev, nil
+}
+
+func (repo *SQLRevisionRepository) GetRevisionByID(ctx context.Context, id int) (*models.Revision, error) {
+	row := repo.db.QueryRowContext(ctx, revisionQuery+` where r.id = ? and r.workspace_id = ?`, id, workspace.FromContext(ctx))
+	return scanRevision(row)
+}
+
+// GetRevisions returns the entity's revisions, newest first.
+func (repo *SQLRevisionRepository) GetRevisions(ctx context.Context, entityType string, entityID int) ([]*models.Revision, error) {
+	query := revisionQuery + ` where r.workspace_id = ? and r.entity_type = ? and r.entity_id = ? order by r.id desc`
+	rows, err := repo.db.QueryContext(ctx, query, workspace.FromContext(ctx), entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
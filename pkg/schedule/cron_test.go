@@ -0,0 +1,73 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronRejectsInvalidExpressions(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-b * * * *",
+	}
+
+	for _, expr := range tests {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2024-01-01 is a Monday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.January, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		expr string
+		t    time.Time
+		want bool
+	}{
+		{"* * * * *", at(1, 0, 0), true},
+		{"30 11 * * *", at(1, 11, 30), true},
+		{"30 11 * * *", at(1, 11, 31), false},
+		{"*/15 * * * *", at(1, 9, 45), true},
+		{"*/15 * * * *", at(1, 9, 50), false},
+		{"0-30/10 * * * *", at(1, 9, 20), true},
+		{"0-30/10 * * * *", at(1, 9, 40), false},
+		{"5/20 * * * *", at(1, 9, 45), true},
+		{"0 12 * * 1-5", at(1, 12, 0), true},
+		{"0 12 * * 1-5", at(6, 12, 0), false},
+		{"0 12 * * 0", at(7, 12, 0), true},
+		{"0 12 * * 7", at(7, 12, 0), true},
+		{"0 12 * * 1,3,5", at(3, 12, 0), true},
+		{"0 12 * * 1,3,5", at(4, 12, 0), false},
+		{"0 12 15 * *", at(15, 12, 0), true},
+		{"0 12 * 2 *", at(1, 12, 0), false},
+		// With both day fields restricted, either one matching is enough.
+		{"0 12 15 * 1", at(1, 12, 0), true},
+		{"0 12 15 * 1", at(15, 12, 0), true},
+		{"0 12 15 * 1", at(2, 12, 0), false},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		if got := c.Matches(tt.t); got != tt.want {
+			t.Errorf("%q matches %s = %v, want %v", tt.expr, tt.t.Format("Mon 2006-01-02 15:04"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package schedule
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// HolidayCalendar is a set of dates on which scheduled picks are skipped.
+type HolidayCalendar struct {
+	dates map[string]string
+	years map[int]bool
+}
+
+// LoadHolidayCalendar reads a calendar file with one holiday per line: a
+// YYYY-MM-DD date optionally followed by the holiday's name. Blank lines and
+// lines starting with # are ignored. An empty path gives an empty calendar.
+func LoadHolidayCalendar(path string) (*HolidayCalendar, error) {
+	cal := &HolidayCalendar{dates: make(map[string]string), years: make(map[int]bool)}
+	if path == "" {
+		return cal, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		date, name, _ := strings.Cut(line, " ")
+		t, err := time.Parse(dateLayout, date)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid date %q", path, lineNo, date)
+		}
+		cal.dates[date] = strings.TrimSpace(name)
+		cal.years[t.Year()] = true
+	}
+
+	return cal, scanner.Err()
+}
+
+// Holiday reports whether the calendar date of t is a holiday, and its name.
+func (c *HolidayCalendar) Holiday(t time.Time) (string, bool) {
+	name, ok := c.dates[t.Format(dateLayout)]
+	return name, ok
+}
+
+// Lacks reports whether the calendar has holidays for some years but none for
+// year, which usually means the file has not been updated for it yet.
+func (c *HolidayCalendar) Lacks(year int) bool {
+	return len(c.years) > 0 && !c.years[year]
+}
@@ -0,0 +1,60 @@
+package schedule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHolidayCalendar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "holidays.txt")
+	err := os.WriteFile(path, []byte("# comment\n\n2026-08-09 National Day\n2026-12-25 Christmas Day\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cal, err := LoadHolidayCalendar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, ok := cal.Holiday(time.Date(2026, time.August, 9, 12, 0, 0, 0, time.UTC))
+	if !ok || name != "National Day" {
+		t.Errorf("Holiday(2026-08-09) = %q, %v, want National Day", name, ok)
+	}
+	if _, ok := cal.Holiday(time.Date(2026, time.August, 10, 12, 0, 0, 0, time.UTC)); ok {
+		t.Error("2026-08-10 is a holiday, want none")
+	}
+
+	if cal.Lacks(2026) {
+		t.Error("Lacks(2026) = true for a calendar with 2026 holidays")
+	}
+	if !cal.Lacks(2027) {
+		t.Error("Lacks(2027) = false for a calendar with no 2027 holidays")
+	}
+
+	empty, err := LoadHolidayCalendar("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Lacks(2027) {
+		t.Error("an empty calendar, with holidays turned off, lacks 2027")
+	}
+}
+
+func TestLoadHolidayCalendarRejectsBadDates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "holidays.txt")
+	if err := os.WriteFile(path, []byte("2026-13-01 Nonsense\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHolidayCalendar(path); err == nil {
+		t.Error("LoadHolidayCalendar succeeded, want an error")
+	}
+}
+
+func TestShippedHolidayCalendarLoads(t *testing.T) {
+	if _, err := LoadHolidayCalendar("../../holidays/sg.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,111 @@
+package schedule
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/geo"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+const (
+	icalProductID = "-//Time-To-Makan//Lunch Schedule//EN"
+	icalUIDDomain = "time-to-makan"
+	// icalLineLimit is the maximum line length in octets, excluding CRLF.
+	icalLineLimit = 75
+)
+
+// writeCalendar writes an RFC 5545 calendar with one all-day event per pick.
+// places must contain every picked place; picks whose place is missing are
+// skipped. Event UIDs depend only on the schedule and date so that calendar
+// clients update an event in place when a day's pick is refreshed.
+func writeCalendar(w io.Writer, schedule *models.Schedule, picks []*models.SchedulePick, places map[int]*models.Place) error {
+	cw := &calendarWriter{w: w}
+
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:" + icalProductID)
+	cw.line("CALSCALE:GREGORIAN")
+	cw.line("METHOD:PUBLISH")
+	cw.line("X-WR-CALNAME:" + escapeText(schedule.Name))
+	cw.line("X-WR-TIMEZONE:" + schedule.Timezone)
+
+	for _, pick := range picks {
+		place, ok := places[pick.PlaceID]
+		if !ok {
+			continue
+		}
+
+		day := pick.PickDate
+		cw.line("BEGIN:VEVENT")
+		cw.line(fmt.Sprintf("UID:schedule-%d-%s@%s", schedule.ID, day.Format("20060102"), icalUIDDomain))
+		cw.line("DTSTAMP:" + pick.CreatedAt.UTC().Format("20060102T150405Z"))
+		cw.line("DTSTART;VALUE=DATE:" + day.Format("20060102"))
+		cw.line("DTEND;VALUE=DATE:" + day.AddDate(0, 0, 1).Format("20060102"))
+		cw.line("SUMMARY:" + escapeText(place.Name))
+		if place.Location != "" {
+			cw.line("LOCATION:" + escapeText(place.Location))
+		}
+		if lat, lon, ok := geo.ParseLatLon(place.Lat, place.Lon); ok {
+			cw.line(fmt.Sprintf("GEO:%.6f;%.6f", lat, lon))
+		}
+		if place.Description != "" {
+			cw.line("DESCRIPTION:" + escapeText(place.Description))
+		}
+		cw.line("TRANSP:TRANSPARENT")
+		cw.line("END:VEVENT")
+	}
+
+	cw.line("END:VCALENDAR")
+	return cw.err
+}
+
+// calendarWriter writes content lines with CRLF endings, folding them at
+// icalLineLimit octets without splitting UTF-8 sequences.
+type calendarWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (cw *calendarWriter) line(s string) {
+	if cw.err != nil {
+		return
+	}
+
+	var sb strings.Builder
+	limit := icalLineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut--
+		}
+		sb.WriteString(s[:cut])
+		sb.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts towards the limit.
+		limit = icalLineLimit - 1
+	}
+	sb.WriteString(s)
+	sb.WriteString("\r\n")
+
+	_, cw.err = io.WriteString(cw.w, sb.String())
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// escapeText escapes a value of the TEXT type.
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
@@ -0,0 +1,114 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+func TestCalendarWriterFoldsLongLines(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:Chicken rice"},
+		{"exactly the limit", "SUMMARY:" + strings.Repeat("a", icalLineLimit-len("SUMMARY:"))},
+		{"one over the limit", "SUMMARY:" + strings.Repeat("a", icalLineLimit-len("SUMMARY:")+1)},
+		{"several folds", "DESCRIPTION:" + strings.Repeat("abcdefghij", 30)},
+		{"multi-byte", "SUMMARY:" + strings.Repeat("鸡饭", 60)},
+		{"multi-byte at the cut", "SUMMARY:" + strings.Repeat("a", 66) + strings.Repeat("é", 20)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			cw := &calendarWriter{w: &sb}
+			cw.line(tt.line)
+			if cw.err != nil {
+				t.Fatal(cw.err)
+			}
+
+			out := sb.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("output %q does not end with CRLF", out)
+			}
+
+			physical := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			var unfolded strings.Builder
+			for i, l := range physical {
+				if len(l) > icalLineLimit {
+					t.Errorf("line %d is %d octets, over the limit of %d", i, len(l), icalLineLimit)
+				}
+				if !utf8.ValidString(l) {
+					t.Errorf("line %d splits a UTF-8 sequence: %q", i, l)
+				}
+				if i > 0 {
+					if !strings.HasPrefix(l, " ") {
+						t.Fatalf("continuation line %d does not start with a space: %q", i, l)
+					}
+					l = l[1:]
+				}
+				unfolded.WriteString(l)
+			}
+
+			if unfolded.String() != tt.line {
+				t.Errorf("unfolded line = %q, want %q", unfolded.String(), tt.line)
+			}
+		})
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Chicken rice", "Chicken rice"},
+		{"Novena; level 2, unit 5", `Novena\; level 2\, unit 5`},
+		{`back\slash`, `back\\slash`},
+		{"two\nlines", `two\nlines`},
+		{"two\r\nlines", `two\nlines`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeText(tt.in); got != tt.want {
+			t.Errorf("escapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCalendarSkipsMissingPlaces(t *testing.T) {
+	day := time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC)
+	schedule := &models.Schedule{ID: 7, Name: "Lunch", Timezone: "Asia/Singapore"}
+	picks := []*models.SchedulePick{
+		{PlaceID: 1, PickDate: day, CreatedAt: day},
+		{PlaceID: 2, PickDate: day.AddDate(0, 0, 1), CreatedAt: day},
+	}
+	places := map[int]*models.Place{
+		1: {ID: 1, Name: "Ah Hock Hokkien Mee", Location: "Novena", Lat: "1.3204", Lon: "103.8437"},
+	}
+
+	var sb strings.Builder
+	err := writeCalendar(&sb, schedule, picks, places)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := sb.String()
+
+	for _, want := range []string{
+		"UID:schedule-7-20240304@" + icalUIDDomain + "\r\n",
+		"DTSTART;VALUE=DATE:20240304\r\n",
+		"DTEND;VALUE=DATE:20240305\r\n",
+		"SUMMARY:Ah Hock Hokkien Mee\r\n",
+		"GEO:1.320400;103.843700\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("calendar does not contain %q", want)
+		}
+	}
+	if n := strings.Count(out, "BEGIN:VEVENT"); n != 1 {
+		t.Errorf("calendar has %d events, want 1", n)
+	}
+}
@@ -0,0 +1,347 @@
+package schedule
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/place"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+const defaultTimezone = "Asia/Singapore"
+
+type ScheduleDto struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	CronExpr     string `json:"cron"`
+	Timezone     string `json:"timezone"`
+	Filters      string `json:"filters"`
+	SkipHolidays bool   `json:"skip_holidays"`
+	Enabled      bool   `json:"enabled"`
+}
+
+func (dto *ScheduleDto) validate() error {
+	if dto.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if _, err := ParseCron(dto.CronExpr); err != nil {
+		return err
+	}
+
+	if dto.Timezone == "" {
+		dto.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(dto.Timezone); err != nil {
+		return errors.New("unknown timezone")
+	}
+
+	query, err := url.ParseQuery(dto.Filters)
+	if err != nil {
+		return err
+	}
+	if _, err := place.ParseGenerateParams(query); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PickDto is a schedule's pick for one day together with the place.
+type PickDto struct {
+	ScheduleID int           `json:"schedule_id"`
+	Date       string        `json:"date"`
+	DrawID     string        `json:"draw_id"`
+	Place      *models.Place `json:"place"`
+}
+
+func GetAllSchedules(repo ScheduleRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		schedules, err := repo.GetAllSchedules(ctx)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, schedules, "schedules")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func GetScheduleByID(repo ScheduleRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		schedule, err := repo.GetScheduleByID(ctx, id)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, schedule, "schedule")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func EditSchedule(repo ScheduleRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload ScheduleDto
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = payload.validate()
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		var schedule models.Schedule
+		if payload.ID != 0 {
+			m, err := repo.GetScheduleByID(ctx, payload.ID)
+			if err != nil {
+				utils.ErrorJSON(w, err)
+				return
+			}
+			schedule = *m
+		} else {
+			schedule.CreatedAt = time.Now()
+			schedule.FeedToken, err = newFeedToken()
+			if err != nil {
+				utils.ErrorJSON(w, err)
+				return
+			}
+		}
+
+		schedule.ID = payload.ID
+		schedule.Name = payload.Name
+		schedule.CronExpr = payload.CronExpr
+		schedule.Timezone = payload.Timezone
+		schedule.Filters = payload.Filters
+		schedule.SkipHolidays = payload.SkipHolidays
+		schedule.Enabled = payload.Enabled
+		schedule.UpdatedAt = time.Now()
+
+		if schedule.ID == 0 {
+			err = repo.InsertSchedule(ctx, schedule)
+		} else {
+			err = repo.UpdateSchedule(ctx, schedule)
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, nil, "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func DeleteSchedule(repo ScheduleRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		err = repo.DeleteSchedule(ctx, id)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, "Deleted Successfully", "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// GetTodayPick returns the schedule's pick for today in the schedule's
+// timezone.
+func GetTodayPick(repo ScheduleRepository, placeRepo place.PlaceRepository, holidays *HolidayCalendar) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		schedule, err := repo.GetScheduleByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.ErrorJSON(w, errors.New("schedule does not exist"), http.StatusNotFound)
+				return
+			}
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		loc, err := time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+		today := localDate(time.Now().In(loc))
+
+		pick, err := repo.GetPick(ctx, schedule.ID, today)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				message := "no pick has been made today yet"
+				if name, ok := holidays.Holiday(today); ok && schedule.SkipHolidays {
+					message = "no pick today: " + name
+				}
+				utils.ErrorJSON(w, errors.New(message), http.StatusNotFound)
+				return
+			}
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		// The pick stands even if its place has since been deleted.
+		places, err := placeRepo.GetPlacesByIDs(ctx, []int{pick.PlaceID})
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		payload := PickDto{
+			ScheduleID: pick.ScheduleID,
+			Date:       today.Format(dateLayout),
+			DrawID:     pick.DrawID,
+			Place:      places[pick.PlaceID],
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, payload, "pick")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// calendarDays is how far back the calendar feed reaches.
+const calendarDays = 90
+
+// GetCalendar serves the picks of the schedule with the feed token in the
+// URL as an iCalendar feed. Calendar apps cannot send a login token, so the
+// feed token alone picks the schedule and its workspace. The token is either
+// part of the path or, under /schedules/{id}/calendar.ics, the token query
+// parameter, which must then belong to that schedule.
+func GetCalendar(repo ScheduleRepository, placeRepo place.PlaceRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		token := vars["token"]
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if token == "" {
+			utils.ErrorJSON(w, errors.New("calendar does not exist"), http.StatusNotFound)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		schedule, err := repo.GetScheduleByFeedToken(ctx, token)
+		if err == nil && vars["id"] != "" && vars["id"] != strconv.Itoa(schedule.ID) {
+			err = sql.ErrNoRows
+		}
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.ErrorJSON(w, errors.New("calendar does not exist"), http.StatusNotFound)
+				return
+			}
+			utils.ErrorJSON(w, err)
+			return
+		}
+		ctx = workspace.NewContext(ctx, schedule.WorkspaceID)
+
+		loc, err := time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+		today := localDate(time.Now().In(loc))
+
+		picks, err := repo.GetPicks(ctx, schedule.ID, today.AddDate(0, 0, -calendarDays), today)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		// Past picks stay on the calendar after their place is deleted.
+		// Merges move picks to the surviving place themselves.
+		ids := make([]int, 0, len(picks))
+		for _, pick := range picks {
+			ids = append(ids, pick.PlaceID)
+		}
+		places, err := placeRepo.GetPlacesByIDs(ctx, ids)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="schedule-%d.ics"`, schedule.ID))
+		w.WriteHeader(http.StatusOK)
+
+		err = writeCalendar(w, schedule, picks, places)
+		if err != nil {
+			log.Println("error writing calendar", err)
+		}
+	}
+}
+
+func newFeedToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
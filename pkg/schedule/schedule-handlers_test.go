@@ -0,0 +1,78 @@
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/place"
+)
+
+type feedRepo struct {
+	ScheduleRepository
+	schedules []*models.Schedule
+}
+
+func (r feedRepo) GetScheduleByFeedToken(ctx context.Context, token string) (*models.Schedule, error) {
+	for _, s := range r.schedules {
+		if s.FeedToken == token {
+			return s, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r feedRepo) GetPicks(ctx context.Context, scheduleID int, from, to time.Time) ([]*models.SchedulePick, error) {
+	return nil, nil
+}
+
+type feedPlaceRepo struct {
+	place.PlaceRepository
+}
+
+func (feedPlaceRepo) GetPlacesByIDs(ctx context.Context, ids []int) (map[int]*models.Place, error) {
+	return map[int]*models.Place{}, nil
+}
+
+func TestGetCalendarChecksTheFeedToken(t *testing.T) {
+	repo := feedRepo{schedules: []*models.Schedule{
+		{ID: 1, Name: "Lunch", Timezone: "Asia/Singapore", FeedToken: "aaaa", WorkspaceID: 1},
+		{ID: 2, Name: "Dinner", Timezone: "Asia/Singapore", FeedToken: "bbbb", WorkspaceID: 2},
+	}}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/calendars/{token:[0-9a-f]+}.ics", GetCalendar(repo, feedPlaceRepo{}))
+	r.HandleFunc("/schedules/{id:[0-9]+}/calendar.ics", GetCalendar(repo, feedPlaceRepo{}))
+
+	tests := []struct {
+		path string
+		want int
+		name string
+	}{
+		{"/calendars/aaaa.ics", http.StatusOK, "Lunch"},
+		{"/calendars/bbbb.ics", http.StatusOK, "Dinner"},
+		{"/calendars/cccc.ics", http.StatusNotFound, ""},
+		{"/schedules/1/calendar.ics?token=aaaa", http.StatusOK, "Lunch"},
+		{"/schedules/1/calendar.ics?token=bbbb", http.StatusNotFound, ""},
+		{"/schedules/2/calendar.ics", http.StatusNotFound, ""},
+		{"/schedules/2/calendar.ics?workspace=2", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.want)
+			continue
+		}
+		if tt.name != "" && !strings.Contains(rec.Body.String(), "X-WR-CALNAME:"+tt.name) {
+			t.Errorf("GET %s does not serve the %s calendar", tt.path, tt.name)
+		}
+	}
+}
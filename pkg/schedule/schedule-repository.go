@@ -0,0 +1,217 @@
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+var _ ScheduleRepository = &SQLScheduleRepository{}
+
+type ScheduleRepository interface {
+	GetScheduleByID(ctx context.Context, id int) (*models.Schedule, error)
+	GetScheduleByFeedToken(ctx context.Context, token string) (*models.Schedule, error)
+	GetAllSchedules(ctx context.Context) ([]*models.Schedule, error)
+	InsertSchedule(ctx context.Context, schedule models.Schedule) error
+	UpdateSchedule(ctx context.Context, schedule models.Schedule) error
+	DeleteSchedule(ctx context.Context, id int) error
+	GetPick(ctx context.Context, scheduleID int, date time.Time) (*models.SchedulePick, error)
+	GetPicks(ctx context.Context, scheduleID int, from, to time.Time) ([]*models.SchedulePick, error)
+	InsertPick(ctx context.Context, pick models.SchedulePick) error
+}
+
+type SQLScheduleRepository struct {
+	db *sql.DB
+}
+
+func NewSQLScheduleRepository(db *sql.DB) *SQLScheduleRepository {
+	return &SQLScheduleRepository{db: db}
+}
+
+const scheduleColumns = `id, name, cron_expr, timezone, filters, skip_holidays, enabled, feed_token, workspace_id, created_at, updated_at`
+
+func scanSchedule(row interface{ Scan(...interface{}) error }) (*models.Schedule, error) {
+	var s models.Schedule
+	err := row.Scan(
+		&s.ID,
+		&s.Name,
+		&s.CronExpr,
+		&s.Timezone,
+		&s.Filters,
+		&s.SkipHolidays,
+		&s.Enabled,
+		&s.FeedToken,
+		&s.WorkspaceID,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (repo *SQLScheduleRepository) GetScheduleByID(ctx context.Context, id int) (*models.Schedule, error) {
+	row := repo.db.QueryRowContext(ctx, `select `+scheduleColumns+` from schedule where id = ? and workspace_id = ?`, id, workspace.FromContext(ctx))
+	return scanSchedule(row)
+}
+
+// GetScheduleByFeedToken finds a schedule in any workspace by its calendar
+// feed token.
+func (repo *SQLScheduleRepository) GetScheduleByFeedToken(ctx context.Context, token string) (*models.Schedule, error) {
+	row := repo.db.QueryRowContext(ctx, `select `+scheduleColumns+` from schedule where feed_token = ?`, token)
+	return scanSchedule(row)
+}
+
+func (repo *SQLScheduleRepository) GetAllSchedules(ctx context.Context) ([]*models.Schedule, error) {
+	rows, err := repo.db.QueryContext(ctx, `select `+scheduleColumns+` from schedule where workspace_id = ? order by name`, workspace.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var schedules []*models.Schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func (repo *SQLScheduleRepository) InsertSchedule(ctx context.Context, s models.Schedule) error {
+	stmt := `
+		insert into schedule (name, cron_expr, timezone, filters, skip_holidays, enabled, feed_token, created_at, updated_at, workspace_id)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := repo.db.ExecContext(ctx, stmt,
+		s.Name,
+		s.CronExpr,
+		s.Timezone,
+		s.Filters,
+		s.SkipHolidays,
+		s.Enabled,
+		s.FeedToken,
+		s.CreatedAt,
+		s.UpdatedAt,
+		workspace.FromContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *SQLScheduleRepository) UpdateSchedule(ctx context.Context, s models.Schedule) error {
+	stmt := `
+		update schedule set name = ?, cron_expr = ?, timezone = ?, filters = ?, skip_holidays = ?, enabled = ?, updated_at = ?
+		where id = ? and workspace_id = ?
+	`
+	_, err := repo.db.ExecContext(ctx, stmt,
+		s.Name,
+		s.CronExpr,
+		s.Timezone,
+		s.Filters,
+		s.SkipHolidays,
+		s.Enabled,
+		s.UpdatedAt,
+		s.ID,
+		workspace.FromContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *SQLScheduleRepository) DeleteSchedule(ctx context.Context, id int) error {
+	_, err := repo.db.ExecContext(ctx, `delete from schedule where id = ? and workspace_id = ?`, id, workspace.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *SQLScheduleRepository) GetPick(ctx context.Context, scheduleID int, date time.Time) (*models.SchedulePick, error) {
+	query := `select schedule_id, pick_date, place_id, draw_id, created_at 
+		from schedule_pick where schedule_id = ? and pick_date = ?
+		and schedule_id in (select id from schedule where workspace_id = ?)`
+
+	var pick models.SchedulePick
+	err := repo.db.QueryRowContext(ctx, query, scheduleID, date.Format(dateLayout), workspace.FromContext(ctx)).Scan(
+		&pick.ScheduleID,
+		&pick.PickDate,
+		&pick.PlaceID,
+		&pick.DrawID,
+		&pick.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pick, nil
+}
+
+// GetPicks returns the schedule's picks dated from from to to inclusive.
+func (repo *SQLScheduleRepository) GetPicks(ctx context.Context, scheduleID int, from, to time.Time) ([]*models.SchedulePick, error) {
+	query := `
+		select schedule_id, pick_date, place_id, draw_id, created_at from schedule_pick
+		where schedule_id = ? and pick_date between ? and ?
+		and schedule_id in (select id from schedule where workspace_id = ?)
+		order by pick_date
+	`
+	rows, err := repo.db.QueryContext(ctx, query, scheduleID, from.Format(dateLayout), to.Format(dateLayout), workspace.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var picks []*models.SchedulePick
+	for rows.Next() {
+		var pick models.SchedulePick
+		err := rows.Scan(
+			&pick.ScheduleID,
+			&pick.PickDate,
+			&pick.PlaceID,
+			&pick.DrawID,
+			&pick.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		picks = append(picks, &pick)
+	}
+	return picks, rows.Err()
+}
+
+// InsertPick stores the day's pick unless one already exists, reporting
+// whether it was stored. This keeps the first pick when several instances
+// run the scheduler.
+func (repo *SQLScheduleRepository) InsertPick(ctx context.Context, pick models.SchedulePick) error {
+	stmt := `
+		insert ignore into schedule_pick (schedule_id, pick_date, place_id, draw_id, created_at)
+		values (?, ?, ?, ?, ?)
+	`
+	_, err := repo.db.ExecContext(ctx, stmt,
+		pick.ScheduleID,
+		pick.PickDate.Format(dateLayout),
+		pick.PlaceID,
+		pick.DrawID,
+		pick.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
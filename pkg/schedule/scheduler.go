@@ -0,0 +1,187 @@
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/url"
+	"time"
+
+	_ "time/tzdata"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/draw"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/place"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/preference"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+// Scheduler runs every enabled schedule whose cron expression matches the
+// current minute and stores the result as that day's pick. Each workspace's
+// schedules draw from that workspace's places.
+type Scheduler struct {
+	repo          ScheduleRepository
+	workspaceRepo workspace.WorkspaceRepository
+	placeRepo     place.PlaceRepository
+	areaRepo      area.AreaRepository
+	prefRepo      preference.PreferenceRepository
+	drawRepo      draw.DrawRepository
+	holidays      *HolidayCalendar
+	hooks         webhook.Publisher
+	logger        *log.Logger
+
+	// warnedYear is the last year the holiday calendar was found lacking,
+	// so the warning is logged once a year rather than every minute.
+	warnedYear int
+}
+
+func NewScheduler(repo ScheduleRepository, workspaceRepo workspace.WorkspaceRepository, placeRepo place.PlaceRepository, areaRepo area.AreaRepository, prefRepo preference.PreferenceRepository, drawRepo draw.DrawRepository, holidays *HolidayCalendar, hooks webhook.Publisher, logger *log.Logger) *Scheduler {
+	return &Scheduler{
+		repo:          repo,
+		workspaceRepo: workspaceRepo,
+		placeRepo:     placeRepo,
+		areaRepo:      areaRepo,
+		prefRepo:      prefRepo,
+		drawRepo:      drawRepo,
+		holidays:      holidays,
+		hooks:         hooks,
+		logger:        logger,
+	}
+}
+
+// Run checks the schedules at the start of every minute until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(next.Sub(now)):
+			s.tick(ctx, next)
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	tickCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if year := now.Year(); s.holidays.Lacks(year) && s.warnedYear != year {
+		s.logger.Printf("scheduler: warning: the holiday calendar has no holidays in %d, so schedules will pick on public holidays", year)
+		s.warnedYear = year
+	}
+
+	workspaces, err := s.workspaceRepo.GetAllWorkspaces(tickCtx)
+	if err != nil {
+		s.logger.Println("scheduler: loading workspaces:", err)
+		return
+	}
+
+	for _, ws := range workspaces {
+		wsCtx := workspace.NewContext(tickCtx, ws.ID)
+		schedules, err := s.repo.GetAllSchedules(wsCtx)
+		if err != nil {
+			s.logger.Printf("scheduler: loading schedules of workspace %d: %v", ws.ID, err)
+			continue
+		}
+
+		for _, sched := range schedules {
+			if !sched.Enabled {
+				continue
+			}
+
+			err := s.runSchedule(wsCtx, sched, now)
+			if err != nil {
+				s.logger.Printf("scheduler: schedule %d (%s): %v", sched.ID, sched.Name, err)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) runSchedule(ctx context.Context, sched *models.Schedule, now time.Time) error {
+	cron, err := ParseCron(sched.CronExpr)
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(sched.Timezone)
+	if err != nil {
+		return err
+	}
+
+	local := now.In(loc)
+	if !cron.Matches(local) {
+		return nil
+	}
+
+	if sched.SkipHolidays {
+		if name, ok := s.holidays.Holiday(local); ok {
+			s.logger.Printf("scheduler: schedule %d skipped for %s (%s)", sched.ID, local.Format(dateLayout), name)
+			return nil
+		}
+	}
+
+	today := localDate(local)
+	_, err = s.repo.GetPick(ctx, sched.ID, today)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	return s.pickForDate(ctx, sched, today)
+}
+
+// pickForDate generates a place with the schedule's saved filters and stores
+// it as the pick for date.
+func (s *Scheduler) pickForDate(ctx context.Context, sched *models.Schedule, date time.Time) error {
+	query, err := url.ParseQuery(sched.Filters)
+	if err != nil {
+		return err
+	}
+
+	params, err := place.ParseGenerateParams(query)
+	if err != nil {
+		return err
+	}
+	params.Count = 0
+
+	outcome, err := place.Generate(ctx, s.placeRepo, s.areaRepo, s.prefRepo, s.drawRepo, params, nil)
+	if err != nil {
+		return err
+	}
+
+	pick := models.SchedulePick{
+		ScheduleID: sched.ID,
+		PickDate:   date,
+		PlaceID:    outcome.Picked[0].ID,
+		DrawID:     outcome.DrawID,
+		CreatedAt:  time.Now(),
+	}
+
+	err = s.repo.InsertPick(ctx, pick)
+	if err != nil {
+		return err
+	}
+
+	s.hooks.Publish(ctx, webhook.EventPickGenerated, webhook.PickEvent{
+		DrawID:     outcome.DrawID,
+		Seed:       outcome.Seed,
+		Source:     place.PickSourceSchedule,
+		ScheduleID: &sched.ID,
+		Places:     outcome.Picked,
+	})
+	return nil
+}
+
+// localDate returns midnight of t's calendar date in t's location.
+func localDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,64 @@
+package slack
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/dietary"
+)
+
+// commandParams are the generatePlace parameters that can be given as
+// key=value in the command text.
+var commandParams = map[string]bool{
+	"category":            true,
+	"dietary":             true,
+	"max_price":           true,
+	"budget":              true,
+	"min_rating":          true,
+	"lat":                 true,
+	"lon":                 true,
+	"radius_km":           true,
+	"area":                true,
+	"exclude_recent_days": true,
+	"open_now":            true,
+	"open_at":             true,
+	"seed":                true,
+}
+
+const commandHelp = "Usage: `/makan [halal] [veg] [vegan] [$-$$$$] [category ...] [key=value ...]`\n" +
+	"Examples: `/makan halal veg`, `/makan $$ japanese`, `/makan min_rating=4 exclude_recent_days=7`, `/makan area=novena`"
+
+// parseCommandText turns the slash command text into a generatePlace query.
+// Keywords cover the common filters, key=value passes any other filter
+// through, and any remaining word is taken as a category.
+func parseCommandText(text string) (url.Values, error) {
+	query := url.Values{}
+
+	for _, word := range strings.Fields(text) {
+		lower := strings.ToLower(word)
+
+		switch {
+		case lower == "halal":
+			query.Set("is_halal", "true")
+		case lower == "veg" || lower == "vegetarian":
+			query.Set("is_vegetarian", "true")
+		case lower == "vegan":
+			query.Add("dietary", dietary.TagVegan)
+		case strings.Trim(lower, "$") == "" && len(lower) <= 4:
+			query.Set("max_price", strconv.Itoa(len(lower)))
+		case strings.Contains(word, "="):
+			kv := strings.SplitN(word, "=", 2)
+			key := strings.ToLower(kv[0])
+			if !commandParams[key] {
+				return nil, fmt.Errorf("unknown option %q", kv[0])
+			}
+			query.Add(key, kv[1])
+		default:
+			query.Add("category", word)
+		}
+	}
+
+	return query, nil
+}
@@ -0,0 +1,50 @@
+package slack
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/dietary"
+)
+
+func TestParseCommandText(t *testing.T) {
+	tests := []struct {
+		text string
+		want url.Values
+	}{
+		{"", url.Values{}},
+		{"halal", url.Values{"is_halal": {"true"}}},
+		{"Halal veg", url.Values{"is_halal": {"true"}, "is_vegetarian": {"true"}}},
+		{"vegetarian", url.Values{"is_vegetarian": {"true"}}},
+		{"vegan", url.Values{"dietary": {dietary.TagVegan}}},
+		{"$$", url.Values{"max_price": {"2"}}},
+		{"$$$$", url.Values{"max_price": {"4"}}},
+		{"$$$$$", url.Values{"category": {"$$$$$"}}},
+		{"halal veg $$", url.Values{"is_halal": {"true"}, "is_vegetarian": {"true"}, "max_price": {"2"}}},
+		{"Japanese Korean", url.Values{"category": {"Japanese", "Korean"}}},
+		{"area=Novena radius_km=2", url.Values{"area": {"Novena"}, "radius_km": {"2"}}},
+		{"MAX_PRICE=3", url.Values{"max_price": {"3"}}},
+		{"open_now=true", url.Values{"open_now": {"true"}}},
+		{"dietary=gluten-free vegan", url.Values{"dietary": {"gluten-free", dietary.TagVegan}}},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCommandText(tt.text)
+		if err != nil {
+			t.Errorf("parseCommandText(%q): %v", tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCommandText(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseCommandTextRejectsUnknownOptions(t *testing.T) {
+	for _, text := range []string{"foo=bar", "halal is_halal=false", "=1"} {
+		if _, err := parseCommandText(text); err == nil {
+			t.Errorf("parseCommandText(%q) succeeded, want an error", text)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+// Action IDs of the buttons on a pick message. All of them carry the draw ID
+// as their value.
+const (
+	actionReroll = "reroll"
+	actionVote   = "vote"
+	actionClose  = "close"
+)
+
+// Message is the subset of a Slack message payload used for replies, either
+// as a slash command response or posted to a response_url.
+type Message struct {
+	ResponseType    string  `json:"response_type,omitempty"`
+	ReplaceOriginal bool    `json:"replace_original,omitempty"`
+	Text            string  `json:"text"`
+	Blocks          []Block `json:"blocks,omitempty"`
+}
+
+type Block struct {
+	Type    string `json:"type"`
+	BlockID string `json:"block_id,omitempty"`
+	Text    *Text  `json:"text,omitempty"`
+	// Elements holds Text items in a context block and Buttons in an
+	// actions block.
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+type Text struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+type Button struct {
+	Type     string `json:"type"`
+	Text     *Text  `json:"text,omitempty"`
+	ActionID string `json:"action_id,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Style    string `json:"style,omitempty"`
+}
+
+func ephemeral(text string) Message {
+	return Message{ResponseType: "ephemeral", Text: text}
+}
+
+// pickMessage renders a drawn place with reroll, vote and close buttons.
+func pickMessage(place *models.Place, drawID string, votes int) Message {
+	return renderPick(place, drawID, Block{
+		Type:    "actions",
+		BlockID: "pick",
+		Elements: []interface{}{
+			Button{Type: "button", Text: &Text{Type: "plain_text", Text: ":game_die: Reroll", Emoji: true}, ActionID: actionReroll, Value: drawID},
+			Button{Type: "button", Text: &Text{Type: "plain_text", Text: fmt.Sprintf(":thumbsup: Vote (%d)", votes), Emoji: true}, ActionID: actionVote, Value: drawID, Style: "primary"},
+			Button{Type: "button", Text: &Text{Type: "plain_text", Text: ":lock: Close vote", Emoji: true}, ActionID: actionClose, Value: drawID},
+		},
+	})
+}
+
+// closedMessage renders a drawn place whose vote has closed, with the final
+// count in place of the buttons.
+func closedMessage(place *models.Place, drawID string, votes int) Message {
+	noun := "votes"
+	if votes == 1 {
+		noun = "vote"
+	}
+
+	return renderPick(place, drawID, Block{
+		Type:     "context",
+		Elements: []interface{}{Text{Type: "mrkdwn", Text: fmt.Sprintf(":lock: Voting closed with %d %s", votes, noun)}},
+	})
+}
+
+func renderPick(place *models.Place, drawID string, footer Block) Message {
+	title := fmt.Sprintf(":fork_and_knife: *%s*", escape(place.Name))
+	if place.Description != "" {
+		title += "\n" + escape(place.Description)
+	}
+
+	var details []string
+	for _, d := range []string{place.Location, place.Category} {
+		if d != "" {
+			details = append(details, escape(d))
+		}
+	}
+	if place.PriceLevel > 0 {
+		details = append(details, strings.Repeat("$", place.PriceLevel))
+	}
+	if place.RatingCount > 0 {
+		details = append(details, fmt.Sprintf("★ %.1f (%d)", place.AverageRating, place.RatingCount))
+	}
+
+	blocks := []Block{
+		{Type: "section", Text: &Text{Type: "mrkdwn", Text: title}},
+	}
+	if len(details) > 0 {
+		blocks = append(blocks, Block{
+			Type:     "context",
+			Elements: []interface{}{Text{Type: "mrkdwn", Text: strings.Join(details, " · ")}},
+		})
+	}
+	blocks = append(blocks,
+		footer,
+		Block{
+			Type:     "context",
+			Elements: []interface{}{Text{Type: "mrkdwn", Text: fmt.Sprintf("Draw `%s`", drawID)}},
+		},
+	)
+
+	return Message{
+		ResponseType: "in_channel",
+		Text:         "Let's makan at " + place.Name,
+		Blocks:       blocks,
+	}
+}
+
+var mrkdwnEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escape escapes the characters that Slack's mrkdwn treats as control
+// sequences.
+func escape(s string) string {
+	return mrkdwnEscaper.Replace(s)
+}
@@ -0,0 +1,69 @@
+package slack
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+const (
+	HeaderTimestamp = "X-Slack-Request-Timestamp"
+	HeaderSignature = "X-Slack-Signature"
+
+	// maxClockSkew rejects replayed requests, as Slack recommends.
+	maxClockSkew = 5 * time.Minute
+	maxBodyBytes = 1 << 20
+)
+
+// Sign returns the X-Slack-Signature value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + strconv.FormatInt(t.Unix(), 10) + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify rejects requests that do not carry a valid Slack signature for the
+// app's signing secret. The body is restored so that next can read it.
+func Verify(secret string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if secret == "" {
+			utils.ErrorJSON(w, errors.New("the Slack integration is not configured"), http.StatusServiceUnavailable)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			utils.ErrorJSON(w, errors.New("invalid request timestamp"), http.StatusUnauthorized)
+			return
+		}
+		sent := time.Unix(ts, 0)
+		if skew := time.Since(sent); skew > maxClockSkew || skew < -maxClockSkew {
+			utils.ErrorJSON(w, errors.New("stale request timestamp"), http.StatusUnauthorized)
+			return
+		}
+
+		expected := Sign(secret, sent, body)
+		if !hmac.Equal([]byte(expected), []byte(r.Header.Get(HeaderSignature))) {
+			utils.ErrorJSON(w, errors.New("invalid request signature"), http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,126 @@
+package slack
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+	raw, err := os.ReadFile("testdata/command.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(strings.TrimSpace(string(raw)))
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		want      int
+	}{
+		{
+			name:      "valid",
+			secret:    secret,
+			timestamp: strconv.FormatInt(now.Unix(), 10),
+			signature: Sign(secret, now, body),
+			body:      body,
+			want:      http.StatusOK,
+		},
+		{
+			name:      "wrong secret",
+			secret:    secret,
+			timestamp: strconv.FormatInt(now.Unix(), 10),
+			signature: Sign("some other secret", now, body),
+			body:      body,
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "tampered body",
+			secret:    secret,
+			timestamp: strconv.FormatInt(now.Unix(), 10),
+			signature: Sign(secret, now, body),
+			body:      bytes.Replace(body, []byte("halal"), []byte("vegan"), 1),
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "stale timestamp",
+			secret:    secret,
+			timestamp: strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10),
+			signature: Sign(secret, now.Add(-10*time.Minute), body),
+			body:      body,
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:      "future timestamp",
+			secret:    secret,
+			timestamp: strconv.FormatInt(now.Add(10*time.Minute).Unix(), 10),
+			signature: Sign(secret, now.Add(10*time.Minute), body),
+			body:      body,
+			want:      http.StatusUnauthorized,
+		},
+		{
+			name:   "missing headers",
+			secret: secret,
+			body:   body,
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:      "not configured",
+			secret:    "",
+			timestamp: strconv.FormatInt(now.Unix(), 10),
+			signature: Sign("", now, body),
+			body:      body,
+			want:      http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []byte
+			handler := Verify(tt.secret, func(w http.ResponseWriter, r *http.Request) {
+				got, _ = io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodPost, srv.URL, bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.timestamp != "" {
+				req.Header.Set(HeaderTimestamp, tt.timestamp)
+			}
+			if tt.signature != "" {
+				req.Header.Set(HeaderSignature, tt.signature)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == http.StatusOK && !bytes.Equal(got, tt.body) {
+				t.Errorf("next read body %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/area"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/draw"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/place"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/preference"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
+)
+
+var responseClient = &http.Client{Timeout: 5 * time.Second}
+
+// interactionPayload is the subset of a block_actions payload that the
+// buttons need.
+type interactionPayload struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	ResponseURL string `json:"response_url"`
+	Actions     []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// Command handles the /makan slash command. Slack shows whatever the response
+// body says, so errors are returned as ephemeral messages with status 200.
+func Command(repo place.PlaceRepository, areaRepo area.AreaRepository, prefRepo preference.PreferenceRepository, drawRepo draw.DrawRepository, hooks webhook.Publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			writeMessage(w, ephemeral("Sorry, that request could not be read."))
+			return
+		}
+
+		text := strings.TrimSpace(r.PostForm.Get("text"))
+		if strings.EqualFold(text, "help") {
+			writeMessage(w, ephemeral(commandHelp))
+			return
+		}
+
+		query, err := parseCommandText(text)
+		if err != nil {
+			writeMessage(w, ephemeral(err.Error()+"\n"+commandHelp))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		writeMessage(w, generate(ctx, repo, areaRepo, prefRepo, drawRepo, hooks, query))
+	}
+}
+
+// Interact handles the reroll, vote and close buttons. Slack ignores the response
+// body for block actions, so the updated message is posted to the payload's
+// response_url.
+func Interact(repo place.PlaceRepository, areaRepo area.AreaRepository, prefRepo preference.PreferenceRepository, drawRepo draw.DrawRepository, slackRepo SlackRepository, hooks webhook.Publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var payload interactionPayload
+		err = json.Unmarshal([]byte(r.PostForm.Get("payload")), &payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if payload.Type != "block_actions" || len(payload.Actions) == 0 || payload.ResponseURL == "" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		action := payload.Actions[0]
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		var msg Message
+		switch action.ActionID {
+		case actionReroll:
+			msg = reroll(ctx, repo, areaRepo, prefRepo, drawRepo, hooks, action.Value)
+		case actionVote:
+			msg = vote(ctx, repo, drawRepo, slackRepo, action.Value, payload.User.ID)
+		case actionClose:
+			msg = closeVote(ctx, repo, drawRepo, slackRepo, hooks, action.Value, payload.User.ID)
+		default:
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// The request is signed, so response_url comes from Slack.
+		err = postResponse(ctx, payload.ResponseURL, msg)
+		if err != nil {
+			log.Println("slack: posting response:", err)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// generate draws a place for the query and renders it, or renders the reason
+// it could not.
+func generate(ctx context.Context, repo place.PlaceRepository, areaRepo area.AreaRepository, prefRepo preference.PreferenceRepository, drawRepo draw.DrawRepository, hooks webhook.Publisher, query url.Values) Message {
+	params, err := place.ParseGenerateParams(query)
+	if err != nil {
+		return ephemeral(err.Error() + "\n" + commandHelp)
+	}
+
+	outcome, err := place.Generate(ctx, repo, areaRepo, prefRepo, drawRepo, params, nil)
+	if errors.Is(err, area.ErrAreaNotFound) {
+		return ephemeral("There is no area called " + params.Filter.Area + ".")
+	}
+	if errors.Is(err, place.ErrNoPlaceMatches) {
+		return ephemeral("No place matches those filters. Try fewer of them.")
+	}
+	if err != nil {
+		log.Println("slack: generating place:", err)
+		return ephemeral("Sorry, something went wrong picking a place.")
+	}
+
+	hooks.Publish(ctx, webhook.EventPickGenerated, webhook.PickEvent{
+		DrawID: outcome.DrawID,
+		Seed:   outcome.Seed,
+		Source: place.PickSourceSlack,
+		Places: outcome.Picked,
+	})
+
+	return pickMessage(outcome.Picked[0], outcome.DrawID, 0)
+}
+
+// reroll draws again with the filters of an earlier draw, replacing its
+// message.
+func reroll(ctx context.Context, repo place.PlaceRepository, areaRepo area.AreaRepository, prefRepo preference.PreferenceRepository, drawRepo draw.DrawRepository, hooks webhook.Publisher, drawID string) Message {
+	d, err := drawRepo.GetDrawByID(ctx, drawID)
+	if err != nil {
+		log.Println("slack: loading draw:", err)
+		return ephemeral("Sorry, that pick could not be found.")
+	}
+
+	var query url.Values
+	err = json.Unmarshal(d.Filters, &query)
+	if err != nil {
+		return ephemeral("Sorry, that pick cannot be rerolled.")
+	}
+	query.Del("seed")
+
+	msg := generate(ctx, repo, areaRepo, prefRepo, drawRepo, hooks, query)
+	if msg.ResponseType != "ephemeral" {
+		msg.ReplaceOriginal = true
+	}
+	return msg
+}
+
+// vote records the user's vote for a draw and updates the message's count.
+func vote(ctx context.Context, repo place.PlaceRepository, drawRepo draw.DrawRepository, slackRepo SlackRepository, drawID string, slackUserID string) Message {
+	d, err := drawRepo.GetDrawByID(ctx, drawID)
+	if err != nil {
+		log.Println("slack: loading draw:", err)
+		return ephemeral("Sorry, that pick could not be found.")
+	}
+	if len(d.PickedPlaceIDs) == 0 {
+		return ephemeral("Sorry, that pick could not be found.")
+	}
+
+	closed, err := slackRepo.IsVoteClosed(ctx, drawID)
+	if err != nil {
+		log.Println("slack: loading vote:", err)
+		return ephemeral("Sorry, your vote could not be recorded.")
+	}
+	if closed {
+		return ephemeral("Voting on that pick has closed.")
+	}
+
+	err = slackRepo.AddVote(ctx, drawID, slackUserID)
+	if err != nil {
+		log.Println("slack: recording vote:", err)
+		return ephemeral("Sorry, your vote could not be recorded.")
+	}
+
+	votes, err := slackRepo.CountVotes(ctx, drawID)
+	if err != nil {
+		log.Println("slack: counting votes:", err)
+		return ephemeral("Sorry, your vote could not be recorded.")
+	}
+
+	p, err := repo.GetPlaceByID(ctx, d.PickedPlaceIDs[0])
+	if err != nil {
+		log.Println("slack: loading place:", err)
+		return ephemeral("Your vote was recorded.")
+	}
+
+	msg := pickMessage(p, drawID, votes)
+	msg.ReplaceOriginal = true
+	return msg
+}
+
+// closeVote closes voting on a draw, replaces its message with the final
+// count and sends session.closed to webhooks the first time it is closed.
+func closeVote(ctx context.Context, repo place.PlaceRepository, drawRepo draw.DrawRepository, slackRepo SlackRepository, hooks webhook.Publisher, drawID string, slackUserID string) Message {
+	d, err := drawRepo.GetDrawByID(ctx, drawID)
+	if err != nil {
+		log.Println("slack: loading draw:", err)
+		return ephemeral("Sorry, that pick could not be found.")
+	}
+	if len(d.PickedPlaceIDs) == 0 {
+		return ephemeral("Sorry, that pick could not be found.")
+	}
+
+	closedNow, err := slackRepo.CloseVote(ctx, drawID, slackUserID)
+	if err != nil {
+		log.Println("slack: closing vote:", err)
+		return ephemeral("Sorry, the vote could not be closed.")
+	}
+
+	votes, err := slackRepo.CountVotes(ctx, drawID)
+	if err != nil {
+		log.Println("slack: counting votes:", err)
+		return ephemeral("Sorry, the vote could not be closed.")
+	}
+
+	// Unlike GetPlaceByID this still finds the place if it was deleted since
+	// the draw.
+	places, err := repo.GetPlacesByIDs(ctx, d.PickedPlaceIDs[:1])
+	if err != nil {
+		log.Println("slack: loading place:", err)
+		return ephemeral("The vote is closed.")
+	}
+	p, ok := places[d.PickedPlaceIDs[0]]
+	if !ok {
+		return ephemeral("The vote is closed.")
+	}
+
+	if closedNow {
+		hooks.Publish(ctx, webhook.EventSessionClosed, webhook.SessionClosedEvent{
+			DrawID:   drawID,
+			Source:   place.PickSourceSlack,
+			Votes:    votes,
+			Place:    p,
+			ClosedBy: slackUserID,
+		})
+	}
+
+	msg := closedMessage(p, drawID, votes)
+	msg.ReplaceOriginal = true
+	return msg
+}
+
+func writeMessage(w http.ResponseWriter, msg Message) {
+	js, err := json.Marshal(msg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+func postResponse(ctx context.Context, responseURL string, msg Message) error {
+	js, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(js))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := responseClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("response_url responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package slack
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/draw"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/place"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
+)
+
+type memDrawRepo struct {
+	draw.DrawRepository
+	draws map[string]*models.Draw
+}
+
+func (r memDrawRepo) GetDrawByID(ctx context.Context, id string) (*models.Draw, error) {
+	d, ok := r.draws[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return d, nil
+}
+
+type memPlaceRepo struct {
+	place.PlaceRepository
+	places map[int]*models.Place
+}
+
+func (r memPlaceRepo) GetPlaceByID(ctx context.Context, id int) (*models.Place, error) {
+	p, ok := r.places[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return p, nil
+}
+
+func (r memPlaceRepo) GetPlacesByIDs(ctx context.Context, ids []int) (map[int]*models.Place, error) {
+	found := make(map[int]*models.Place)
+	for _, id := range ids {
+		if p, ok := r.places[id]; ok {
+			found[id] = p
+		}
+	}
+	return found, nil
+}
+
+type memSlackRepo struct {
+	votes  map[string]map[string]bool
+	closed map[string]bool
+}
+
+func (r *memSlackRepo) AddVote(ctx context.Context, drawID string, slackUserID string) error {
+	if r.votes[drawID] == nil {
+		r.votes[drawID] = make(map[string]bool)
+	}
+	r.votes[drawID][slackUserID] = true
+	return nil
+}
+
+func (r *memSlackRepo) CountVotes(ctx context.Context, drawID string) (int, error) {
+	return len(r.votes[drawID]), nil
+}
+
+func (r *memSlackRepo) CloseVote(ctx context.Context, drawID string, slackUserID string) (bool, error) {
+	if r.closed[drawID] {
+		return false, nil
+	}
+	r.closed[drawID] = true
+	return true, nil
+}
+
+func (r *memSlackRepo) IsVoteClosed(ctx context.Context, drawID string) (bool, error) {
+	return r.closed[drawID], nil
+}
+
+type published struct {
+	event string
+	data  interface{}
+}
+
+type recordingPublisher []published
+
+func (p *recordingPublisher) Publish(ctx context.Context, event string, data interface{}) {
+	*p = append(*p, published{event, data})
+}
+
+func TestCloseVote(t *testing.T) {
+	ctx := context.Background()
+	places := memPlaceRepo{places: map[int]*models.Place{7: {ID: 7, Name: "Sushi Tei"}}}
+	draws := memDrawRepo{draws: map[string]*models.Draw{"d1": {ID: "d1", PickedPlaceIDs: []int{7}}}}
+	slackRepo := &memSlackRepo{votes: map[string]map[string]bool{}, closed: map[string]bool{}}
+	var hooks recordingPublisher
+
+	vote(ctx, places, draws, slackRepo, "d1", "U1")
+	vote(ctx, places, draws, slackRepo, "d1", "U2")
+
+	msg := closeVote(ctx, places, draws, slackRepo, &hooks, "d1", "U1")
+	if !msg.ReplaceOriginal || msg.Blocks[len(msg.Blocks)-2].Type != "context" {
+		t.Errorf("closing did not replace the buttons with the result: %+v", msg)
+	}
+
+	if len(hooks) != 1 || hooks[0].event != webhook.EventSessionClosed {
+		t.Fatalf("published %+v, want one %s", hooks, webhook.EventSessionClosed)
+	}
+	got := hooks[0].data.(webhook.SessionClosedEvent)
+	if got.DrawID != "d1" || got.Votes != 2 || got.Place.ID != 7 || got.ClosedBy != "U1" {
+		t.Errorf("session.closed data = %+v", got)
+	}
+
+	closeVote(ctx, places, draws, slackRepo, &hooks, "d1", "U2")
+	if len(hooks) != 1 {
+		t.Errorf("closing twice published %d events, want 1", len(hooks))
+	}
+
+	msg = vote(ctx, places, draws, slackRepo, "d1", "U3")
+	if msg.ResponseType != "ephemeral" {
+		t.Errorf("voting after the close was not refused: %+v", msg)
+	}
+	if n, _ := slackRepo.CountVotes(ctx, "d1"); n != 2 {
+		t.Errorf("votes after the close = %d, want 2", n)
+	}
+}
@@ -0,0 +1,75 @@
+package slack
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+var _ SlackRepository = &SQLSlackRepository{}
+
+type SlackRepository interface {
+	AddVote(ctx context.Context, drawID string, slackUserID string) error
+	CountVotes(ctx context.Context, drawID string) (int, error)
+	CloseVote(ctx context.Context, drawID string, slackUserID string) (bool, error)
+	IsVoteClosed(ctx context.Context, drawID string) (bool, error)
+}
+
+type SQLSlackRepository struct {
+	db *sql.DB
+}
+
+func NewSQLSlackRepository(db *sql.DB) *SQLSlackRepository {
+	return &SQLSlackRepository{db: db}
+}
+
+// AddVote records the user's vote for the draw. Voting again is a no-op.
+func (repo *SQLSlackRepository) AddVote(ctx context.Context, drawID string, slackUserID string) error {
+	stmt := `insert ignore into slack_vote (draw_id, slack_user_id) values (?, ?)`
+	_, err := repo.db.ExecContext(ctx, stmt, drawID, slackUserID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *SQLSlackRepository) CountVotes(ctx context.Context, drawID string) (int, error) {
+	var count int
+	query := `select count(*) from slack_vote v join draw d on d.id = v.draw_id where v.draw_id = ? and d.workspace_id = ?`
+	err := repo.db.QueryRowContext(ctx, query, drawID, workspace.FromContext(ctx)).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CloseVote closes voting on the draw. It reports whether this call closed it,
+// so that a second press of the button does not announce the result again.
+func (repo *SQLSlackRepository) CloseVote(ctx context.Context, drawID string, slackUserID string) (bool, error) {
+	stmt := `insert ignore into slack_vote_session (draw_id, closed_by) values (?, ?)`
+	result, err := repo.db.ExecContext(ctx, stmt, drawID, slackUserID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+func (repo *SQLSlackRepository) IsVoteClosed(ctx context.Context, drawID string) (bool, error) {
+	var closed bool
+	query := `select exists (select 1 from slack_vote_session where draw_id = ?)`
+	err := repo.db.QueryRowContext(ctx, query, drawID).Scan(&closed)
+	if err != nil {
+		return false, err
+	}
+
+	return closed, nil
+}
@@ -0,0 +1,111 @@
+package suggestion
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+// Change is one field that a suggestion would change. Current is nil for
+// fields of a new place.
+type Change struct {
+	Field    string      `json:"field"`
+	Current  interface{} `json:"current"`
+	Proposed interface{} `json:"proposed"`
+}
+
+// editableFields are the place fields a suggestion can change, in the order
+// they are shown to moderators.
+var editableFields = []struct {
+	name  string
+	value func(p *models.Place) interface{}
+}{
+	{"name", func(p *models.Place) interface{} { return p.Name }},
+	{"description", func(p *models.Place) interface{} { return p.Description }},
+	{"category", func(p *models.Place) interface{} { return p.Category }},
+	{"location", func(p *models.Place) interface{} { return p.Location }},
+	{"lat", func(p *models.Place) interface{} { return strings.TrimSpace(p.Lat) }},
+	{"lon", func(p *models.Place) interface{} { return strings.TrimSpace(p.Lon) }},
+	{"area_id", func(p *models.Place) interface{} { return intValue(p.AreaID) }},
+	{"price_level", func(p *models.Place) interface{} { return p.PriceLevel }},
+	{"min_spend", func(p *models.Place) interface{} { return floatValue(p.MinSpend) }},
+	{"max_spend", func(p *models.Place) interface{} { return floatValue(p.MaxSpend) }},
+	{"dietary_tags", func(p *models.Place) interface{} { return tagSlugs(p.DietaryTags) }},
+	{"opening_hours", func(p *models.Place) interface{} { return p.OpeningHours }},
+}
+
+// Diff lists the fields that differ between the current place and the
+// proposed one. With no current place, as for a new place, every field the
+// proposal sets is listed.
+func Diff(current, proposed *models.Place) []Change {
+	changes := []Change{}
+	for _, f := range editableFields {
+		next := f.value(proposed)
+		if current == nil {
+			if !isZero(next) {
+				changes = append(changes, Change{Field: f.name, Proposed: next})
+			}
+			continue
+		}
+
+		prev := f.value(current)
+		if !reflect.DeepEqual(prev, next) {
+			changes = append(changes, Change{Field: f.name, Current: prev, Proposed: next})
+		}
+	}
+	return changes
+}
+
+func isZero(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+		return rv.Len() == 0
+	}
+	return reflect.ValueOf(v).IsZero()
+}
+
+func intValue(p *int) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func floatValue(p *float64) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func tagSlugs(tags []models.DietaryTag) []string {
+	slugs := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		slugs = append(slugs, tag.Slug)
+	}
+	sort.Strings(slugs)
+	return slugs
+}
+
+// applyProposal copies the fields a suggestion can change from proposed onto
+// place.
+func applyProposal(place *models.Place, proposed models.Place) {
+	place.Name = proposed.Name
+	place.Description = proposed.Description
+	place.Category = proposed.Category
+	place.Location = proposed.Location
+	place.Lat = proposed.Lat
+	place.Lon = proposed.Lon
+	place.AreaID = proposed.AreaID
+	place.PriceLevel = proposed.PriceLevel
+	place.MinSpend = proposed.MinSpend
+	place.MaxSpend = proposed.MaxSpend
+	place.DietaryTags = proposed.DietaryTags
+	place.IsHalal = proposed.IsHalal
+	place.IsVegetarian = proposed.IsVegetarian
+	place.OpeningHours = proposed.OpeningHours
+}
@@ -0,0 +1,402 @@
+package suggestion
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/dietary"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/middleware"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/place"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
+)
+
+const maxNoteLength = 2000
+
+var errPlaceGone = errors.New("the place no longer exists")
+
+// SuggestionDto is a user's suggestion. Without a place_id it proposes a new
+// place; with one it proposes the place as the user would have it, in the
+// same shape as /v1/admin/updatePlace.
+type SuggestionDto struct {
+	PlaceID *int           `json:"place_id"`
+	Place   place.PlaceDto `json:"place"`
+	Comment string         `json:"comment"`
+}
+
+// ReviewDto is a moderator's decision. On approval Place, when set, replaces
+// the suggested place, so that moderators can fix a suggestion before
+// applying it.
+type ReviewDto struct {
+	Place *place.PlaceDto `json:"place"`
+	Note  string          `json:"note"`
+}
+
+// SuggestionDetailDto is a suggestion with the place it would change, as it
+// is now, and the fields that would change.
+type SuggestionDetailDto struct {
+	*models.PlaceSuggestion
+	Current *models.Place `json:"current"`
+	Changes []Change      `json:"changes"`
+}
+
+// SubmitSuggestion queues a new place or a correction for moderation.
+func SubmitSuggestion(repo SuggestionRepository, placeRepo place.PlaceRepository, tagRepo dietary.DietaryTagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := middleware.UserFromContext(r.Context())
+
+		var payload SuggestionDto
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		payload.Place.Name = strings.TrimSpace(payload.Place.Name)
+		if payload.Place.Name == "" {
+			utils.ErrorJSON(w, errors.New("name is required"), http.StatusBadRequest)
+			return
+		}
+		if len(payload.Comment) > maxNoteLength {
+			utils.ErrorJSON(w, errors.New("comment is too long"), http.StatusBadRequest)
+			return
+		}
+		err = payload.Place.Validate()
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		s := models.PlaceSuggestion{
+			Kind:      models.SuggestionCreate,
+			UserID:    &user.ID,
+			Status:    models.SuggestionPending,
+			Comment:   payload.Comment,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		s.Proposed.Lat = " "
+		s.Proposed.Lon = " "
+
+		var current *models.Place
+		if payload.PlaceID != nil {
+			current, err = placeRepo.GetPlaceByID(ctx, *payload.PlaceID)
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.ErrorJSON(w, errors.New("place does not exist"), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				utils.ErrorJSON(w, err)
+				return
+			}
+			s.Kind = models.SuggestionUpdate
+			s.PlaceID = &current.ID
+			s.Proposed = *current
+		}
+
+		err = payload.Place.Fill(ctx, tagRepo, &s.Proposed)
+		if errors.Is(err, place.ErrUnknownDietaryTag) {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		if len(Diff(current, &s.Proposed)) == 0 {
+			utils.ErrorJSON(w, errors.New("the suggestion does not change the place"), http.StatusBadRequest)
+			return
+		}
+
+		s.ID, err = repo.InsertSuggestion(ctx, s)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusCreated, s, "suggestion")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// GetMySuggestions lets users follow what became of their suggestions.
+func GetMySuggestions(repo SuggestionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := middleware.UserFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		suggestions, err := repo.GetSuggestionsByUser(ctx, user.ID)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, suggestions, "suggestions")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// GetSuggestionQueue lists suggestions with the status given by the status
+// query parameter, pending by default.
+func GetSuggestionQueue(repo SuggestionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		switch status {
+		case "":
+			status = models.SuggestionPending
+		case models.SuggestionPending, models.SuggestionApproved, models.SuggestionRejected:
+		default:
+			utils.ErrorJSON(w, errors.New("status must be pending, approved or rejected"), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		suggestions, err := repo.GetSuggestions(ctx, status)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, suggestions, "suggestions")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// GetSuggestion shows a suggestion next to the place as it is now.
+func GetSuggestion(repo SuggestionRepository, placeRepo place.PlaceRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		s, ok := getSuggestion(ctx, w, r, repo)
+		if !ok {
+			return
+		}
+
+		detail := SuggestionDetailDto{PlaceSuggestion: s}
+		if s.Kind == models.SuggestionUpdate && s.PlaceID != nil {
+			current, err := placeRepo.GetPlaceByID(ctx, *s.PlaceID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				utils.ErrorJSON(w, err)
+				return
+			}
+			detail.Current = current
+		}
+		detail.Changes = Diff(detail.Current, &s.Proposed)
+
+		err := utils.WriteJSON(w, http.StatusOK, detail, "suggestion")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// ApproveSuggestion applies a suggestion, optionally as edited by the
+// moderator. The place change and the approval are one transaction.
+func ApproveSuggestion(repo SuggestionRepository, placeRepo place.PlaceRepository, tagRepo dietary.DietaryTagRepository, hooks webhook.Publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		moderator, _ := middleware.UserFromContext(r.Context())
+
+		payload, ok := decodeReview(w, r)
+		if !ok {
+			return
+		}
+		if payload.Place != nil {
+			err := payload.Place.Validate()
+			if err != nil {
+				utils.ErrorJSON(w, err, http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		s, ok := getSuggestion(ctx, w, r, repo)
+		if !ok {
+			return
+		}
+		if s.Status != models.SuggestionPending {
+			utils.ErrorJSON(w, ErrNotPending, http.StatusConflict)
+			return
+		}
+
+		now := time.Now()
+		var target models.Place
+		event := webhook.EventPlaceCreated
+		if s.Kind == models.SuggestionUpdate {
+			if s.PlaceID == nil {
+				utils.ErrorJSON(w, errPlaceGone, http.StatusConflict)
+				return
+			}
+			current, err := placeRepo.GetPlaceByID(ctx, *s.PlaceID)
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.ErrorJSON(w, errPlaceGone, http.StatusConflict)
+				return
+			}
+			if err != nil {
+				utils.ErrorJSON(w, err)
+				return
+			}
+			target = *current
+			applyProposal(&target, s.Proposed)
+			event = webhook.EventPlaceUpdated
+		} else {
+			target = s.Proposed
+			target.ID = 0
+			target.CreatedAt = now
+		}
+		target.UpdatedAt = now
+
+		if payload.Place != nil {
+			err := payload.Place.Fill(ctx, tagRepo, &target)
+			if errors.Is(err, place.ErrUnknownDietaryTag) {
+				utils.ErrorJSON(w, err, http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				utils.ErrorJSON(w, err)
+				return
+			}
+		}
+
+		s.ModeratorID = &moderator.ID
+		s.ModeratorNote = payload.Note
+		s.ReviewedAt = &now
+		s.UpdatedAt = now
+		placeID, err := placeRepo.SavePlace(ctx, target, func(tx *sql.Tx, placeID int) error {
+			s.PlaceID = &placeID
+			s.Proposed = target
+			s.Proposed.ID = placeID
+			return repo.ApproveSuggestion(ctx, tx, *s)
+		})
+		if errors.Is(err, ErrNotPending) {
+			utils.ErrorJSON(w, err, http.StatusConflict)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.ErrorJSON(w, errPlaceGone, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+		s.Status = models.SuggestionApproved
+
+		place.PublishPlace(ctx, placeRepo, hooks, event, placeID)
+
+		err = utils.WriteJSON(w, http.StatusOK, s, "suggestion")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// RejectSuggestion closes a suggestion without changing the place. The note
+// tells the submitter why.
+func RejectSuggestion(repo SuggestionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		moderator, _ := middleware.UserFromContext(r.Context())
+
+		payload, ok := decodeReview(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		s, ok := getSuggestion(ctx, w, r, repo)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		s.ModeratorID = &moderator.ID
+		s.ModeratorNote = payload.Note
+		s.ReviewedAt = &now
+		s.UpdatedAt = now
+		err := repo.RejectSuggestion(ctx, *s)
+		if errors.Is(err, ErrNotPending) {
+			utils.ErrorJSON(w, err, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+		s.Status = models.SuggestionRejected
+
+		err = utils.WriteJSON(w, http.StatusOK, s, "suggestion")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// decodeReview reads the optional body of an approval or rejection.
+func decodeReview(w http.ResponseWriter, r *http.Request) (ReviewDto, bool) {
+	var payload ReviewDto
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil && !errors.Is(err, io.EOF) {
+		utils.ErrorJSON(w, err)
+		return payload, false
+	}
+	if len(payload.Note) > maxNoteLength {
+		utils.ErrorJSON(w, errors.New("note is too long"), http.StatusBadRequest)
+		return payload, false
+	}
+	return payload, true
+}
+
+// getSuggestion loads the suggestion in the URL, writing the error response
+// itself when it cannot.
+func getSuggestion(ctx context.Context, w http.ResponseWriter, r *http.Request, repo SuggestionRepository) (*models.PlaceSuggestion, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.ErrorJSON(w, err)
+		return nil, false
+	}
+
+	s, err := repo.GetSuggestionByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.ErrorJSON(w, errors.New("suggestion does not exist"), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		utils.ErrorJSON(w, err)
+		return nil, false
+	}
+
+	return s, true
+}
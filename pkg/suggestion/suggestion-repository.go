@@ -0,0 +1,191 @@
+package suggestion
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+// ErrNotPending is returned when a suggestion that has already been approved
+// or rejected is resolved again.
+var ErrNotPending = errors.New("the suggestion has already been reviewed")
+
+var _ SuggestionRepository = &SQLSuggestionRepository{}
+
+type SuggestionRepository interface {
+	GetSuggestionByID(ctx context.Context, id int) (*models.PlaceSuggestion, error)
+	GetSuggestions(ctx context.Context, status string) ([]*models.PlaceSuggestion, error)
+	GetSuggestionsByUser(ctx context.Context, userID int) ([]*models.PlaceSuggestion, error)
+	InsertSuggestion(ctx context.Context, s models.PlaceSuggestion) (int, error)
+	ApproveSuggestion(ctx context.Context, tx *sql.Tx, s models.PlaceSuggestion) error
+	RejectSuggestion(ctx context.Context, s models.PlaceSuggestion) error
+}
+
+type SQLSuggestionRepository struct {
+	db *sql.DB
+}
+
+func NewSQLSuggestionRepository(db *sql.DB) *SQLSuggestionRepository {
+	return &SQLSuggestionRepository{db: db}
+}
+
+const suggestionColumns = `id, kind, place_id, user_id, status, proposed, coalesce(comment, ''), moderator_id, coalesce(moderator_note, ''), reviewed_at, created_at, updated_at`
+
+func scanSuggestion(row interface{ Scan(...interface{}) error }) (*models.PlaceSuggestion, error) {
+	var s models.PlaceSuggestion
+	var proposed []byte
+	err := row.Scan(
+		&s.ID,
+		&s.Kind,
+		&s.PlaceID,
+		&s.UserID,
+		&s.Status,
+		&proposed,
+		&s.Comment,
+		&s.ModeratorID,
+		&s.ModeratorNote,
+		&s.ReviewedAt,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(proposed, &s.Proposed); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (repo *SQLSuggestionRepository) GetSuggestionByID(ctx context.Context, id int) (*models.PlaceSuggestion, error) {
+	row := repo.db.QueryRowContext(ctx, `select `+suggestionColumns+` from place_suggestion where id = ? and workspace_id = ?`, id, workspace.FromContext(ctx))
+	return scanSuggestion(row)
+}
+
+// GetSuggestions returns the workspace's suggestions with the status, oldest
+// first so that the queue is worked in order.
+func (repo *SQLSuggestionRepository) GetSuggestions(ctx context.Context, status string) ([]*models.PlaceSuggestion, error) {
+	query := `select ` + suggestionColumns + ` from place_suggestion where workspace_id = ? and status = ? order by created_at, id`
+	return repo.querySuggestions(ctx, query, workspace.FromContext(ctx), status)
+}
+
+// GetSuggestionsByUser returns the user's suggestions in the workspace,
+// newest first.
+func (repo *SQLSuggestionRepository) GetSuggestionsByUser(ctx context.Context, userID int) ([]*models.PlaceSuggestion, error) {
+	query := `select ` + suggestionColumns + ` from place_suggestion where workspace_id = ? and user_id = ? order by created_at desc, id desc`
+	return repo.querySuggestions(ctx, query, workspace.FromContext(ctx), userID)
+}
+
+func (repo *SQLSuggestionRepository) querySuggestions(ctx context.Context, query string, args ...interface{}) ([]*models.PlaceSuggestion, error) {
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var suggestions []*models.PlaceSuggestion
+	for rows.Next() {
+		s, err := scanSuggestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
+
+func (repo *SQLSuggestionRepository) InsertSuggestion(ctx context.Context, s models.PlaceSuggestion) (int, error) {
+	proposed, err := json.Marshal(s.Proposed)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `
+		insert into place_suggestion (workspace_id, kind, place_id, user_id, status, proposed, comment, created_at, updated_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := repo.db.ExecContext(ctx, stmt,
+		workspace.FromContext(ctx),
+		s.Kind,
+		s.PlaceID,
+		s.UserID,
+		s.Status,
+		proposed,
+		s.Comment,
+		s.CreatedAt,
+		s.UpdatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// ApproveSuggestion records the approval inside tx, the transaction that
+// applies the place, so that the two commit together. It returns
+// ErrNotPending if the suggestion was reviewed in the meantime, which rolls
+// the place change back.
+func (repo *SQLSuggestionRepository) ApproveSuggestion(ctx context.Context, tx *sql.Tx, s models.PlaceSuggestion) error {
+	s.Status = models.SuggestionApproved
+	return resolve(ctx, tx, s)
+}
+
+func (repo *SQLSuggestionRepository) RejectSuggestion(ctx context.Context, s models.PlaceSuggestion) error {
+	s.Status = models.SuggestionRejected
+	return resolve(ctx, repo.db, s)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// resolve moves a pending suggestion to its final status. The status check is
+// part of the update, so of two moderators resolving the same suggestion only
+// the first succeeds.
+func resolve(ctx context.Context, db execer, s models.PlaceSuggestion) error {
+	proposed, err := json.Marshal(s.Proposed)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+		update place_suggestion
+		set status = ?, place_id = ?, proposed = ?, moderator_id = ?, moderator_note = ?, reviewed_at = ?, updated_at = ?
+		where id = ? and workspace_id = ? and status = ?
+	`
+	result, err := db.ExecContext(ctx, stmt,
+		s.Status,
+		s.PlaceID,
+		proposed,
+		s.ModeratorID,
+		s.ModeratorNote,
+		s.ReviewedAt,
+		s.UpdatedAt,
+		s.ID,
+		workspace.FromContext(ctx),
+		models.SuggestionPending,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotPending
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package trash
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/category"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/location"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/place"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+// purgeEvery is how often the purger looks for expired trash.
+const purgeEvery = time.Hour
+
+// Purger permanently removes places, categories and locations once they have
+// been in the trash for longer than the retention period, in every
+// workspace.
+type Purger struct {
+	workspaceRepo workspace.WorkspaceRepository
+	placeRepo     place.PlaceRepository
+	categoryRepo  category.CategoryRepository
+	locationRepo  location.LocationRepository
+	retention     time.Duration
+	logger        *log.Logger
+}
+
+func NewPurger(workspaceRepo workspace.WorkspaceRepository, placeRepo place.PlaceRepository, categoryRepo category.CategoryRepository, locationRepo location.LocationRepository, retention time.Duration, logger *log.Logger) *Purger {
+	return &Purger{
+		workspaceRepo: workspaceRepo,
+		placeRepo:     placeRepo,
+		categoryRepo:  categoryRepo,
+		locationRepo:  locationRepo,
+		retention:     retention,
+		logger:        logger,
+	}
+}
+
+// Run purges expired trash at start-up and then every hour until ctx is
+// done.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(purgeEvery)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context, now time.Time) {
+	purgeCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	workspaces, err := p.workspaceRepo.GetAllWorkspaces(purgeCtx)
+	if err != nil {
+		p.logger.Println("trash: loading workspaces:", err)
+		return
+	}
+
+	before := now.Add(-p.retention)
+	for _, ws := range workspaces {
+		wsCtx := workspace.NewContext(purgeCtx, ws.ID)
+		purges := []struct {
+			name  string
+			purge func(ctx context.Context, before time.Time) (int, error)
+		}{
+			{"places", p.placeRepo.PurgePlaces},
+			{"categories", p.categoryRepo.PurgeCategories},
+			{"locations", p.locationRepo.PurgeLocations},
+		}
+		for _, purge := range purges {
+			n, err := purge.purge(wsCtx, before)
+			if err != nil {
+				p.logger.Printf("trash: purging %s of workspace %d: %v", purge.name, ws.ID, err)
+				continue
+			}
+			if n > 0 {
+				p.logger.Printf("trash: purged %d %s of workspace %d", n, purge.name, ws.ID)
+			}
+		}
+	}
+}
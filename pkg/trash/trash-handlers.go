@@ -0,0 +1,116 @@
+package trash
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/category"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/location"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/place"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/webhook"
+)
+
+// GetTrash lists the deleted places, categories and locations of the
+// workspace, most recently deleted first. ?type=place, category or location
+// narrows the list to one kind.
+func GetTrash(placeRepo place.PlaceRepository, categoryRepo category.CategoryRepository, locationRepo location.LocationRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entityType := r.URL.Query().Get("type")
+		switch entityType {
+		case "", models.EntityPlace, models.EntityCategory, models.EntityLocation:
+		default:
+			utils.ErrorJSON(w, errors.New("type must be place, category or location"), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		sources := []struct {
+			entityType string
+			list       func(ctx context.Context) ([]*models.TrashItem, error)
+		}{
+			{models.EntityPlace, placeRepo.GetDeletedPlaces},
+			{models.EntityCategory, categoryRepo.GetDeletedCategories},
+			{models.EntityLocation, locationRepo.GetDeletedLocations},
+		}
+
+		items := []*models.TrashItem{}
+		for _, source := range sources {
+			if entityType != "" && entityType != source.entityType {
+				continue
+			}
+
+			found, err := source.list(ctx)
+			if err != nil {
+				utils.ErrorJSON(w, err)
+				return
+			}
+			items = append(items, found...)
+		}
+
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].DeletedAt.After(items[j].DeletedAt)
+		})
+
+		err := utils.WriteJSON(w, http.StatusOK, items, "trash")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// RestoreItem takes the place, category or location named in the URL out of
+// the trash. A restored place is announced to webhooks as created again.
+func RestoreItem(placeRepo place.PlaceRepository, categoryRepo category.CategoryRepository, locationRepo location.LocationRepository, hooks webhook.Publisher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		switch vars["type"] {
+		case models.EntityPlace:
+			err = placeRepo.UndeletePlace(ctx, id)
+		case models.EntityCategory:
+			err = categoryRepo.UndeleteCategory(ctx, id)
+		case models.EntityLocation:
+			err = locationRepo.UndeleteLocation(ctx, id)
+		default:
+			utils.ErrorJSON(w, errors.New("type must be place, category or location"), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.ErrorJSON(w, errors.New("the item is not in the trash"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		if vars["type"] == models.EntityPlace {
+			place.PublishPlace(ctx, placeRepo, hooks, webhook.EventPlaceCreated, id)
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, nil, "response")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
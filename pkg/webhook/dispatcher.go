@@ -0,0 +1,358 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+// Events that subscriptions can ask for.
+const (
+	EventPlaceCreated  = "place.created"
+	EventPlaceUpdated  = "place.updated"
+	EventPlaceDeleted  = "place.deleted"
+	EventPickGenerated = "pick.generated"
+	EventSessionClosed = "session.closed"
+)
+
+var Events = []string{
+	EventPlaceCreated,
+	EventPlaceUpdated,
+	EventPlaceDeleted,
+	EventPickGenerated,
+	EventSessionClosed,
+}
+
+// Headers sent with every delivery. The signature header has the form
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed with the
+// subscription secret>"; receivers should recompute it and reject stale
+// timestamps.
+const (
+	HeaderEvent     = "X-Makan-Event"
+	HeaderDelivery  = "X-Makan-Delivery"
+	HeaderSignature = "X-Makan-Signature"
+)
+
+const (
+	// maxAttempts is how many times a delivery is tried before it is marked
+	// failed. With retryBase doubling this spans about an hour.
+	maxAttempts = 8
+	retryBase   = 30 * time.Second
+	pollEvery   = 15 * time.Second
+	batchSize   = 50
+	// maxErrorLength keeps logged errors within the last_error column.
+	maxErrorLength = 1024
+)
+
+// Publisher is what the rest of the application uses to emit events.
+type Publisher interface {
+	Publish(ctx context.Context, event string, data interface{})
+}
+
+// Envelope is the JSON body of every delivery. ID identifies the event, so it
+// is the same across retries and replays and receivers can use it to ignore
+// duplicates.
+type Envelope struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// PickEvent is the data of a pick.generated event.
+type PickEvent struct {
+	DrawID     string          `json:"draw_id"`
+	Seed       int64           `json:"seed"`
+	Source     string          `json:"source"`
+	ScheduleID *int            `json:"schedule_id,omitempty"`
+	Places     []*models.Place `json:"places"`
+}
+
+// SessionClosedEvent is the data of a session.closed event, sent when voting
+// on a draw's Slack message is closed. ClosedBy is the Slack user ID of whoever
+// closed it.
+type SessionClosedEvent struct {
+	DrawID   string        `json:"draw_id"`
+	Source   string        `json:"source"`
+	Votes    int           `json:"votes"`
+	Place    *models.Place `json:"place"`
+	ClosedBy string        `json:"closed_by"`
+}
+
+// PlaceDeletedEvent is the data of a place.deleted event.
+type PlaceDeletedEvent struct {
+	ID int `json:"id"`
+}
+
+// Dispatcher stores an outgoing delivery per subscriber when an event is
+// published and sends pending deliveries in the background, retrying failures
+// with exponential backoff.
+type Dispatcher struct {
+	repo   WebhookRepository
+	client *http.Client
+	logger *log.Logger
+	wake   chan struct{}
+}
+
+func NewDispatcher(repo WebhookRepository, logger *log.Logger) *Dispatcher {
+	return &Dispatcher{
+		repo:   repo,
+		client: newDeliveryClient(),
+		logger: logger,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Publish queues the event for every enabled subscription that wants it.
+// Failures are logged rather than returned so that a webhook problem never
+// fails the change that caused the event.
+func (d *Dispatcher) Publish(ctx context.Context, event string, data interface{}) {
+	subs, err := d.repo.GetAllSubscriptions(ctx)
+	if err != nil {
+		d.logger.Printf("webhook: publishing %s: %v", event, err)
+		return
+	}
+
+	var payload []byte
+	queued := false
+	for _, sub := range subs {
+		if !sub.Enabled || !subscribes(sub, event) {
+			continue
+		}
+
+		if payload == nil {
+			payload, err = newPayload(event, data)
+			if err != nil {
+				d.logger.Printf("webhook: publishing %s: %v", event, err)
+				return
+			}
+		}
+
+		now := time.Now()
+		_, err = d.repo.InsertDelivery(ctx, models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			Event:          event,
+			Payload:        payload,
+			Status:         models.DeliveryPending,
+			NextAttemptAt:  &now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+		if err != nil {
+			d.logger.Printf("webhook: queueing %s for subscription %d: %v", event, sub.ID, err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		d.notify()
+	}
+}
+
+// Replay queues a new delivery with the same payload as an earlier one.
+func (d *Dispatcher) Replay(ctx context.Context, deliveryID int) (*models.WebhookDelivery, error) {
+	original, err := d.repo.GetDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	replay := models.WebhookDelivery{
+		SubscriptionID: original.SubscriptionID,
+		Event:          original.Event,
+		Payload:        original.Payload,
+		Status:         models.DeliveryPending,
+		NextAttemptAt:  &now,
+		ReplayOf:       &original.ID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	replay.ID, err = d.repo.InsertDelivery(ctx, replay)
+	if err != nil {
+		return nil, err
+	}
+
+	d.notify()
+	return &replay, nil
+}
+
+// Run sends due deliveries whenever an event is queued and at least every
+// pollEvery, until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+		d.deliverDue(ctx)
+	}
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	due, err := d.repo.GetDueDeliveries(ctx, time.Now(), batchSize)
+	if err != nil {
+		d.logger.Println("webhook: loading deliveries:", err)
+		return
+	}
+
+	subs := make(map[int]*models.WebhookSubscription)
+	for _, delivery := range due {
+		sub, ok := subs[delivery.SubscriptionID]
+		if !ok {
+			sub, err = d.repo.GetSubscriptionByID(workspace.NewContext(ctx, delivery.WorkspaceID), delivery.SubscriptionID)
+			if err != nil {
+				d.logger.Printf("webhook: delivery %d: %v", delivery.ID, err)
+				continue
+			}
+			subs[sub.ID] = sub
+		}
+
+		d.attempt(ctx, sub, delivery)
+
+		err = d.repo.UpdateDelivery(ctx, *delivery)
+		if err != nil {
+			d.logger.Printf("webhook: delivery %d: %v", delivery.ID, err)
+		}
+	}
+}
+
+// attempt sends the delivery once and updates its status, attempt count and
+// next attempt time in place.
+func (d *Dispatcher) attempt(ctx context.Context, sub *models.WebhookSubscription, delivery *models.WebhookDelivery) {
+	now := time.Now()
+	delivery.UpdatedAt = now
+
+	if !sub.Enabled {
+		delivery.Status = models.DeliveryFailed
+		delivery.NextAttemptAt = nil
+		delivery.LastError = "subscription is disabled"
+		return
+	}
+
+	delivery.Attempts++
+	statusCode, err := d.send(ctx, sub, delivery, now)
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+	if err == nil {
+		delivery.Status = models.DeliverySucceeded
+		delivery.NextAttemptAt = nil
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = truncate(err.Error(), maxErrorLength)
+	if delivery.Attempts >= maxAttempts {
+		delivery.Status = models.DeliveryFailed
+		delivery.NextAttemptAt = nil
+		return
+	}
+
+	next := now.Add(backoff(delivery.Attempts))
+	delivery.NextAttemptAt = &next
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub *models.WebhookSubscription, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "time-to-makan-webhooks")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, now, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff is the wait after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	return retryBase << uint(attempts-1)
+}
+
+func subscribes(sub *models.WebhookSubscription, event string) bool {
+	for _, e := range sub.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func newPayload(event string, data interface{}) ([]byte, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Envelope{
+		ID:        id,
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,170 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+func TestSign(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"place.created"}`)
+
+	tests := []struct {
+		name   string
+		secret string
+		t      time.Time
+		body   []byte
+		want   string
+	}{
+		{
+			name:   "known vector",
+			secret: "whsec_test",
+			t:      at,
+			body:   body,
+			want:   "t=1700000000,v1=e0570e6d4639387a19f068e64067f81397e1662cef7ff564b2890ee2d23f87b8",
+		},
+		{
+			name:   "other secret",
+			secret: "whsec_other",
+			t:      at,
+			body:   body,
+		},
+		{
+			name:   "other time",
+			secret: "whsec_test",
+			t:      at.Add(time.Second),
+			body:   body,
+		},
+		{
+			name:   "other body",
+			secret: "whsec_test",
+			t:      at,
+			body:   []byte(`{"event":"place.deleted"}`),
+		},
+	}
+
+	known := Sign("whsec_test", at, body)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sign(tt.secret, tt.t, tt.body)
+			if tt.want != "" {
+				if got != tt.want {
+					t.Errorf("Sign = %q, want %q", got, tt.want)
+				}
+				return
+			}
+			if got == known {
+				t.Errorf("Sign = %q, the same as for the known vector", got)
+			}
+			if !verify(tt.secret, got, tt.body) {
+				t.Errorf("signature %q does not verify", got)
+			}
+		})
+	}
+}
+
+// verify checks a signature header the way the README asks receivers to.
+func verify(secret, header string, body []byte) bool {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return false
+		}
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "v1":
+			sig = kv[1]
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	want := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(sig), []byte(want))
+}
+
+func TestSendSignsTheDelivery(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"id":"abc","event":"place.created","data":{"id":1}}`)
+	now := time.Unix(1700000000, 0)
+
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(nil, log.New(io.Discard, "", 0))
+	// The test server listens on loopback, which the delivery client refuses.
+	d.client = srv.Client()
+	sub := &models.WebhookSubscription{URL: srv.URL, Secret: secret}
+	delivery := &models.WebhookDelivery{ID: 42, Event: EventPlaceCreated, Payload: body}
+
+	status, err := d.send(context.Background(), sub, delivery, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", status, http.StatusNoContent)
+	}
+
+	if string(gotBody) != string(body) {
+		t.Errorf("body = %s, want %s", gotBody, body)
+	}
+	if e := got.Header.Get(HeaderEvent); e != EventPlaceCreated {
+		t.Errorf("%s = %q, want %q", HeaderEvent, e, EventPlaceCreated)
+	}
+	if id := got.Header.Get(HeaderDelivery); id != strconv.Itoa(delivery.ID) {
+		t.Errorf("%s = %q, want %q", HeaderDelivery, id, "42")
+	}
+
+	signature := got.Header.Get(HeaderSignature)
+	if signature != Sign(secret, now, body) {
+		t.Errorf("%s = %q, want %q", HeaderSignature, signature, Sign(secret, now, body))
+	}
+	if !verify(secret, signature, gotBody) {
+		t.Errorf("signature %q does not verify against the received body", signature)
+	}
+	if verify("whsec_wrong", signature, gotBody) {
+		t.Errorf("signature %q verifies with the wrong secret", signature)
+	}
+}
+
+func TestSendReportsFailingEndpoints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(nil, log.New(io.Discard, "", 0))
+	// The test server listens on loopback, which the delivery client refuses.
+	d.client = srv.Client()
+	sub := &models.WebhookSubscription{URL: srv.URL, Secret: "whsec_test"}
+	delivery := &models.WebhookDelivery{ID: 1, Event: EventPlaceDeleted, Payload: []byte(`{}`)}
+
+	status, err := d.send(context.Background(), sub, delivery, time.Now())
+	if err == nil {
+		t.Error("send succeeded against a failing endpoint")
+	}
+	if status != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", status, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+func TestValidateRejectsPrivateTargets(t *testing.T) {
+	tests := []struct {
+		url     string
+		private bool
+	}{
+		{"https://93.184.216.34/hook", false},
+		{"https://[2606:2800:220:1:248:1893:25c8:1946]/hook", false},
+		{"http://localhost:8080/hook", true},
+		{"http://api.LOCALHOST./hook", true},
+		{"http://127.0.0.1/hook", true},
+		{"http://127.1.2.3:9000/hook", true},
+		{"http://[::1]/hook", true},
+		{"http://0.0.0.0/hook", true},
+		{"http://10.0.0.5/hook", true},
+		{"http://172.16.3.4/hook", true},
+		{"http://192.168.1.1/hook", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"http://100.64.0.1/hook", true},
+		{"http://[fe80::1]/hook", true},
+		{"http://[fd00::1]/hook", true},
+		{"http://[::ffff:127.0.0.1]/hook", true},
+		{"http://[64:ff9b::a00:1]/hook", true},
+	}
+
+	for _, tt := range tests {
+		dto := WebhookDto{URL: tt.url, Events: []string{EventPlaceCreated}}
+		err := dto.validate(context.Background())
+		if tt.private && !errors.Is(err, errPrivateTarget) {
+			t.Errorf("validate(%s) = %v, want %v", tt.url, err, errPrivateTarget)
+		}
+		if !tt.private && err != nil {
+			t.Errorf("validate(%s) = %v, want nil", tt.url, err)
+		}
+	}
+}
+
+func TestDeliveryClientRefusesPrivateAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(nil, log.New(io.Discard, "", 0))
+	sub := &models.WebhookSubscription{URL: srv.URL, Secret: "whsec_test"}
+	delivery := &models.WebhookDelivery{ID: 1, Event: EventPlaceCreated, Payload: []byte(`{}`)}
+
+	_, err := d.send(context.Background(), sub, delivery, time.Now())
+	if !errors.Is(err, errPrivateTarget) {
+		t.Errorf("send to %s = %v, want %v", srv.URL, err, errPrivateTarget)
+	}
+	if called {
+		t.Error("the loopback endpoint was called")
+	}
+}
@@ -0,0 +1,252 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/workspace"
+)
+
+var _ WebhookRepository = &SQLWebhookRepository{}
+
+type WebhookRepository interface {
+	GetSubscriptionByID(ctx context.Context, id int) (*models.WebhookSubscription, error)
+	GetAllSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error)
+	InsertSubscription(ctx context.Context, sub models.WebhookSubscription) error
+	UpdateSubscription(ctx context.Context, sub models.WebhookSubscription) error
+	DeleteSubscription(ctx context.Context, id int) error
+	GetDeliveryByID(ctx context.Context, id int) (*models.WebhookDelivery, error)
+	GetDeliveries(ctx context.Context, subscriptionID int, limit int) ([]*models.WebhookDelivery, error)
+	GetDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error)
+	InsertDelivery(ctx context.Context, delivery models.WebhookDelivery) (int, error)
+	UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+}
+
+type SQLWebhookRepository struct {
+	db *sql.DB
+}
+
+func NewSQLWebhookRepository(db *sql.DB) *SQLWebhookRepository {
+	return &SQLWebhookRepository{db: db}
+}
+
+const subscriptionColumns = `id, url, secret, events, enabled, created_at, updated_at`
+
+func scanSubscription(row interface{ Scan(...interface{}) error }) (*models.WebhookSubscription, error) {
+	var s models.WebhookSubscription
+	var events string
+	err := row.Scan(
+		&s.ID,
+		&s.URL,
+		&s.Secret,
+		&events,
+		&s.Enabled,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Events = strings.Split(events, ",")
+	return &s, nil
+}
+
+func (repo *SQLWebhookRepository) GetSubscriptionByID(ctx context.Context, id int) (*models.WebhookSubscription, error) {
+	row := repo.db.QueryRowContext(ctx, `select `+subscriptionColumns+` from webhook_subscription where id = ? and workspace_id = ?`, id, workspace.FromContext(ctx))
+	return scanSubscription(row)
+}
+
+func (repo *SQLWebhookRepository) GetAllSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	rows, err := repo.db.QueryContext(ctx, `select `+subscriptionColumns+` from webhook_subscription where workspace_id = ? order by id`, workspace.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var subs []*models.WebhookSubscription
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+func (repo *SQLWebhookRepository) InsertSubscription(ctx context.Context, s models.WebhookSubscription) error {
+	stmt := `
+		insert into webhook_subscription (url, secret, events, enabled, created_at, updated_at, workspace_id)
+		values (?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := repo.db.ExecContext(ctx, stmt,
+		s.URL,
+		s.Secret,
+		strings.Join(s.Events, ","),
+		s.Enabled,
+		s.CreatedAt,
+		s.UpdatedAt,
+		workspace.FromContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *SQLWebhookRepository) UpdateSubscription(ctx context.Context, s models.WebhookSubscription) error {
+	stmt := `
+		update webhook_subscription set url = ?, secret = ?, events = ?, enabled = ?, updated_at = ?
+		where id = ? and workspace_id = ?
+	`
+	_, err := repo.db.ExecContext(ctx, stmt,
+		s.URL,
+		s.Secret,
+		strings.Join(s.Events, ","),
+		s.Enabled,
+		s.UpdatedAt,
+		s.ID,
+		workspace.FromContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *SQLWebhookRepository) DeleteSubscription(ctx context.Context, id int) error {
+	_, err := repo.db.ExecContext(ctx, `delete from webhook_subscription where id = ? and workspace_id = ?`, id, workspace.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const deliveryColumns = `id, subscription_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, replay_of, workspace_id, created_at, updated_at`
+
+func scanDelivery(row interface{ Scan(...interface{}) error }) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload []byte
+	err := row.Scan(
+		&d.ID,
+		&d.SubscriptionID,
+		&d.Event,
+		&payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastStatusCode,
+		&d.LastError,
+		&d.ReplayOf,
+		&d.WorkspaceID,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Payload = payload
+	return &d, nil
+}
+
+func (repo *SQLWebhookRepository) queryDeliveries(ctx context.Context, query string, args ...interface{}) ([]*models.WebhookDelivery, error) {
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (repo *SQLWebhookRepository) GetDeliveryByID(ctx context.Context, id int) (*models.WebhookDelivery, error) {
+	row := repo.db.QueryRowContext(ctx, `select `+deliveryColumns+` from webhook_delivery where id = ? and workspace_id = ?`, id, workspace.FromContext(ctx))
+	return scanDelivery(row)
+}
+
+// GetDeliveries returns the subscription's most recent deliveries, newest first.
+func (repo *SQLWebhookRepository) GetDeliveries(ctx context.Context, subscriptionID int, limit int) ([]*models.WebhookDelivery, error) {
+	query := `select ` + deliveryColumns + ` from webhook_delivery where subscription_id = ? and workspace_id = ? order by id desc limit ?`
+	return repo.queryDeliveries(ctx, query, subscriptionID, workspace.FromContext(ctx), limit)
+}
+
+// GetDueDeliveries returns pending deliveries whose next attempt is at or
+// before now, oldest first, across every workspace.
+func (repo *SQLWebhookRepository) GetDueDeliveries(ctx context.Context, now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+		select ` + deliveryColumns + ` from webhook_delivery
+		where status = ? and next_attempt_at <= ?
+		order by next_attempt_at, id limit ?
+	`
+	return repo.queryDeliveries(ctx, query, models.DeliveryPending, now, limit)
+}
+
+func (repo *SQLWebhookRepository) InsertDelivery(ctx context.Context, d models.WebhookDelivery) (int, error) {
+	stmt := `
+		insert into webhook_delivery (subscription_id, event, payload, status, attempts, next_attempt_at, last_error, replay_of, workspace_id, created_at, updated_at)
+		values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := repo.db.ExecContext(ctx, stmt,
+		d.SubscriptionID,
+		d.Event,
+		[]byte(d.Payload),
+		d.Status,
+		d.Attempts,
+		d.NextAttemptAt,
+		d.LastError,
+		d.ReplayOf,
+		workspace.FromContext(ctx),
+		d.CreatedAt,
+		d.UpdatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// UpdateDelivery records the outcome of a delivery attempt.
+func (repo *SQLWebhookRepository) UpdateDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	stmt := `
+		update webhook_delivery set status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, updated_at = ?
+		where id = ?
+	`
+	_, err := repo.db.ExecContext(ctx, stmt,
+		d.Status,
+		d.Attempts,
+		d.NextAttemptAt,
+		d.LastStatusCode,
+		d.LastError,
+		d.UpdatedAt,
+		d.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/middleware"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+type contextKey struct{}
+
+// NewContext returns a context whose repository calls are limited to the
+// workspace.
+func NewContext(ctx context.Context, workspaceID int) context.Context {
+	return context.WithValue(ctx, contextKey{}, workspaceID)
+}
+
+// FromContext returns the workspace that repository calls made with ctx are
+// limited to. Contexts without one use the default workspace.
+func FromContext(ctx context.Context) int {
+	if id, ok := ctx.Value(contextKey{}).(int); ok && id != 0 {
+		return id
+	}
+	return models.DefaultWorkspaceID
+}
+
+// Scope puts the caller's active workspace, as carried by their token, in
+// the request context. Anonymous callers and tokens issued before
+// workspaces get the default workspace.
+func Scope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		workspaceID := models.DefaultWorkspaceID
+		if td, ok := middleware.UserFromContext(r.Context()); ok && td.WorkspaceID != 0 {
+			workspaceID = td.WorkspaceID
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), workspaceID)))
+	})
+}
+
+// FromQuery scopes a request to the workspace named by the workspace query
+// parameter, given as an ID or slug. It is for integration endpoints, whose
+// callers are verified by signature rather than a user token, so each team
+// points its integration at its own workspace's URL.
+func FromQuery(repo WorkspaceRepository, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("workspace")
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		var ws *models.Workspace
+		var err error
+		if id, convErr := strconv.Atoi(key); convErr == nil {
+			ws, err = repo.GetWorkspaceByID(r.Context(), id)
+		} else {
+			ws, err = repo.GetWorkspaceBySlug(r.Context(), key)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.ErrorJSON(w, errors.New("workspace does not exist"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		next(w, r.WithContext(NewContext(r.Context(), ws.ID)))
+	}
+}
@@ -0,0 +1,376 @@
+package workspace
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/auth"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/middleware"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+	"github.com/ngfenglong/food-randomizer-BE/pkg/utils"
+)
+
+const (
+	inviteTTL         = 7 * 24 * time.Hour
+	maxSlugLength     = 64
+	errDuplicateEntry = 1062
+)
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+type WorkspaceDto struct {
+	Name string `json:"name"`
+}
+
+// MembershipDto is one of the caller's workspaces. Active marks the one the
+// caller's token is working in.
+type MembershipDto struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Slug   string `json:"slug"`
+	Role   int    `json:"role"`
+	Active bool   `json:"active"`
+}
+
+type InviteDto struct {
+	Role int `json:"role"`
+}
+
+type JoinDto struct {
+	Code string `json:"code"`
+}
+
+type RoleDto struct {
+	Role int `json:"role"`
+}
+
+// slugify turns a workspace name into the slug used in integration URLs.
+func slugify(name string) string {
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	return slug
+}
+
+// GetMyWorkspaces lists the workspaces the caller belongs to.
+func GetMyWorkspaces(repo WorkspaceRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		td, _ := middleware.UserFromContext(r.Context())
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		memberships, err := repo.GetMemberships(ctx, td.ID)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		active := FromContext(r.Context())
+		workspaces := make([]MembershipDto, 0, len(memberships))
+		for _, m := range memberships {
+			workspaces = append(workspaces, MembershipDto{
+				ID:     m.Workspace.ID,
+				Name:   m.Workspace.Name,
+				Slug:   m.Workspace.Slug,
+				Role:   m.Role,
+				Active: m.Workspace.ID == active,
+			})
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, workspaces, "workspaces")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// CreateWorkspace creates a workspace with the caller as its admin. The
+// caller switches to it with SwitchWorkspace.
+func CreateWorkspace(repo WorkspaceRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		td, _ := middleware.UserFromContext(r.Context())
+
+		var payload WorkspaceDto
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		name := strings.TrimSpace(payload.Name)
+		slug := slugify(name)
+		if slug == "" {
+			utils.ErrorJSON(w, errors.New("name must contain a letter or digit"), http.StatusBadRequest)
+			return
+		}
+		if _, err := strconv.Atoi(slug); err == nil {
+			utils.ErrorJSON(w, errors.New("name must not be a number"), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		ws := models.Workspace{Name: name, Slug: slug, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+		ws.ID, err = repo.InsertWorkspace(ctx, ws, td.ID)
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry {
+			utils.ErrorJSON(w, errors.New("a workspace with this name already exists"), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusCreated, ws, "workspace")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// SwitchWorkspace makes another of the caller's workspaces active. It
+// returns new tokens carrying the workspace and the caller's role in it, in
+// the same shape as a login.
+func SwitchWorkspace(repo WorkspaceRepository, authRepo auth.AuthRepository, signer *auth.Signer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		td, _ := middleware.UserFromContext(r.Context())
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		member, err := repo.GetMember(ctx, id, td.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.ErrorJSON(w, errors.New("you are not a member of this workspace"), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = repo.SetActiveWorkspace(ctx, td.ID, member.WorkspaceID)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		switched := *td
+		switched.WorkspaceID = member.WorkspaceID
+		switched.Role = member.Role
+		payload, err := auth.IssueTokens(ctx, authRepo, signer, &switched)
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusOK, payload, "data")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// CreateInvite creates a single-use invite to the caller's active workspace.
+// Admins cannot invite anyone with a role above their own.
+func CreateInvite(repo WorkspaceRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		td, _ := middleware.UserFromContext(r.Context())
+
+		var payload InviteDto
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		if payload.Role < models.RoleUser || payload.Role > td.Role {
+			utils.ErrorJSON(w, errors.New("role must be between 0 and your own role"), http.StatusBadRequest)
+			return
+		}
+
+		code, err := newInviteCode()
+		if err != nil {
+			utils.ErrorJSON(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		now := time.Now()
+		invite := models.WorkspaceInvite{
+			Code:        code,
+			WorkspaceID: FromContext(ctx),
+			Role:        payload.Role,
+			CreatedBy:   &td.ID,
+			ExpiresAt:   now.Add(inviteTTL),
+			CreatedAt:   now,
+		}
+		err = repo.InsertInvite(ctx, invite)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		err = utils.WriteJSON(w, http.StatusCreated, invite, "invite")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// JoinWorkspace redeems an invite code for the caller. The new membership is
+// recorded in the audit log of the workspace joined.
+func JoinWorkspace(repo WorkspaceRepository, recorder auth.AuditRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		td, _ := middleware.UserFromContext(r.Context())
+
+		var payload JoinDto
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		invite, err := repo.AcceptInvite(ctx, strings.TrimSpace(payload.Code), td.ID, time.Now())
+		if errors.Is(err, ErrInviteInvalid) {
+			utils.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, ErrAlreadyMember) {
+			utils.ErrorJSON(w, err, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		recorder.Record(r, models.AuditEntry{
+			WorkspaceID: invite.WorkspaceID,
+			Action:      models.AuditJoin,
+			TargetType:  "user",
+			TargetID:    strconv.Itoa(td.ID),
+			Status:      http.StatusOK,
+			Summary:     fmt.Sprintf("joined with role %d", invite.Role),
+		})
+
+		ws, err := repo.GetWorkspaceByID(ctx, invite.WorkspaceID)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		membership := MembershipDto{ID: ws.ID, Name: ws.Name, Slug: ws.Slug, Role: invite.Role}
+		err = utils.WriteJSON(w, http.StatusOK, membership, "workspace")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+// UpdateMemberRole changes the role of the member in the URL in the caller's
+// active workspace. Callers can only change members ranked below them, such as
+// an admin changing a moderator but not another admin, cannot raise anyone
+// above their own role, and cannot change their own. The new role applies to
+// tokens issued from then on.
+func UpdateMemberRole(repo WorkspaceRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		td, _ := middleware.UserFromContext(r.Context())
+
+		userID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		var payload RoleDto
+		err = json.NewDecoder(r.Body).Decode(&payload)
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		if payload.Role < models.RoleUser || payload.Role > td.Role {
+			utils.ErrorJSON(w, errors.New("role must be between 0 and your own role"), http.StatusBadRequest)
+			return
+		}
+		if userID == td.ID {
+			utils.ErrorJSON(w, errors.New("you cannot change your own role"), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		workspaceID := FromContext(ctx)
+		current, err := repo.GetMember(ctx, workspaceID, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.ErrorJSON(w, errors.New("the user is not a member of this workspace"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+		if current.Role >= td.Role {
+			utils.ErrorJSON(w, errors.New("you can only change the role of members below your own role"), http.StatusForbidden)
+			return
+		}
+
+		err = repo.SetMemberRole(ctx, workspaceID, userID, payload.Role)
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.ErrorJSON(w, errors.New("the user is not a member of this workspace"), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+
+		member := models.WorkspaceMember{WorkspaceID: workspaceID, UserID: userID, Role: payload.Role}
+		err = utils.WriteJSON(w, http.StatusOK, member, "member")
+		if err != nil {
+			utils.ErrorJSON(w, err)
+			return
+		}
+	}
+}
+
+func newInviteCode() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
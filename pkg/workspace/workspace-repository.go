@@ -0,0 +1,288 @@
+package workspace
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ngfenglong/food-randomizer-BE/pkg/models"
+)
+
+var (
+	// ErrInviteInvalid is returned for invite codes that do not exist, have
+	// expired or have already been used.
+	ErrInviteInvalid = errors.New("the invite is invalid or has expired")
+	// ErrAlreadyMember is returned when an invite is redeemed by a member.
+	ErrAlreadyMember = errors.New("you are already a member of this workspace")
+)
+
+// Membership is a workspace together with the user's role in it.
+type Membership struct {
+	Workspace models.Workspace
+	Role      int
+}
+
+var _ WorkspaceRepository = &SQLWorkspaceRepository{}
+
+type WorkspaceRepository interface {
+	GetWorkspaceByID(ctx context.Context, id int) (*models.Workspace, error)
+	GetWorkspaceBySlug(ctx context.Context, slug string) (*models.Workspace, error)
+	GetAllWorkspaces(ctx context.Context) ([]*models.Workspace, error)
+	GetMemberships(ctx context.Context, userID int) ([]*Membership, error)
+	GetMember(ctx context.Context, workspaceID, userID int) (*models.WorkspaceMember, error)
+	SetMemberRole(ctx context.Context, workspaceID, userID, role int) error
+	InsertWorkspace(ctx context.Context, ws models.Workspace, ownerID int) (int, error)
+	SetActiveWorkspace(ctx context.Context, userID, workspaceID int) error
+	InsertInvite(ctx context.Context, invite models.WorkspaceInvite) error
+	AcceptInvite(ctx context.Context, code string, userID int, now time.Time) (*models.WorkspaceInvite, error)
+}
+
+type SQLWorkspaceRepository struct {
+	db *sql.DB
+}
+
+func NewSQLWorkspaceRepository(db *sql.DB) *SQLWorkspaceRepository {
+	return &SQLWorkspaceRepository{db: db}
+}
+
+const workspaceColumns = `id, name, slug, created_at, updated_at`
+
+func scanWorkspace(row interface{ Scan(...interface{}) error }) (*models.Workspace, error) {
+	var ws models.Workspace
+	err := row.Scan(
+		&ws.ID,
+		&ws.Name,
+		&ws.Slug,
+		&ws.CreatedAt,
+		&ws.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+func (repo *SQLWorkspaceRepository) GetWorkspaceByID(ctx context.Context, id int) (*models.Workspace, error) {
+	row := repo.db.QueryRowContext(ctx, `select `+workspaceColumns+` from workspace where id = ?`, id)
+	return scanWorkspace(row)
+}
+
+func (repo *SQLWorkspaceRepository) GetWorkspaceBySlug(ctx context.Context, slug string) (*models.Workspace, error) {
+	row := repo.db.QueryRowContext(ctx, `select `+workspaceColumns+` from workspace where slug = ?`, slug)
+	return scanWorkspace(row)
+}
+
+func (repo *SQLWorkspaceRepository) GetAllWorkspaces(ctx context.Context) ([]*models.Workspace, error) {
+	rows, err := repo.db.QueryContext(ctx, `select `+workspaceColumns+` from workspace order by id`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var workspaces []*models.Workspace
+	for rows.Next() {
+		ws, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, ws)
+	}
+	return workspaces, rows.Err()
+}
+
+func (repo *SQLWorkspaceRepository) GetMemberships(ctx context.Context, userID int) ([]*Membership, error) {
+	query := `
+		select w.id, w.name, w.slug, w.created_at, w.updated_at, m.role
+		from workspace_member m
+		join workspace w on w.id = m.workspace_id
+		where m.user_id = ?
+		order by w.name
+	`
+	rows, err := repo.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var memberships []*Membership
+	for rows.Next() {
+		var m Membership
+		err := rows.Scan(
+			&m.Workspace.ID,
+			&m.Workspace.Name,
+			&m.Workspace.Slug,
+			&m.Workspace.CreatedAt,
+			&m.Workspace.UpdatedAt,
+			&m.Role,
+		)
+		if err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, &m)
+	}
+	return memberships, rows.Err()
+}
+
+func (repo *SQLWorkspaceRepository) GetMember(ctx context.Context, workspaceID, userID int) (*models.WorkspaceMember, error) {
+	query := `select workspace_id, user_id, role, created_at from workspace_member where workspace_id = ? and user_id = ?`
+
+	var m models.WorkspaceMember
+	err := repo.db.QueryRowContext(ctx, query, workspaceID, userID).Scan(
+		&m.WorkspaceID,
+		&m.UserID,
+		&m.Role,
+		&m.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// SetMemberRole changes a member's role in the workspace. It returns
+// sql.ErrNoRows if the user is not a member.
+func (repo *SQLWorkspaceRepository) SetMemberRole(ctx context.Context, workspaceID, userID, role int) error {
+	_, err := repo.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.db.ExecContext(ctx, `update workspace_member set role = ? where workspace_id = ? and user_id = ?`, role, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InsertWorkspace creates the workspace with ownerID as its first admin.
+func (repo *SQLWorkspaceRepository) InsertWorkspace(ctx context.Context, ws models.Workspace, ownerID int) (int, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`insert into workspace (name, slug, created_at, updated_at) values (?, ?, ?, ?)`,
+		ws.Name,
+		ws.Slug,
+		ws.CreatedAt,
+		ws.UpdatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`insert into workspace_member (workspace_id, user_id, role, created_at) values (?, ?, ?, ?)`,
+		id,
+		ownerID,
+		models.RoleAdmin,
+		ws.CreatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+func (repo *SQLWorkspaceRepository) SetActiveWorkspace(ctx context.Context, userID, workspaceID int) error {
+	_, err := repo.db.ExecContext(ctx, `update user set workspace_id = ? where id = ?`, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repo *SQLWorkspaceRepository) InsertInvite(ctx context.Context, invite models.WorkspaceInvite) error {
+	stmt := `
+		insert into workspace_invite (code, workspace_id, role, created_by, expires_at, created_at)
+		values (?, ?, ?, ?, ?, ?)
+	`
+	_, err := repo.db.ExecContext(ctx, stmt,
+		invite.Code,
+		invite.WorkspaceID,
+		invite.Role,
+		invite.CreatedBy,
+		invite.ExpiresAt,
+		invite.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AcceptInvite redeems an invite code, adding the user to its workspace with
+// the invite's role. The invite is locked while it is checked so that it
+// cannot be redeemed twice.
+func (repo *SQLWorkspaceRepository) AcceptInvite(ctx context.Context, code string, userID int, now time.Time) (*models.WorkspaceInvite, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		select code, workspace_id, role, created_by, expires_at, accepted_by, accepted_at, created_at
+		from workspace_invite where code = ? for update
+	`
+	var invite models.WorkspaceInvite
+	err = tx.QueryRowContext(ctx, query, code).Scan(
+		&invite.Code,
+		&invite.WorkspaceID,
+		&invite.Role,
+		&invite.CreatedBy,
+		&invite.ExpiresAt,
+		&invite.AcceptedBy,
+		&invite.AcceptedAt,
+		&invite.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInviteInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invite.AcceptedBy != nil || invite.AcceptedAt != nil || !now.Before(invite.ExpiresAt) {
+		return nil, ErrInviteInvalid
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`insert ignore into workspace_member (workspace_id, user_id, role, created_at) values (?, ?, ?, ?)`,
+		invite.WorkspaceID,
+		userID,
+		invite.Role,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrAlreadyMember
+	}
+
+	_, err = tx.ExecContext(ctx, `update workspace_invite set accepted_by = ?, accepted_at = ? where code = ?`, userID, now, code)
+	if err != nil {
+		return nil, err
+	}
+
+	invite.AcceptedBy = &userID
+	invite.AcceptedAt = &now
+	return &invite, tx.Commit()
+}